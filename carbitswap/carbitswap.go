@@ -0,0 +1,43 @@
+package carbitswap
+
+import (
+	"context"
+
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	bsserver "github.com/ipfs/boxo/bitswap/server"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/host"
+)
+
+var log = logging.Logger("carbitswap")
+
+// Server is a bitswap server that serves the blocks of supplied CARs. It only
+// responds to requests for blocks; it neither requests blocks nor provides
+// them to content routing.
+type Server struct {
+	net    bsnet.BitSwapNetwork
+	server *bsserver.Server
+}
+
+// New starts a bitswap server on the host h, serving the blocks of the CARs
+// opened by opener, located with finder.
+//
+// See: supplier.NewUnionBlockstore.
+func New(ctx context.Context, h host.Host, opener supplier.BlockstoreOpener, finder supplier.ContextFinder) *Server {
+	net := bsnet.NewFromIpfsHost(h, nil)
+	bs := supplier.NewUnionBlockstore(opener, finder)
+	server := bsserver.New(ctx, net, bs, bsserver.ProvideEnabled(false))
+	net.Start(server)
+	log.Infow("bitswap server started", "host_id", h.ID())
+	return &Server{
+		net:    net,
+		server: server,
+	}
+}
+
+// Close stops the bitswap server.
+func (s *Server) Close() error {
+	s.net.Stop()
+	return s.server.Close()
+}
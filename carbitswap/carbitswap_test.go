@@ -0,0 +1,69 @@
+package carbitswap_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bsclient "github.com/ipfs/boxo/bitswap/client"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/carbitswap"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/ipni/index-provider/testutil"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h.Close()
+
+	eng, err := engine.New(engine.WithHost(h), engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	carPath, err := filepath.Abs("../testdata/sample-v1.car")
+	require.NoError(t, err)
+	_, err = cs.Put(ctx, []byte("fish"), carPath, metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	subject := carbitswap.New(ctx, h, cs, eng)
+	defer subject.Close()
+
+	bs := testutil.OpenSampleCar(t, "sample-v1.car")
+	roots, err := bs.Roots()
+	require.NoError(t, err)
+	want, err := bs.Get(ctx, roots[0])
+	require.NoError(t, err)
+	require.NoError(t, bs.Close())
+
+	clientHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer clientHost.Close()
+	clientNet := bsnet.NewFromIpfsHost(clientHost, nil)
+	client := bsclient.New(ctx, clientNet,
+		blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore())),
+		bsclient.ProviderSearchDelay(time.Hour))
+	clientNet.Start(client)
+	defer clientNet.Stop()
+	defer client.Close()
+
+	require.NoError(t, clientHost.Connect(ctx, peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}))
+
+	got, err := client.GetBlock(ctx, roots[0])
+	require.NoError(t, err)
+	require.Equal(t, want.RawData(), got.RawData())
+}
@@ -0,0 +1,7 @@
+// Package carbitswap provides a bitswap server that serves the blocks of the
+// CAR files supplied via supplier.CarSupplier, over the libp2p host of the
+// provider.
+//
+// The CAR that holds a requested block is located using the engine reverse
+// index, which must be enabled. See: engine.WithReverseIndex.
+package carbitswap
@@ -32,6 +32,9 @@ func (p ProviderDealID) String() string {
 	return fmt.Sprintf("%v/%v", p.Receiver, p.DealID)
 }
 
+// BlockStoreSupplier supplies the blockstores that retrievals are served
+// from. It is implemented by supplier.CarSupplier, and by
+// stores.BlockstorePool to share open blockstores between retrievals.
 type BlockStoreSupplier interface {
 	ReadOnlyBlockstore(contextID []byte) (supplier.ClosableBlockstore, error)
 }
@@ -40,15 +43,21 @@ type carDataTransfer struct {
 	dt       datatransfer.Manager
 	supplier BlockStoreSupplier
 	stores   *stores.ReadOnlyBlockstores
+	policy   *RetrievalPolicy
 }
 
-func StartCarDataTransfer(dt datatransfer.Manager, supplier BlockStoreSupplier) error {
+func StartCarDataTransfer(dt datatransfer.Manager, supplier BlockStoreSupplier, o ...Option) error {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return err
+	}
 	cdt := &carDataTransfer{
 		dt:       dt,
 		supplier: supplier,
 		stores:   stores.NewReadOnlyBlockstores(),
+		policy:   opts.policy,
 	}
-	err := dt.RegisterVoucherType(retrievaltypes.DealProposalType, cdt)
+	err = dt.RegisterVoucherType(retrievaltypes.DealProposalType, cdt)
 	if err != nil {
 		return err
 	}
@@ -184,12 +193,26 @@ func (cdt *carDataTransfer) attemptAcceptDeal(providerDealID ProviderDealID, pro
 	}
 	contextID := dmh.Digest
 
+	if cdt.policy != nil {
+		if err = cdt.policy.acquire(providerDealID.Receiver, providerDealID.String()); err != nil {
+			return retrievaltypes.DealStatusRejected, err
+		}
+	}
+
 	// read blockstore from supplier
 	bs, err := cdt.supplier.ReadOnlyBlockstore(contextID)
 	if err != nil {
+		if cdt.policy != nil {
+			cdt.policy.release(providerDealID.Receiver, providerDealID.String())
+		}
 		return retrievaltypes.DealStatusErrored, fmt.Errorf("error reading blockstore: %w", err)
 	}
-	cdt.stores.Track(providerDealID.String(), bs)
+	if !cdt.stores.Track(providerDealID.String(), bs) {
+		// The deal is restarted, and already has a blockstore.
+		if err = bs.Close(); err != nil {
+			log.Errorw("Failed to close blockstore", "err", err)
+		}
+	}
 	return retrievaltypes.DealStatusAccepted, nil
 }
 
@@ -214,6 +237,9 @@ func (cdt *carDataTransfer) eventListener(event datatransfer.Event, channelState
 		if err != nil {
 			log.Errorf("termination error: %s", err)
 		}
+		if cdt.policy != nil {
+			cdt.policy.release(providerDealID.Receiver, providerDealID.String())
+		}
 	}
 }
 
@@ -239,5 +265,9 @@ func (ctd *carDataTransfer) transportConfigurer(channelID datatransfer.ChannelID
 	if store == nil {
 		return nil
 	}
-	return []datatransfer.TransportOption{dtgs.UseStore(storeutil.LinkSystemForBlockstore(store))}
+	lsys := storeutil.LinkSystemForBlockstore(store)
+	if ctd.policy != nil {
+		lsys.StorageReadOpener = ctd.policy.limitReads(providerDealID.Receiver, lsys.StorageReadOpener)
+	}
+	return []datatransfer.TransportOption{dtgs.UseStore(lsys)}
 }
@@ -29,6 +29,7 @@ import (
 	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
 	"github.com/ipni/go-libipni/metadata"
 	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/ipni/index-provider/engine/peerutil"
 	"github.com/ipni/index-provider/supplier"
 	"github.com/ipni/index-provider/testutil"
 	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
@@ -211,6 +212,75 @@ func TestCarDataTransfer(t *testing.T) {
 	}
 }
 
+func TestCarDataTransferRetrievalPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	contextID := []byte("cheese")
+	rdOnlyBS := testutil.OpenSampleCar(t, "sample-v1-2.car")
+	roots, err := rdOnlyBS.Roots()
+	require.NoError(t, err)
+	supplier := &fakeSupplier{blockstores: map[string]supplier.ClosableBlockstore{string(contextID): rdOnlyBS}}
+	pieceCID := pieceCIDFromContextID(t, contextID)
+
+	mn := mocknet.New()
+	srcHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	dstHost, err := mn.GenPeer()
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+
+	policy := cardatatransfer.NewRetrievalPolicy(cardatatransfer.RetrievalLimits{
+		Peers: peerutil.NewPolicy(true, dstHost.ID()),
+	})
+	srcDt := testutil.SetupDataTransferOnHost(t, srcHost, dssync.MutexWrap(datastore.NewMapDatastore()), cidlink.DefaultLinkSystem())
+	err = cardatatransfer.StartCarDataTransfer(srcDt, supplier, cardatatransfer.WithRetrievalPolicy(policy))
+	require.NoError(t, err)
+
+	dstStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	dstDt := testutil.SetupDataTransferOnHost(t, dstHost, dstStore, storeutil.LinkSystemForBlockstore(bstore.NewBlockstore(dstStore)))
+	require.NoError(t, dstDt.RegisterVoucherType(retrievaltypes.DealProposalType, nil))
+	dstResultChan := make(chan string, 1)
+	dstDt.SubscribeToEvents(func(event datatransfer.Event, channelState datatransfer.ChannelState) {
+		switch channelState.Status() {
+		case datatransfer.Cancelled, datatransfer.Failed:
+			vr, err := retrievaltypes.DealResponseFromNode(channelState.LastVoucherResult().Voucher)
+			if err == nil {
+				dstResultChan <- vr.Message
+			}
+		case datatransfer.Completed:
+			dstResultChan <- ""
+		}
+	})
+
+	retrieve := func(id retrievaltypes.DealID) string {
+		voucher := (&retrievaltypes.DealProposal{
+			PayloadCID: roots[0],
+			ID:         id,
+			Params: retrievaltypes.Params{
+				PieceCID: &pieceCID,
+			},
+		}).AsVoucher()
+		_, err := dstDt.OpenPullDataChannel(ctx, srcHost.ID(), voucher, roots[0], selectorparse.CommonSelector_ExploreAllRecursively)
+		require.NoError(t, err)
+		select {
+		case <-ctx.Done():
+			require.FailNow(t, "context closed")
+		case msg := <-dstResultChan:
+			return msg
+		}
+		return ""
+	}
+
+	require.Equal(t, cardatatransfer.ErrPeerNotAllowed.Error(), retrieve(1))
+
+	limits := policy.Limits()
+	limits.Peers.SetPeer(dstHost.ID(), true)
+	policy.SetLimits(limits)
+	require.Empty(t, retrieve(2))
+	require.Eventually(t, func() bool { return policy.Active(dstHost.ID()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
 type fakeSupplier struct {
 	blockstores map[string]supplier.ClosableBlockstore
 }
@@ -0,0 +1,30 @@
+package cardatatransfer
+
+type (
+	// Option captures a configurable parameter of the car data transfer
+	// server.
+	Option func(*options) error
+
+	options struct {
+		policy *RetrievalPolicy
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithRetrievalPolicy sets the policy that decides which peers may retrieve,
+// and limits their transfers. If unset, any peer may retrieve without limits.
+func WithRetrievalPolicy(policy *RetrievalPolicy) Option {
+	return func(o *options) error {
+		o.policy = policy
+		return nil
+	}
+}
@@ -0,0 +1,202 @@
+package cardatatransfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/linking"
+	"github.com/ipni/index-provider/engine/peerutil"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrPeerNotAllowed signals that a peer is not allowed to retrieve by the
+	// retrieval policy.
+	ErrPeerNotAllowed = errors.New("peer is not allowed to retrieve")
+	// ErrTooManyTransfers signals that a peer already has the maximum number
+	// of concurrent transfers allowed by the retrieval policy.
+	ErrTooManyTransfers = errors.New("too many concurrent transfers for peer")
+)
+
+// RetrievalLimits are the settings of a RetrievalPolicy.
+type RetrievalLimits struct {
+	// Peers determines which peers are allowed to retrieve. The zero value
+	// allows no peers; use peerutil.NewPolicy(true) to allow all peers.
+	Peers peerutil.Policy
+	// MaxTransfersPerPeer is the maximum number of concurrent transfers to a
+	// peer. Zero means no limit.
+	MaxTransfersPerPeer int
+	// MaxBytesPerSecondPerPeer is the maximum rate, in bytes per second, at
+	// which blocks are read for transfer to a peer, over all of its
+	// transfers. Zero means no limit.
+	MaxBytesPerSecondPerPeer int
+}
+
+// RetrievalPolicy decides which peers may retrieve, and limits the number of
+// concurrent transfers and the bandwidth of each peer. Its limits can be
+// changed while transfers are in progress. Changes to the bandwidth limit
+// apply to transfers in progress, and changes to the other limits apply to
+// new transfers.
+type RetrievalPolicy struct {
+	lock   sync.Mutex
+	limits RetrievalLimits
+	// active holds the keys of the transfers in progress for each peer.
+	active   map[peer.ID]map[string]struct{}
+	limiters map[peer.ID]*rate.Limiter
+}
+
+// NewRetrievalPolicy instantiates a new RetrievalPolicy with the given limits.
+func NewRetrievalPolicy(limits RetrievalLimits) *RetrievalPolicy {
+	return &RetrievalPolicy{
+		limits:   copyLimits(limits),
+		active:   make(map[peer.ID]map[string]struct{}),
+		limiters: make(map[peer.ID]*rate.Limiter),
+	}
+}
+
+// Limits returns a copy of the current limits.
+func (p *RetrievalPolicy) Limits() RetrievalLimits {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return copyLimits(p.limits)
+}
+
+// SetLimits replaces the current limits.
+func (p *RetrievalPolicy) SetLimits(limits RetrievalLimits) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.limits = copyLimits(limits)
+	limit, burst := p.rateLimit()
+	for _, limiter := range p.limiters {
+		limiter.SetLimit(limit)
+		limiter.SetBurst(burst)
+	}
+}
+
+// Allowed returns true if the peer is allowed to retrieve.
+func (p *RetrievalPolicy) Allowed(peerID peer.ID) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.limits.Peers.Eval(peerID)
+}
+
+// Active returns the number of transfers in progress for the peer.
+func (p *RetrievalPolicy) Active(peerID peer.ID) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.active[peerID])
+}
+
+// acquire records the start of the transfer identified by key to the peer.
+// Returns ErrPeerNotAllowed if the peer may not retrieve, or
+// ErrTooManyTransfers if the peer has no transfers left. Acquiring a transfer
+// that is already in progress, as for a restarted transfer, has no effect.
+func (p *RetrievalPolicy) acquire(peerID peer.ID, key string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.limits.Peers.Eval(peerID) {
+		return ErrPeerNotAllowed
+	}
+	keys, ok := p.active[peerID]
+	if !ok {
+		keys = make(map[string]struct{})
+		p.active[peerID] = keys
+	}
+	if _, ok = keys[key]; ok {
+		return nil
+	}
+	if p.limits.MaxTransfersPerPeer != 0 && len(keys) >= p.limits.MaxTransfersPerPeer {
+		return ErrTooManyTransfers
+	}
+	keys[key] = struct{}{}
+	if _, ok = p.limiters[peerID]; !ok {
+		p.limiters[peerID] = rate.NewLimiter(p.rateLimit())
+	}
+	return nil
+}
+
+// release records the end of the transfer identified by key to the peer.
+func (p *RetrievalPolicy) release(peerID peer.ID, key string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	keys, ok := p.active[peerID]
+	if !ok {
+		return
+	}
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(p.active, peerID)
+		delete(p.limiters, peerID)
+	}
+}
+
+// limitReads wraps opener so that the blocks it reads for the peer are read
+// no faster than the bandwidth limit of the peer.
+func (p *RetrievalPolicy) limitReads(peerID peer.ID, opener linking.BlockReadOpener) linking.BlockReadOpener {
+	p.lock.Lock()
+	limiter := p.limiters[peerID]
+	p.lock.Unlock()
+	if limiter == nil {
+		return opener
+	}
+	return func(lctx linking.LinkContext, lnk datamodel.Link) (io.Reader, error) {
+		r, err := opener(lctx, lnk)
+		if err != nil {
+			return nil, err
+		}
+		ctx := lctx.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return &limitedReader{
+			r:       r,
+			limiter: limiter,
+			ctx:     ctx,
+		}, nil
+	}
+}
+
+// rateLimit returns the limit and burst of the rate limiters for the current
+// bandwidth limit.
+func (p *RetrievalPolicy) rateLimit() (rate.Limit, int) {
+	if p.limits.MaxBytesPerSecondPerPeer <= 0 {
+		return rate.Inf, 0
+	}
+	return rate.Limit(p.limits.MaxBytesPerSecondPerPeer), p.limits.MaxBytesPerSecondPerPeer
+}
+
+func copyLimits(limits RetrievalLimits) RetrievalLimits {
+	limits.Peers = peerutil.NewPolicy(limits.Peers.Default(), limits.Peers.Except()...)
+	return limits
+}
+
+// limitedReader reads no faster than allowed by a rate limiter.
+type limitedReader struct {
+	r       io.Reader
+	limiter *rate.Limiter
+	ctx     context.Context
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.limiter.Limit() != rate.Inf {
+		// Waiting for more than the burst size always fails, so never read
+		// more than that at once.
+		if burst := l.limiter.Burst(); burst > 0 && len(b) > burst {
+			b = b[:burst]
+		}
+	}
+	n, err := l.r.Read(b)
+	if n > 0 {
+		if werr := l.limiter.WaitN(l.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,88 @@
+package cardatatransfer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/linking"
+	"github.com/ipni/index-provider/engine/peerutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrievalPolicy(t *testing.T) {
+	peers := random.Peers(2)
+	allowed, denied := peers[0], peers[1]
+
+	subject := NewRetrievalPolicy(RetrievalLimits{
+		Peers:               peerutil.NewPolicy(true, denied),
+		MaxTransfersPerPeer: 2,
+	})
+	require.True(t, subject.Allowed(allowed))
+	require.False(t, subject.Allowed(denied))
+
+	require.ErrorIs(t, subject.acquire(denied, "a"), ErrPeerNotAllowed)
+	require.Zero(t, subject.Active(denied))
+
+	require.NoError(t, subject.acquire(allowed, "a"))
+	require.NoError(t, subject.acquire(allowed, "b"))
+	// Acquiring the same transfer again, as when restarted, does not count.
+	require.NoError(t, subject.acquire(allowed, "b"))
+	require.Equal(t, 2, subject.Active(allowed))
+	require.ErrorIs(t, subject.acquire(allowed, "c"), ErrTooManyTransfers)
+
+	subject.release(allowed, "a")
+	require.NoError(t, subject.acquire(allowed, "c"))
+
+	// Changing the limits applies to new transfers only.
+	limits := subject.Limits()
+	limits.Peers.SetPeer(allowed, false)
+	subject.SetLimits(limits)
+	require.Equal(t, 2, subject.Active(allowed))
+	require.ErrorIs(t, subject.acquire(allowed, "d"), ErrPeerNotAllowed)
+
+	subject.release(allowed, "b")
+	subject.release(allowed, "c")
+	require.Zero(t, subject.Active(allowed))
+	require.Empty(t, subject.limiters)
+
+	// Limits returns a copy.
+	limits.Peers.SetPeer(allowed, true)
+	require.False(t, subject.Allowed(allowed))
+}
+
+func TestRetrievalPolicyBandwidth(t *testing.T) {
+	peerID := random.Peers(1)[0]
+	data := random.Bytes(5000)
+	opener := func(linking.LinkContext, datamodel.Link) (io.Reader, error) {
+		return bytes.NewReader(data), nil
+	}
+	read := func(subject *RetrievalPolicy) time.Duration {
+		r, err := subject.limitReads(peerID, opener)(linking.LinkContext{Ctx: context.Background()}, nil)
+		require.NoError(t, err)
+		start := time.Now()
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+		return time.Since(start)
+	}
+
+	subject := NewRetrievalPolicy(RetrievalLimits{
+		Peers:                    peerutil.NewPolicy(true),
+		MaxBytesPerSecondPerPeer: 4000,
+	})
+	require.NoError(t, subject.acquire(peerID, "a"))
+	// The first 4000 bytes are read at once, as the burst, and the rest take
+	// a quarter of a second.
+	require.GreaterOrEqual(t, read(subject), 200*time.Millisecond)
+
+	// Removing the limit applies to the transfers in progress.
+	subject.SetLimits(RetrievalLimits{Peers: peerutil.NewPolicy(true)})
+	for i := 0; i < 10; i++ {
+		require.Less(t, read(subject), 100*time.Millisecond)
+	}
+}
@@ -0,0 +1,295 @@
+package stores
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ipni/index-provider/metrics"
+	"github.com/ipni/index-provider/supplier"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrPoolFull signals that a blockstore could not be opened because the pool
+// is at capacity and all open blockstores are in use.
+var ErrPoolFull = errors.New("too many open blockstores")
+
+// BlockstoreOpener opens the read-only blockstore for a context ID.
+type BlockstoreOpener = supplier.BlockstoreOpener
+
+// BlockstorePool is a bounded pool of open read-only blockstores, shared by
+// all users of the same context ID. Each blockstore returned by the pool is a
+// reference to a pooled blockstore, released by closing it. Blockstores with
+// no references are kept open for reuse, and the least recently used of them
+// is closed when room is needed for another.
+//
+// BlockstorePool implements the same ReadOnlyBlockstore method as
+// supplier.CarSupplier, so it can be used wherever the supplier is. It should
+// be registered with the supplier, so that blockstores over CARs that are
+// removed or replaced are no longer shared. See:
+// supplier.CarSupplier.RegisterInvalidator.
+type BlockstorePool struct {
+	opener   BlockstoreOpener
+	capacity int
+
+	lock    sync.Mutex
+	entries map[string]*poolEntry
+	// opening holds the blockstores being opened, by key.
+	opening map[string]*pendingOpen
+	// open counts the open blockstores, including those being opened and
+	// those invalidated but still in use.
+	open int
+	// idle holds the entries with no references, least recently used first.
+	idle   *list.List
+	closed bool
+}
+
+type poolEntry struct {
+	key  string
+	bs   supplier.ClosableBlockstore
+	refs int
+	// elem is the element of the entry in the idle list, or nil if the entry
+	// is in use.
+	elem *list.Element
+	// invalidated signals that the entry is no longer shared, and is closed
+	// once released.
+	invalidated bool
+}
+
+// pendingOpen is a blockstore being opened, which other users of the same
+// key wait for.
+type pendingOpen struct {
+	done        chan struct{}
+	invalidated bool
+}
+
+// pooledBlockstore is a reference to a pooled blockstore.
+type pooledBlockstore struct {
+	supplier.ClosableBlockstore
+	pool  *BlockstorePool
+	entry *poolEntry
+	once  sync.Once
+}
+
+var _ supplier.BlockstoreInvalidator = (*BlockstorePool)(nil)
+
+// NewBlockstorePool instantiates a pool that keeps at most capacity
+// blockstores, opened with opener, open at any time.
+func NewBlockstorePool(opener BlockstoreOpener, capacity int) *BlockstorePool {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &BlockstorePool{
+		opener:   opener,
+		capacity: capacity,
+		entries:  make(map[string]*poolEntry),
+		opening:  make(map[string]*pendingOpen),
+		idle:     list.New(),
+	}
+}
+
+// ReadOnlyBlockstore returns a reference to the open blockstore for the
+// context ID, opening it if necessary. The returned blockstore must be closed
+// to release the reference. Blockstores are opened without blocking the use
+// of the others in the pool; concurrent users of the same context ID wait for
+// it to be opened once.
+//
+// Returns ErrPoolFull if the blockstore is not already open, and cannot be
+// opened because all open blockstores are in use.
+func (p *BlockstorePool) ReadOnlyBlockstore(contextID []byte) (supplier.ClosableBlockstore, error) {
+	key := string(contextID)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for {
+		if p.closed {
+			return nil, errors.New("blockstore pool is closed")
+		}
+		if entry, ok := p.entries[key]; ok {
+			recordAcquire("hit")
+			return p.acquire(entry), nil
+		}
+		if pending, ok := p.opening[key]; ok {
+			p.lock.Unlock()
+			<-pending.done
+			p.lock.Lock()
+			continue
+		}
+		if p.open >= p.capacity && !p.evictIdle() {
+			recordAcquire("full")
+			return nil, ErrPoolFull
+		}
+		entry, err := p.openEntry(key, contextID)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			// Invalidated while being opened; open it anew.
+			continue
+		}
+		recordAcquire("miss")
+		return p.acquire(entry), nil
+	}
+}
+
+// Invalidate stops sharing the blockstore for the context ID, so that its next
+// user opens it anew. It is called when the CAR of the context ID is removed
+// or replaced. The blockstore is closed once it is no longer in use.
+func (p *BlockstorePool) Invalidate(contextID []byte) {
+	key := string(contextID)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if pending, ok := p.opening[key]; ok {
+		pending.invalidated = true
+	}
+	entry, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	delete(p.entries, key)
+	entry.invalidated = true
+	if entry.refs == 0 {
+		if err := p.closeEntry(entry); err != nil {
+			log.Errorw("Failed to close invalidated blockstore", "err", err)
+		}
+	}
+}
+
+// Close closes the blockstores not in use. Blockstores in use are closed when
+// their last reference is released.
+func (p *BlockstorePool) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closed = true
+	var errs []error
+	for p.idle.Len() != 0 {
+		if err := p.closeEntry(p.idle.Front().Value.(*poolEntry)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Len returns the number of open blockstores.
+func (p *BlockstorePool) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.open
+}
+
+// Close releases the reference to the pooled blockstore. It is safe to call
+// more than once.
+func (b *pooledBlockstore) Close() error {
+	var err error
+	b.once.Do(func() {
+		err = b.pool.release(b.entry)
+	})
+	return err
+}
+
+// acquire returns a new reference to the entry. The caller must hold the lock.
+func (p *BlockstorePool) acquire(entry *poolEntry) *pooledBlockstore {
+	if entry.elem != nil {
+		p.idle.Remove(entry.elem)
+		entry.elem = nil
+	}
+	entry.refs++
+	return &pooledBlockstore{
+		ClosableBlockstore: entry.bs,
+		pool:               p,
+		entry:              entry,
+	}
+}
+
+// openEntry opens the blockstore for the key, and adds it to the pool. The
+// lock, held by the caller, is released while opening, so that the other
+// blockstores can be used meanwhile. Returns a nil entry if the key was
+// invalidated while opening.
+func (p *BlockstorePool) openEntry(key string, contextID []byte) (*poolEntry, error) {
+	pending := &pendingOpen{done: make(chan struct{})}
+	p.opening[key] = pending
+	p.open++
+
+	p.lock.Unlock()
+	bs, err := p.opener.ReadOnlyBlockstore(contextID)
+	p.lock.Lock()
+
+	delete(p.opening, key)
+	close(pending.done)
+	if err != nil {
+		p.open--
+		return nil, err
+	}
+	if pending.invalidated || p.closed {
+		p.open--
+		if err = bs.Close(); err != nil {
+			log.Errorw("Failed to close invalidated blockstore", "err", err)
+		}
+		return nil, nil
+	}
+	metrics.BlockstorePool.Open.Add(context.Background(), 1)
+	entry := &poolEntry{
+		key: key,
+		bs:  bs,
+	}
+	p.entries[key] = entry
+	return entry, nil
+}
+
+func (p *BlockstorePool) release(entry *poolEntry) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	entry.refs--
+	if entry.refs != 0 {
+		return nil
+	}
+	if p.closed || entry.invalidated {
+		return p.closeEntry(entry)
+	}
+	entry.elem = p.idle.PushBack(entry)
+	return nil
+}
+
+// evictIdle closes the least recently used blockstore not in use. Returns
+// false if all blockstores are in use.
+func (p *BlockstorePool) evictIdle() bool {
+	front := p.idle.Front()
+	if front == nil {
+		return false
+	}
+	entry := front.Value.(*poolEntry)
+	if err := p.closeEntry(entry); err != nil {
+		log.Errorw("Failed to close evicted blockstore", "err", err)
+	}
+	metrics.BlockstorePool.Evicted.Add(context.Background(), 1)
+	return true
+}
+
+func (p *BlockstorePool) closeEntry(entry *poolEntry) error {
+	if entry.elem != nil {
+		p.idle.Remove(entry.elem)
+		entry.elem = nil
+	}
+	if p.entries[entry.key] == entry {
+		delete(p.entries, entry.key)
+	}
+	p.open--
+	metrics.BlockstorePool.Open.Add(context.Background(), -1)
+	if err := entry.bs.Close(); err != nil {
+		return fmt.Errorf("failed to close read-only blockstore: %w", err)
+	}
+	return nil
+}
+
+func recordAcquire(result string) {
+	metrics.BlockstorePool.Acquire.Add(context.Background(), 1,
+		metric.WithAttributeSet(attribute.NewSet(attribute.String("result", result))))
+}
@@ -0,0 +1,149 @@
+package stores_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipni/index-provider/cardatatransfer/stores"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/ipni/index-provider/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type countingOpener struct {
+	t      *testing.T
+	opened map[string]int
+}
+
+func (o *countingOpener) ReadOnlyBlockstore(contextID []byte) (supplier.ClosableBlockstore, error) {
+	o.opened[string(contextID)]++
+	return testutil.OpenSampleCar(o.t, "sample-v1.car"), nil
+}
+
+func TestBlockstorePool(t *testing.T) {
+	opener := &countingOpener{t: t, opened: make(map[string]int)}
+	pool := stores.NewBlockstorePool(opener, 2)
+
+	a1, err := pool.ReadOnlyBlockstore([]byte("a"))
+	require.NoError(t, err)
+	a2, err := pool.ReadOnlyBlockstore([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, 1, opener.opened["a"], "blockstore must be shared")
+	require.Equal(t, 1, pool.Len())
+
+	b, err := pool.ReadOnlyBlockstore([]byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, 2, pool.Len())
+
+	// All open blockstores are in use.
+	_, err = pool.ReadOnlyBlockstore([]byte("c"))
+	require.ErrorIs(t, err, stores.ErrPoolFull)
+
+	require.NoError(t, a1.Close())
+	require.NoError(t, a1.Close(), "closing twice must release once")
+	_, err = pool.ReadOnlyBlockstore([]byte("c"))
+	require.ErrorIs(t, err, stores.ErrPoolFull)
+
+	// Once released, a is idle and evicted to make room for c.
+	require.NoError(t, a2.Close())
+	c, err := pool.ReadOnlyBlockstore([]byte("c"))
+	require.NoError(t, err)
+	require.Equal(t, 2, pool.Len())
+
+	// a is opened again, by evicting the idle b.
+	require.NoError(t, b.Close())
+	a3, err := pool.ReadOnlyBlockstore([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, 2, opener.opened["a"])
+	require.Equal(t, 1, opener.opened["b"])
+
+	// Blockstores in use are closed when released after the pool is closed.
+	require.NoError(t, pool.Close())
+	require.Equal(t, 2, pool.Len())
+	require.NoError(t, a3.Close())
+	require.NoError(t, c.Close())
+	require.Equal(t, 0, pool.Len())
+	_, err = pool.ReadOnlyBlockstore([]byte("a"))
+	require.Error(t, err)
+}
+
+func TestBlockstorePool_Invalidate(t *testing.T) {
+	opener := &countingOpener{t: t, opened: make(map[string]int)}
+	pool := stores.NewBlockstorePool(opener, 2)
+
+	a1, err := pool.ReadOnlyBlockstore([]byte("a"))
+	require.NoError(t, err)
+	b, err := pool.ReadOnlyBlockstore([]byte("b"))
+	require.NoError(t, err)
+	require.NoError(t, b.Close())
+
+	// An idle blockstore is closed right away.
+	pool.Invalidate([]byte("b"))
+	require.Equal(t, 1, pool.Len())
+
+	// A blockstore in use is no longer shared, and closed once released.
+	pool.Invalidate([]byte("a"))
+	a2, err := pool.ReadOnlyBlockstore([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, 2, opener.opened["a"])
+	require.Equal(t, 2, pool.Len())
+	require.NoError(t, a1.Close())
+	require.Equal(t, 1, pool.Len())
+	require.NoError(t, a2.Close())
+	require.Equal(t, 1, pool.Len())
+	require.NoError(t, pool.Close())
+}
+
+type blockingOpener struct {
+	countingOpener
+	lock    sync.Mutex
+	release chan struct{}
+}
+
+func (o *blockingOpener) ReadOnlyBlockstore(contextID []byte) (supplier.ClosableBlockstore, error) {
+	if string(contextID) == "slow" {
+		<-o.release
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.countingOpener.ReadOnlyBlockstore(contextID)
+}
+
+func TestBlockstorePool_SlowOpen(t *testing.T) {
+	opener := &blockingOpener{
+		countingOpener: countingOpener{t: t, opened: make(map[string]int)},
+		release:        make(chan struct{}),
+	}
+	pool := stores.NewBlockstorePool(opener, 3)
+
+	fast, err := pool.ReadOnlyBlockstore([]byte("fast"))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	slow := make([]supplier.ClosableBlockstore, 2)
+	for i := range slow {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			slow[i], err = pool.ReadOnlyBlockstore([]byte("slow"))
+			require.NoError(t, err)
+		}(i)
+	}
+
+	// Opening a slow blockstore does not stall the others.
+	require.Eventually(t, func() bool { return pool.Len() == 2 }, time.Second, time.Millisecond)
+	fast2, err := pool.ReadOnlyBlockstore([]byte("fast"))
+	require.NoError(t, err)
+	require.NoError(t, fast.Close())
+	require.NoError(t, fast2.Close())
+
+	close(opener.release)
+	wg.Wait()
+	require.Equal(t, 1, opener.opened["slow"], "concurrent opens must be shared")
+	for _, bs := range slow {
+		require.NoError(t, bs.Close())
+	}
+	require.NoError(t, pool.Close())
+}
@@ -1,5 +1,5 @@
-// Package stores is copy pasted from go-fil-markets stores package -
-// there is no novel code here.
+// Package stores tracks the read-only blockstores used by retrievals.
+// ReadOnlyBlockstores is copied from the go-fil-markets stores package.
 package stores
 
 import (
@@ -9,8 +9,11 @@ import (
 	"sync"
 
 	bstore "github.com/ipfs/boxo/blockstore"
+	logging "github.com/ipfs/go-log/v2"
 )
 
+var log = logging.Logger("car-data-transfer/stores")
+
 var ErrNotFound = errors.New("not found")
 
 func IsNotFound(err error) bool {
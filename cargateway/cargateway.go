@@ -0,0 +1,259 @@
+package cargateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-graphsync/storeutil"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipfs/go-unixfsnode/data"
+	carv2 "github.com/ipld/go-car/v2"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	"github.com/ipld/go-ipld-prime/linking"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/multiformats/go-multicodec"
+)
+
+var log = logging.Logger("cargateway")
+
+const (
+	// RawContentType is the media type of a block response.
+	RawContentType = "application/vnd.ipld.raw"
+	// CarContentType is the media type of a CAR response.
+	CarContentType = "application/vnd.ipld.car"
+
+	carResponseType = CarContentType + "; version=1; order=dfs; dups=n"
+	immutableCache  = "public, max-age=29030400, immutable"
+)
+
+// DagScope is the value of the dag-scope parameter of a CAR request, which
+// determines the blocks included in the response.
+type DagScope string
+
+const (
+	// DagScopeBlock includes only the root block.
+	DagScopeBlock DagScope = "block"
+	// DagScopeEntity includes the blocks needed to read the entity at the
+	// root: all the blocks of a UnixFS file, and only the root block
+	// otherwise.
+	DagScopeEntity DagScope = "entity"
+	// DagScopeAll includes all the blocks of the DAG at the root.
+	DagScopeAll DagScope = "all"
+)
+
+type Server struct {
+	server *http.Server
+	l      net.Listener
+}
+
+// New creates a gateway server that serves the content of the CAR files
+// opened by opener. The engine is used to locate the CAR that holds a requested
+// CID, and must have the reverse index enabled. See: engine.WithReverseIndex.
+func New(e *engine.Engine, opener supplier.BlockstoreOpener, o ...Option) (*Server, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", opts.listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Handler:      newHandler(e, opener),
+		ReadTimeout:  opts.readTimeout,
+		WriteTimeout: opts.writeTimeout,
+	}
+	return &Server{server, l}, nil
+}
+
+func (s *Server) Start() error {
+	log.Infow("gateway http server listening", "addr", s.l.Addr())
+	return s.server.Serve(s.l)
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Info("gateway http server shutdown")
+	return s.server.Shutdown(ctx)
+}
+
+type gatewayHandler struct {
+	e      *engine.Engine
+	opener supplier.BlockstoreOpener
+}
+
+func newHandler(e *engine.Engine, opener supplier.BlockstoreOpener) http.Handler {
+	h := &gatewayHandler{e, opener}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ipfs/{path...}", h.handleIpfs)
+	mux.HandleFunc("HEAD /ipfs/{path...}", h.handleIpfs)
+	return mux
+}
+
+func (h *gatewayHandler) handleIpfs(w http.ResponseWriter, r *http.Request) {
+	cidStr, contentPath, _ := strings.Cut(r.PathValue("path"), "/")
+	root, err := cid.Decode(cidStr)
+	if err != nil {
+		http.Error(w, "invalid cid: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if contentPath != "" {
+		http.Error(w, "content paths are not supported", http.StatusNotImplemented)
+		return
+	}
+
+	format, err := responseFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+	var scope DagScope
+	if format == CarContentType {
+		if r.URL.Query().Has("entity-bytes") {
+			http.Error(w, "entity-bytes is not supported", http.StatusNotImplemented)
+			return
+		}
+		scope = DagScope(r.URL.Query().Get("dag-scope"))
+		switch scope {
+		case "":
+			scope = DagScopeAll
+		case DagScopeBlock, DagScopeEntity, DagScopeAll:
+		default:
+			http.Error(w, fmt.Sprintf("invalid dag-scope %q", scope), http.StatusBadRequest)
+			return
+		}
+	}
+
+	bs, err := supplier.FindBlockstore(r.Context(), h.opener, h.e, root)
+	if err != nil {
+		if errors.Is(err, supplier.ErrNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Errorw("Failed to find CAR for cid", "cid", root, "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	defer bs.Close()
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", immutableCache)
+	if format == RawContentType {
+		h.serveRaw(w, r, bs, root)
+		return
+	}
+	h.serveCar(w, r, bs, root, scope)
+}
+
+func (h *gatewayHandler) serveRaw(w http.ResponseWriter, r *http.Request, bs supplier.ClosableBlockstore, root cid.Cid) {
+	blk, err := bs.Get(r.Context(), root)
+	if err != nil {
+		log.Errorw("Failed to get block", "cid", root, "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", RawContentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(blk.RawData())))
+	w.Header().Set("Etag", fmt.Sprintf(`"%s.raw"`, root))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err = w.Write(blk.RawData()); err != nil {
+		log.Errorw("Failed to write block response", "cid", root, "err", err)
+	}
+}
+
+func (h *gatewayHandler) serveCar(w http.ResponseWriter, r *http.Request, bs supplier.ClosableBlockstore, root cid.Cid, scope DagScope) {
+	lsys := storeutil.LinkSystemForBlockstore(bs)
+	selector := selectorparse.CommonSelector_ExploreAllRecursively
+	if scope != DagScopeAll {
+		selector = selectorparse.CommonSelector_MatchPoint
+		if scope == DagScopeEntity {
+			isFile, err := isUnixFSFile(r.Context(), bs, root)
+			if err != nil {
+				log.Errorw("Failed to read root block", "cid", root, "err", err)
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+			if isFile {
+				selector = selectorparse.CommonSelector_ExploreAllRecursively
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", carResponseType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.car"`, root))
+	w.Header().Set("Etag", fmt.Sprintf(`"%s.car.%s"`, root, scope))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := carv2.TraverseV1(r.Context(), &lsys, root, selector, w); err != nil {
+		// The response status is already sent, so abort the response to signal
+		// to the client that it is incomplete.
+		log.Errorw("Failed to write CAR response", "cid", root, "scope", scope, "err", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// isUnixFSFile checks whether the block root is the root of a UnixFS file.
+func isUnixFSFile(ctx context.Context, bs supplier.ClosableBlockstore, root cid.Cid) (bool, error) {
+	if multicodec.Code(root.Prefix().Codec) != multicodec.DagPb {
+		return false, nil
+	}
+	lsys := storeutil.LinkSystemForBlockstore(bs)
+	n, err := lsys.Load(linking.LinkContext{Ctx: ctx}, cidlink.Link{Cid: root}, dagpb.Type.PBNode)
+	if err != nil {
+		return false, err
+	}
+	pbn, ok := n.(dagpb.PBNode)
+	if !ok || !pbn.FieldData().Exists() {
+		return false, nil
+	}
+	ufsData, err := data.DecodeUnixFSData(pbn.FieldData().Must().Bytes())
+	if err != nil {
+		// Not UnixFS.
+		return false, nil
+	}
+	return ufsData.FieldDataType().Int() == data.Data_File, nil
+}
+
+// responseFormat returns the media type of the response requested by the
+// format query parameter, or else by the Accept header.
+func responseFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "raw":
+		return RawContentType, nil
+	case "car":
+		return CarContentType, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported format %q", format)
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case RawContentType:
+			return RawContentType, nil
+		case CarContentType:
+			if v, ok := params["version"]; ok && v != "1" {
+				continue
+			}
+			return CarContentType, nil
+		}
+	}
+	return "", fmt.Errorf("only %s and %s responses are supported", RawContentType, CarContentType)
+}
@@ -0,0 +1,114 @@
+package cargateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-test/random"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/ipni/index-provider/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eng, err := engine.New(engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	carPath, err := filepath.Abs("../testdata/sample-v1.car")
+	require.NoError(t, err)
+	_, err = cs.Put(ctx, []byte("fish"), carPath, metadata.Default.New(&metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+
+	bs := testutil.OpenSampleCar(t, "sample-v1.car")
+	roots, err := bs.Roots()
+	require.NoError(t, err)
+	root := roots[0]
+	rootBlock, err := bs.Get(ctx, root)
+	require.NoError(t, err)
+	blockCount := testutil.GetBstoreLen(ctx, t, bs)
+	require.NoError(t, bs.Close())
+
+	server := httptest.NewServer(newHandler(eng, cs))
+	defer server.Close()
+
+	get := func(path, accept string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+	readCar := func(body []byte) []cid.Cid {
+		br, err := carv2.NewBlockReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		require.Equal(t, []cid.Cid{root}, br.Roots)
+		var cids []cid.Cid
+		for {
+			blk, err := br.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			cids = append(cids, blk.Cid())
+		}
+		return cids
+	}
+
+	resp, body := get("/ipfs/"+root.String(), RawContentType)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, RawContentType, resp.Header.Get("Content-Type"))
+	require.Equal(t, rootBlock.RawData(), body)
+
+	resp, body = get("/ipfs/"+root.String()+"?format=raw", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, rootBlock.RawData(), body)
+
+	resp, body = get("/ipfs/"+root.String(), CarContentType+"; version=1")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, carResponseType, resp.Header.Get("Content-Type"))
+	cids := readCar(body)
+	require.Len(t, cids, blockCount)
+	require.Equal(t, root, cids[0])
+
+	for _, scope := range []DagScope{DagScopeBlock, DagScopeEntity} {
+		resp, body = get("/ipfs/"+root.String()+"?format=car&dag-scope="+string(scope), "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, []cid.Cid{root}, readCar(body))
+	}
+
+	resp, _ = get("/ipfs/"+root.String()+"?format=car&dag-scope=everything", "")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = get("/ipfs/"+root.String(), "text/html")
+	require.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+
+	resp, _ = get("/ipfs/"+root.String()+"/some/path", RawContentType)
+	require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+
+	unknown := random.Cids(1)[0]
+	resp, _ = get("/ipfs/"+unknown.String(), RawContentType)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,9 @@
+// Package cargateway provides a HTTP retrieval server, following the IPFS
+// trustless gateway specification, that serves the content of the CAR files
+// supplied via supplier.CarSupplier.
+//
+// Blocks are served as application/vnd.ipld.raw, and DAGs as
+// application/vnd.ipld.car with the dag-scope parameter. The CAR that holds
+// a requested CID is located using the engine reverse index, which must be
+// enabled. See: engine.WithReverseIndex.
+package cargateway
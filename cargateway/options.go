@@ -0,0 +1,56 @@
+package cargateway
+
+import "time"
+
+type (
+	// Option captures a configurable parameter in the gateway HTTP server.
+	Option func(*options) error
+
+	options struct {
+		listenAddr   string
+		readTimeout  time.Duration
+		writeTimeout time.Duration
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		listenAddr:   "0.0.0.0:3106",
+		readTimeout:  30 * time.Second,
+		writeTimeout: 10 * time.Minute,
+	}
+
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithListenAddr sets the net address on which the gateway HTTP server is
+// exposed. If unset, the default address of '0.0.0.0:3106' is used.
+func WithListenAddr(addr string) Option {
+	return func(o *options) error {
+		o.listenAddr = addr
+		return nil
+	}
+}
+
+// WithReadTimeout sets the HTTP read timeout.
+// If unset, the default of 30 seconds is used.
+func WithReadTimeout(t time.Duration) Option {
+	return func(o *options) error {
+		o.readTimeout = t
+		return nil
+	}
+}
+
+// WithWriteTimeout sets the HTTP write timeout, which bounds the time taken
+// to send a CAR response. If unset, the default of 10 minutes is used.
+func WithWriteTimeout(t time.Duration) Option {
+	return func(o *options) error {
+		o.writeTimeout = t
+		return nil
+	}
+}
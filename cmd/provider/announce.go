@@ -19,6 +19,10 @@ var AnnounceCmd = &cli.Command{
 
 var announceFlags = []cli.Flag{
 	adminAPIFlag,
+	adminTokenFlag,
+	adminTLSCAFlag,
+	adminTLSCertFlag,
+	adminTLSKeyFlag,
 }
 
 var AnnounceHttpCmd = &cli.Command{
@@ -30,6 +34,10 @@ var AnnounceHttpCmd = &cli.Command{
 
 var announceHttpFlags = []cli.Flag{
 	adminAPIFlag,
+	adminTokenFlag,
+	adminTLSCAFlag,
+	adminTLSCertFlag,
+	adminTLSKeyFlag,
 	indexerFlag,
 }
 
@@ -39,8 +47,7 @@ func announceCommand(cctx *cli.Context) error {
 		return err
 	}
 
-	cl := &http.Client{}
-	resp, err := cl.Do(req)
+	resp, err := doHttpReq(req)
 	if err != nil {
 		return err
 	}
@@ -79,8 +86,7 @@ func announceHttpCommand(cctx *cli.Context) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	cl := &http.Client{}
-	resp, err := cl.Do(req)
+	resp, err := doHttpReq(req)
 	if err != nil {
 		return err
 	}
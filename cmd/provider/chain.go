@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+var ChainCmd = &cli.Command{
+	Name:  "chain",
+	Usage: "Exports or imports the advertisement chain, for backup and migration.",
+	Subcommands: []*cli.Command{
+		exportChainSubCmd,
+		importChainSubCmd,
+	},
+}
+
+var exportChainSubCmd = &cli.Command{
+	Name:  "export",
+	Usage: "Exports the advertisement chain to a CARv2 file.",
+	Description: `Writes every advertisement in the chain to a CARv2 file whose root is the
+latest advertisement. If --entries is set, then the entries blocks that are
+cached by the provider are also written; entries that are not cached are
+regenerated from the content when needed, and so are not required to restore
+the chain.
+
+The daemon must not be running.`,
+	Action: doExportChain,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "output",
+			Usage:    "Path of the CAR file to write. The file must not exist.",
+			Aliases:  []string{"o"},
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "entries",
+			Usage: "Also export the cached entries blocks of each advertisement.",
+		},
+	},
+}
+
+var importChainSubCmd = &cli.Command{
+	Name:  "import",
+	Usage: "Imports the advertisement chain from a CARv2 file.",
+	Description: `Restores an advertisement chain written by the export command into a
+datastore that has no advertisement chain. The root of the CAR file becomes
+the latest advertisement, and the context ID mappings are rebuilt from the
+advertisements. The provider identity must be the one that signed the chain.
+
+The daemon must not be running.`,
+	Action: doImportChain,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "input",
+			Usage:    "Path of the CAR file to read.",
+			Aliases:  []string{"i"},
+			Required: true,
+		},
+	},
+}
+
+func doExportChain(cctx *cli.Context) error {
+	cfg, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	ds, err := openDatastore(cfg.Datastore)
+	if err != nil {
+		return err
+	}
+	defer ds.Close()
+
+	eng, err := newOfflineEngine(cfg, ds)
+	if err != nil {
+		return err
+	}
+	// Starting the engine gives access to the cached entries.
+	if cctx.Bool("entries") {
+		if err = eng.Start(cctx.Context); err != nil {
+			return err
+		}
+		defer eng.Shutdown()
+	}
+
+	outPath := cctx.String("output")
+	f, err := os.OpenFile(outPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	export, err := eng.ExportChain(cctx.Context, f, cctx.Bool("entries"))
+	if err != nil {
+		f.Close()
+		os.Remove(outPath)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	w := cctx.App.Writer
+	fmt.Fprintln(w, "Head:", export.Head)
+	fmt.Fprintln(w, "Advertisements:", export.Ads)
+	if cctx.Bool("entries") {
+		fmt.Fprintln(w, "Entries blocks:", export.EntriesBlocks)
+		fmt.Fprintln(w, "Advertisements with uncached entries:", export.UncachedEntries)
+	}
+	return nil
+}
+
+func doImportChain(cctx *cli.Context) error {
+	cfg, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Identity.Rotation != nil {
+		return errors.New("identity rotation is pending; cannot import advertisement chain")
+	}
+	ds, err := openDatastore(cfg.Datastore)
+	if err != nil {
+		return err
+	}
+	defer ds.Close()
+
+	eng, err := newOfflineEngine(cfg, ds)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(cctx.String("input"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	report, err := eng.ImportChain(cctx.Context, f)
+	if err != nil {
+		return err
+	}
+
+	w := cctx.App.Writer
+	fmt.Fprintln(w, "Head:", report.Head)
+	fmt.Fprintln(w, "Advertisements:", report.Ads)
+	fmt.Fprintln(w, "Advertised context IDs:", report.ContextIDs)
+	fmt.Fprintln(w, "Mappings rebuilt:", report.Repaired())
+	var unrepaired int
+	for _, p := range report.Problems {
+		if !p.Repaired {
+			fmt.Fprintln(w, "\t", p)
+			unrepaired++
+		}
+	}
+	if unrepaired != 0 {
+		return fmt.Errorf("imported chain has %d problems", unrepaired)
+	}
+	return nil
+}
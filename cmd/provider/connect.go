@@ -23,6 +23,10 @@ var connectFlags = []cli.Flag{
 		Required: true,
 	},
 	adminAPIFlag,
+	adminTokenFlag,
+	adminTLSCAFlag,
+	adminTLSCertFlag,
+	adminTLSKeyFlag,
 }
 
 func connectCommand(cctx *cli.Context) error {
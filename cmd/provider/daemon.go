@@ -11,20 +11,30 @@ import (
 	dtnetwork "github.com/filecoin-project/go-data-transfer/v2/network"
 	gstransport "github.com/filecoin-project/go-data-transfer/v2/transport/graphsync"
 	"github.com/ipfs/boxo/bootstrap"
-	leveldb "github.com/ipfs/go-ds-leveldb"
 	gsimpl "github.com/ipfs/go-graphsync/impl"
 	gsnet "github.com/ipfs/go-graphsync/network"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipld/go-car/v2"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/carbitswap"
 	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/ipni/index-provider/cardatatransfer/stores"
+	"github.com/ipni/index-provider/cargateway"
 	"github.com/ipni/index-provider/cmd/provider/internal/config"
 	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/engine/peerutil"
 	"github.com/ipni/index-provider/engine/policy"
+	"github.com/ipni/index-provider/engine/webhook"
+	"github.com/ipni/index-provider/metrics"
 	adminserver "github.com/ipni/index-provider/server/admin/http"
 	droutingserver "github.com/ipni/index-provider/server/delegatedrouting/server"
+	findserver "github.com/ipni/index-provider/server/find/http"
 	"github.com/ipni/index-provider/supplier"
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/mitchellh/go-homedir"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/urfave/cli/v2"
@@ -97,6 +107,17 @@ func daemonCommand(cctx *cli.Context) error {
 		return err
 	}
 
+	rpCfg := cfg.ProviderServer.RetrievalPolicy
+	retrievalPeers, err := peerutil.NewPolicyStrings(rpCfg.Peers.Allow, rpCfg.Peers.Except)
+	if err != nil {
+		return fmt.Errorf("bad retrieval policy in config: %w", err)
+	}
+	retrievalPolicy := cardatatransfer.NewRetrievalPolicy(cardatatransfer.RetrievalLimits{
+		Peers:                    retrievalPeers,
+		MaxTransfersPerPeer:      rpCfg.MaxTransfersPerPeer,
+		MaxBytesPerSecondPerPeer: rpCfg.MaxBytesPerSecondPerPeer,
+	})
+
 	p2pmaddr, err := multiaddr.NewMultiaddr(cfg.ProviderServer.ListenMultiaddr)
 	if err != nil {
 		return fmt.Errorf("bad p2p address in config %s: %s", cfg.ProviderServer.ListenMultiaddr, err)
@@ -112,19 +133,23 @@ func daemonCommand(cctx *cli.Context) error {
 	}
 	log.Infow("libp2p host initialized", "host_id", h.ID(), "multiaddr", p2pmaddr)
 
-	// Initialize datastore
-	if cfg.Datastore.Type != "levelds" {
-		return fmt.Errorf("only levelds datastore type supported, %q not supported", cfg.Datastore.Type)
-	}
-	dataStorePath, err := config.Path("", cfg.Datastore.Dir)
-	if err != nil {
-		return err
-	}
-	err = dirWritable(dataStorePath)
-	if err != nil {
-		return err
+	var metricsSrv *metrics.Server
+	if cfg.Metrics.ListenMultiaddr != "" {
+		metricsAddr, err := cfg.Metrics.ListenNetAddr()
+		if err != nil {
+			return err
+		}
+		metricsSrv, err = metrics.NewServer(metricsAddr)
+		if err != nil {
+			return err
+		}
+		if err = metricsSrv.Start(); err != nil {
+			return err
+		}
 	}
-	ds, err := leveldb.NewDatastore(dataStorePath, nil)
+
+	// Initialize datastore
+	ds, err := openDatastore(cfg.Datastore)
 	if err != nil {
 		return err
 	}
@@ -142,26 +167,42 @@ func daemonCommand(cctx *cli.Context) error {
 		return err
 	}
 
-	httpListenAddr, err := cfg.Ingest.HttpPublisher.ListenNetAddr()
-	if err != nil {
-		return err
+	// Retrieval servers run alongside graphsync are advertised with the
+	// content of imported CARs.
+	var retrievalProtocols []metadata.Protocol
+	retrievalAddrs := cfg.ProviderServer.RetrievalMultiaddrs
+	gatewayEnabled := cfg.GatewayServer.ListenMultiaddr != ""
+	if gatewayEnabled {
+		retrievalProtocols = append(retrievalProtocols, &metadata.IpfsGatewayHttp{})
+		// Advertise the gateway address in addition to the libp2p retrieval
+		// addresses.
+		if len(retrievalAddrs) == 0 {
+			for _, a := range h.Addrs() {
+				retrievalAddrs = append(retrievalAddrs, a.String())
+			}
+		} else {
+			retrievalAddrs = append([]string{}, retrievalAddrs...)
+		}
+		retrievalAddrs = append(retrievalAddrs, cfg.GatewayServer.RetrievalMultiaddr())
 	}
+	if cfg.ProviderServer.Bitswap {
+		retrievalProtocols = append(retrievalProtocols, &metadata.Bitswap{})
+	}
+
+	// The find server and retrieval servers locate content with the reverse
+	// index.
+	reverseIndex := cfg.Ingest.ReverseIndex || cfg.FindServer.ListenMultiaddr != "" ||
+		gatewayEnabled || cfg.ProviderServer.Bitswap
 
 	// Starting provider core
-	eng, err := engine.New(
-		engine.WithDatastore(ds),
-		engine.WithDirectAnnounce(cfg.DirectAnnounce.URLs...),
+	engOpts, err := engineOptions(cfg, syncPolicy, retrievalAddrs, reverseIndex)
+	if err != nil {
+		return err
+	}
+	eng, err := engine.New(append([]engine.Option{
+		engine.WithDatastore(chainDatastore(ds, cfg.Datastore.ChainNamespace)),
 		engine.WithHost(h),
-		engine.WithEntriesCacheCapacity(cfg.Ingest.LinkCacheSize),
-		engine.WithChainedEntries(cfg.Ingest.LinkedChunkSize),
-		engine.WithTopicName(cfg.Ingest.PubSubTopic),
-		engine.WithPublisherKind(engine.PublisherKind(cfg.Ingest.PublisherKind)),
-		engine.WithHttpPublisherListenAddr(httpListenAddr),
-		engine.WithHttpPublisherAnnounceAddr(cfg.Ingest.HttpPublisher.AnnounceMultiaddr),
-		engine.WithPubsubAnnounce(!cfg.DirectAnnounce.NoPubsubAnnounce),
-		engine.WithSyncPolicy(syncPolicy),
-		engine.WithRetrievalAddrs(cfg.ProviderServer.RetrievalMultiaddrs...),
-	)
+	}, engOpts...)...)
 	if err != nil {
 		return err
 	}
@@ -171,14 +212,74 @@ func daemonCommand(cctx *cli.Context) error {
 		return err
 	}
 
+	// Subscribe webhooks before anything is published.
+	var notifier *webhook.Notifier
+	if len(cfg.Webhooks.URLs) != 0 {
+		whOpts, err := cfg.Webhooks.Options("")
+		if err != nil {
+			return err
+		}
+		notifier, err = webhook.New(eng, cfg.Webhooks.URLs, whOpts...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = publishExtendedProviders(ctx, eng, cfg.ExtendedProviders); err != nil {
+		return err
+	}
+
 	// Instantiate CAR supplier and register it as the multihash lister onto the engine.
 	cs := supplier.NewCarSupplier(eng, ds, car.ZeroLengthSectionAsEOF(carZeroLengthAsEOFFlagValue))
 
-	// Start serving CAR files for retrieval requests
-	err = cardatatransfer.StartCarDataTransfer(dt, cs)
+	// Start serving CAR files for retrieval requests, sharing open CARs
+	// between retrievals.
+	bsPool := stores.NewBlockstorePool(cs, cfg.ProviderServer.MaxOpenBlockstores)
+	defer bsPool.Close()
+	cs.RegisterInvalidator(bsPool)
+	err = cardatatransfer.StartCarDataTransfer(dt, bsPool, cardatatransfer.WithRetrievalPolicy(retrievalPolicy))
 	if err != nil {
 		return err
 	}
+	var bitswapSrv *carbitswap.Server
+	if cfg.ProviderServer.Bitswap {
+		bitswapSrv = carbitswap.New(ctx, h, bsPool, eng)
+	}
+
+	// Index the CARs imported before the reverse index was enabled in the
+	// background, so that they can be found and retrieved.
+	indexCtx, cancelIndex := context.WithCancel(ctx)
+	defer cancelIndex()
+	indexDone := make(chan struct{})
+	if reverseIndex {
+		go func() {
+			defer close(indexDone)
+			n, err := cs.IndexContexts(indexCtx, eng)
+			if err != nil {
+				log.Errorw("Failed to add imported CARs to reverse index", "err", err)
+				return
+			}
+			log.Infow("Imported CARs added to reverse index", "count", n)
+		}()
+	} else {
+		close(indexDone)
+	}
+
+	// Continue any identity rotation in the background, while the current
+	// identity keeps serving its advertisement chain.
+	rotateCtx, cancelRotate := context.WithCancel(ctx)
+	defer cancelRotate()
+	rotateDone := make(chan struct{})
+	if cfg.Identity.Rotation != nil {
+		go func() {
+			defer close(rotateDone)
+			if err := rotateIdentity(rotateCtx, cfg, eng, ds, cs.ListMultihashes, h.Addrs(), engOpts); err != nil {
+				log.Errorw("Failed to rotate provider identity; will resume on next start", "err", err)
+			}
+		}()
+	} else {
+		close(rotateDone)
+	}
 
 	// TODO: unclear why the admin config takes multiaddr if it is always converted to net addr; simplify.
 	addr, err := cfg.AdminServer.ListenNetAddr()
@@ -186,22 +287,50 @@ func daemonCommand(cctx *cli.Context) error {
 		return err
 	}
 
-	adminSvr, err := adminserver.New(
-		h,
-		eng,
-		cs,
+	adminTLSConfig, err := cfg.AdminServer.ServerTLSConfig()
+	if err != nil {
+		return err
+	}
+	adminOpts := []adminserver.Option{
 		adminserver.WithListenAddr(addr),
 		adminserver.WithReadTimeout(time.Duration(cfg.AdminServer.ReadTimeout)),
 		adminserver.WithWriteTimeout(time.Duration(cfg.AdminServer.WriteTimeout)),
-	)
+		adminserver.WithRetrievalProtocols(retrievalProtocols...),
+		adminserver.WithRetrievalPolicy(retrievalPolicy),
+		adminserver.WithTLSConfig(adminTLSConfig),
+	}
+	adminAddr := cfg.AdminServer.ListenMultiaddr
+	adminSocket, adminSocketMode, err := cfg.AdminServer.UnixSocket()
+	if err != nil {
+		return err
+	}
+	if adminSocket != "" {
+		adminOpts = append(adminOpts, adminserver.WithUnixSocket(adminSocket, adminSocketMode))
+		adminAddr = "unix:" + adminSocket
+	}
+	for _, token := range cfg.AdminServer.Tokens {
+		adminOpts = append(adminOpts, adminserver.WithBearerToken(token.Token, token.ReadOnly))
+	}
+	if cfg.ExtendedProviders.KeysDir != "" {
+		keysDir, err := config.Path("", cfg.ExtendedProviders.KeysDir)
+		if err != nil {
+			return err
+		}
+		adminOpts = append(adminOpts, adminserver.WithExtendedProviderKeysDir(keysDir, config.LoadPrivKey))
+	}
+	if len(cfg.AdminServer.Tokens) == 0 && cfg.AdminServer.ClientCAPath == "" && adminSocket == "" {
+		log.Warn("Admin server does not authenticate requests; configure AdminServer.Tokens or mutual TLS unless it is only reachable by trusted clients")
+	}
+
+	adminSvr, err := adminserver.New(h, eng, cs, adminOpts...)
 
 	if err != nil {
 		return err
 	}
-	log.Infow("admin server initialized", "address", cfg.AdminServer.ListenMultiaddr)
+	log.Infow("admin server initialized", "address", adminAddr)
 
 	adminErrChan := make(chan error, 1)
-	fmt.Fprintf(cctx.App.ErrWriter, "Starting admin server on %s ...", cfg.AdminServer.ListenMultiaddr)
+	fmt.Fprintf(cctx.App.ErrWriter, "Starting admin server on %s ...", adminAddr)
 	go func() {
 		adminErrChan <- adminSvr.Start()
 	}()
@@ -259,6 +388,55 @@ func daemonCommand(cctx *cli.Context) error {
 		}()
 	}
 
+	findErrChan := make(chan error, 1)
+	var findSrv *findserver.Server
+	if cfg.FindServer.ListenMultiaddr != "" {
+		findAddr, err := cfg.FindServer.ListenNetAddr()
+		if err != nil {
+			return err
+		}
+		findSrv, err = findserver.New(
+			eng,
+			findserver.WithListenAddr(findAddr),
+			findserver.WithReadTimeout(time.Duration(cfg.FindServer.ReadTimeout)),
+			findserver.WithWriteTimeout(time.Duration(cfg.FindServer.WriteTimeout)),
+		)
+		if err != nil {
+			return err
+		}
+		log.Infow("find server initialized", "address", cfg.FindServer.ListenMultiaddr)
+
+		fmt.Fprintf(cctx.App.ErrWriter, "Starting find server on %s ...", cfg.FindServer.ListenMultiaddr)
+		go func() {
+			findErrChan <- findSrv.Start()
+		}()
+	}
+
+	gatewayErrChan := make(chan error, 1)
+	var gatewaySrv *cargateway.Server
+	if gatewayEnabled {
+		gatewayAddr, err := cfg.GatewayServer.ListenNetAddr()
+		if err != nil {
+			return err
+		}
+		gatewaySrv, err = cargateway.New(
+			eng,
+			bsPool,
+			cargateway.WithListenAddr(gatewayAddr),
+			cargateway.WithReadTimeout(time.Duration(cfg.GatewayServer.ReadTimeout)),
+			cargateway.WithWriteTimeout(time.Duration(cfg.GatewayServer.WriteTimeout)),
+		)
+		if err != nil {
+			return err
+		}
+		log.Infow("gateway server initialized", "address", cfg.GatewayServer.ListenMultiaddr)
+
+		fmt.Fprintf(cctx.App.ErrWriter, "Starting gateway server on %s ...", cfg.GatewayServer.ListenMultiaddr)
+		go func() {
+			gatewayErrChan <- gatewaySrv.Start()
+		}()
+	}
+
 	var finalErr error
 	// Keep process running.
 	select {
@@ -269,6 +447,12 @@ func daemonCommand(cctx *cli.Context) error {
 	case err = <-droutingErrChan:
 		log.Errorw("Failed to start delegated routing server", "err", err)
 		finalErr = ErrDaemonStart
+	case err = <-findErrChan:
+		log.Errorw("Failed to start find server", "err", err)
+		finalErr = ErrDaemonStart
+	case err = <-gatewayErrChan:
+		log.Errorw("Failed to start gateway server", "err", err)
+		finalErr = ErrDaemonStart
 	}
 
 	log.Infow("Shutting down daemon")
@@ -284,6 +468,25 @@ func daemonCommand(cctx *cli.Context) error {
 		}
 	}()
 
+	cancelRotate()
+	<-rotateDone
+	cancelIndex()
+	<-indexDone
+
+	if notifier != nil {
+		if err = notifier.Close(); err != nil {
+			log.Errorw("Error closing webhook notifier", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+
+	if bitswapSrv != nil {
+		if err = bitswapSrv.Close(); err != nil {
+			log.Errorw("Error closing bitswap server", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+
 	if err = eng.Shutdown(); err != nil {
 		log.Errorf("Error closing provider core: %s", err)
 		finalErr = ErrDaemonStop
@@ -307,10 +510,90 @@ func daemonCommand(cctx *cli.Context) error {
 			finalErr = ErrDaemonStop
 		}
 	}
+	if findSrv != nil {
+		if err = findSrv.Shutdown(shutdownCtx); err != nil {
+			log.Errorw("Error shutting down find server", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+	if gatewaySrv != nil {
+		if err = gatewaySrv.Shutdown(shutdownCtx); err != nil {
+			log.Errorw("Error shutting down gateway server", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+	if metricsSrv != nil {
+		if err = metricsSrv.Shutdown(shutdownCtx); err != nil {
+			log.Errorw("Error shutting down metrics server", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
 	log.Infow("node stopped")
 	return finalErr
 }
 
+// engineOptions returns the engine options that do not depend on the provider
+// identity. Both the daemon engine and the engine of an identity being rotated
+// to are built with them, so that they advertise and index content alike.
+func engineOptions(cfg *config.Config, syncPolicy *policy.Policy, retrievalAddrs []string, reverseIndex bool) ([]engine.Option, error) {
+	httpListenAddr, err := cfg.Ingest.HttpPublisher.ListenNetAddr()
+	if err != nil {
+		return nil, err
+	}
+	staticPublisherDir, err := config.Path("", cfg.Ingest.StaticPublisher.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return []engine.Option{
+		engine.WithDirectAnnounce(cfg.DirectAnnounce.URLs...),
+		engine.WithEntriesCacheCapacity(cfg.Ingest.LinkCacheSize),
+		engine.WithChainedEntries(cfg.Ingest.LinkedChunkSize),
+		engine.WithTopicName(cfg.Ingest.PubSubTopic),
+		engine.WithPublisherKind(engine.PublisherKind(cfg.Ingest.PublisherKind)),
+		engine.WithHttpPublisherListenAddr(httpListenAddr),
+		engine.WithHttpPublisherAnnounceAddr(cfg.Ingest.HttpPublisher.AnnounceMultiaddr),
+		engine.WithStaticPublisherDir(staticPublisherDir),
+		engine.WithExtendedProviderKeyLoader(func(id peer.ID) (crypto.PrivKey, error) {
+			return cfg.ExtendedProviders.LoadKey("", id)
+		}),
+		engine.WithPubsubAnnounce(!cfg.DirectAnnounce.NoPubsubAnnounce),
+		engine.WithSyncPolicy(syncPolicy),
+		engine.WithRetrievalAddrs(retrievalAddrs...),
+		engine.WithReverseIndex(reverseIndex),
+	}, nil
+}
+
+// publishExtendedProviders publishes the configured extended providers, unless
+// none are configured or the same set is already advertised.
+func publishExtendedProviders(ctx context.Context, eng *engine.Engine, xpCfg config.ExtendedProviders) error {
+	if len(xpCfg.Providers) == 0 {
+		return nil
+	}
+	infos, err := xpCfg.Infos("")
+	if err != nil {
+		return err
+	}
+	contextID, err := xpCfg.DecodeContextID()
+	if err != nil {
+		return err
+	}
+	md, err := xpCfg.DecodeMetadata()
+	if err != nil {
+		return err
+	}
+
+	adCid, err := eng.NotifyExtendedProviders(ctx, contextID, md, xpCfg.Override, infos...)
+	if err != nil {
+		if errors.Is(err, provider.ErrAlreadyAdvertised) {
+			log.Info("Configured extended providers are already advertised")
+			return nil
+		}
+		return fmt.Errorf("cannot publish extended providers: %w", err)
+	}
+	log.Infow("Published extended providers", "count", len(infos), "adCid", adCid)
+	return nil
+}
+
 // dirWritable checks if a directory is writable. If the directory does
 // not exist it is created with writable permission.
 func dirWritable(dir string) error {
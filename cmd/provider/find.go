@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var FindCmd = &cli.Command{
+	Name:  "find",
+	Usage: "Finds the context IDs, and CAR files, that advertise a CID or multihash.",
+	Description: `Looks up the CID or multihash in the reverse index of the provider daemon, and
+lists each provider and context ID currently advertising it, along with the path
+of the CAR file imported with the context ID, if any.
+
+The reverse index must be enabled by setting Ingest.ReverseIndex in the config
+file. It covers the contexts advertised while the index is enabled, and the CAR
+files imported earlier, which are indexed in the background when the daemon
+starts. Contexts removed before the index was enabled are not covered.`,
+	Action: doFind,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		adminTokenFlag,
+		adminTLSCAFlag,
+		adminTLSCertFlag,
+		adminTLSKeyFlag,
+		&cli.StringFlag{
+			Name:  "cid",
+			Usage: "CID to find.",
+		},
+		&cli.StringFlag{
+			Name:    "multihash",
+			Usage:   "Base58 encoded multihash to find.",
+			Aliases: []string{"mh"},
+		},
+	},
+}
+
+func doFind(cctx *cli.Context) error {
+	query := url.Values{}
+	switch {
+	case cctx.String("cid") != "" && cctx.String("multihash") != "":
+		return errors.New("only one of --cid or --multihash may be specified")
+	case cctx.String("cid") != "":
+		query.Set("cid", cctx.String("cid"))
+	case cctx.String("multihash") != "":
+		query.Set("multihash", cctx.String("multihash"))
+	default:
+		return errors.New("--cid or --multihash must be specified")
+	}
+
+	resp, err := doHttpGetReq(cctx.Context, adminAPIFlagValue+"/admin/find?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.FindRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	if len(res.Contexts) == 0 {
+		return errors.New("not advertised")
+	}
+	var b bytes.Buffer
+	for _, found := range res.Contexts {
+		b.WriteString("Context ID: ")
+		b.WriteString(base64.StdEncoding.EncodeToString(found.ContextID))
+		b.WriteString("\n\t Provider: ")
+		b.WriteString(found.Provider)
+		b.WriteString("\n")
+		if found.Path != "" {
+			b.WriteString("\t CAR: ")
+			b.WriteString(found.Path)
+			b.WriteString("\n")
+		}
+	}
+	_, err = cctx.App.Writer.Write(b.Bytes())
+	return err
+}
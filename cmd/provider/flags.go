@@ -71,4 +71,38 @@ var (
 		Value:       "http://localhost:3102",
 		Destination: &adminAPIFlagValue,
 	}
+	// The admin client flags override the AdminServer section of the local
+	// provider config, if any, when sending requests to the admin API.
+	adminTokenFlagValue string
+	adminTokenFlag      = &cli.StringFlag{
+		Name:        "admin-token",
+		Usage:       "Bearer token sent to the admin API",
+		EnvVars:     []string{"PROVIDER_ADMIN_TOKEN"},
+		DefaultText: "The first token in the AdminServer config that is not read-only",
+		Destination: &adminTokenFlagValue,
+	}
+	adminTLSCAFlagValue string
+	adminTLSCAFlag      = &cli.StringFlag{
+		Name:        "admin-tls-ca",
+		Usage:       "PEM encoded certificate authorities trusted to serve the admin API over HTTPS, in addition to the system ones",
+		EnvVars:     []string{"PROVIDER_ADMIN_TLS_CA"},
+		DefaultText: "TLSCertPath in the AdminServer config",
+		Destination: &adminTLSCAFlagValue,
+	}
+	adminTLSCertFlagValue string
+	adminTLSCertFlag      = &cli.StringFlag{
+		Name:        "admin-tls-cert",
+		Usage:       "PEM encoded client certificate presented to the admin API over HTTPS",
+		EnvVars:     []string{"PROVIDER_ADMIN_TLS_CERT"},
+		DefaultText: "ClientCertPath in the AdminServer config",
+		Destination: &adminTLSCertFlagValue,
+	}
+	adminTLSKeyFlagValue string
+	adminTLSKeyFlag      = &cli.StringFlag{
+		Name:        "admin-tls-key",
+		Usage:       "PEM encoded key of the client certificate presented to the admin API",
+		EnvVars:     []string{"PROVIDER_ADMIN_TLS_KEY"},
+		DefaultText: "ClientKeyPath in the AdminServer config",
+		Destination: &adminTLSKeyFlagValue,
+	}
 )
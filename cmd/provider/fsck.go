@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/urfave/cli/v2"
+)
+
+var FsckCmd = &cli.Command{
+	Name:  "fsck",
+	Usage: "Checks the consistency of the provider datastore with its advertisement chain.",
+	Description: `Walks the advertisement chain from its head, verifying the links and signature
+of each advertisement, and cross-checks the context ID mappings kept by the
+provider against the latest advertisement for each context ID. Mappings that
+are missing, disagree with the chain, or belong to context IDs that are no
+longer advertised are reported, and repaired if --repair is set.
+
+The daemon must not be running.`,
+	Action: fsckCommand,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "repair",
+			Usage: "Repair the mappings that are inconsistent with the advertisement chain.",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "Print each problem found.",
+		},
+	},
+}
+
+func fsckCommand(cctx *cli.Context) error {
+	cfg, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	ds, err := openDatastore(cfg.Datastore)
+	if err != nil {
+		return err
+	}
+	defer ds.Close()
+
+	eng, err := newOfflineEngine(cfg, ds)
+	if err != nil {
+		return err
+	}
+	report, err := eng.Verify(cctx.Context, cctx.Bool("repair"))
+	if err != nil {
+		return err
+	}
+
+	w := cctx.App.Writer
+	if report.Head == cid.Undef {
+		fmt.Fprintln(w, "Head: none")
+	} else {
+		fmt.Fprintln(w, "Head:", report.Head)
+	}
+	fmt.Fprintln(w, "Advertisements:", report.Ads)
+	fmt.Fprintln(w, "Advertised context IDs:", report.ContextIDs)
+	if cctx.Bool("verbose") {
+		for _, p := range report.Problems {
+			fmt.Fprintln(w, "\t", p)
+		}
+	}
+	fmt.Fprintln(w, "Problems:", len(report.Problems))
+	if cctx.Bool("repair") {
+		fmt.Fprintln(w, "Repaired:", report.Repaired())
+	}
+	if unrepaired := len(report.Problems) - report.Repaired(); unrepaired != 0 {
+		return fmt.Errorf("%d problems not repaired", unrepaired)
+	}
+	return nil
+}
+
+// openDatastore opens the provider datastore. Only one process at a time may
+// open the datastore.
+func openDatastore(dsCfg config.Datastore) (datastore.Batching, error) {
+	if dsCfg.Type != "levelds" {
+		return nil, fmt.Errorf("only levelds datastore type supported, %q not supported", dsCfg.Type)
+	}
+	dataStorePath, err := config.Path("", dsCfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = dirWritable(dataStorePath); err != nil {
+		return nil, err
+	}
+	return leveldb.NewDatastore(dataStorePath, nil)
+}
+
+// newOfflineEngine creates an engine over the advertisement chain in the
+// datastore, for use while the daemon is not running. The engine is not
+// started, so does not publish or announce.
+func newOfflineEngine(cfg *config.Config, ds datastore.Batching) (*engine.Engine, error) {
+	peerID, privKey, err := cfg.Identity.DecodeOrCreate(io.Discard)
+	if err != nil {
+		return nil, err
+	}
+	addrs := cfg.ProviderServer.RetrievalMultiaddrs
+	if len(addrs) == 0 {
+		addrs = []string{cfg.ProviderServer.ListenMultiaddr}
+	}
+	maddrs := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		ma, err := multiaddr.NewMultiaddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("bad provider address %q: %w", a, err)
+		}
+		maddrs = append(maddrs, ma)
+	}
+	return engine.New(
+		engine.WithDatastore(chainDatastore(ds, cfg.Datastore.ChainNamespace)),
+		engine.WithPrivateKey(privKey),
+		engine.WithProvider(peer.AddrInfo{ID: peerID, Addrs: maddrs}),
+	)
+}
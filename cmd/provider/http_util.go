@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
 )
 
 // doHttpPostReq marshals the req to JSON and sends a POST request with content type
@@ -24,8 +30,122 @@ func doHttpPostReq(ctx context.Context, path string, req interface{}) (resp *htt
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	return doHttpReq(httpReq)
+}
+
+// doHttpGetReq sends a GET request to the given path.
+//
+// This function is intended for internal use in CLI to interact with the admin server.
+func doHttpGetReq(ctx context.Context, path string) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doHttpReq(httpReq)
+}
+
+// doHttpReq sends the request. If the request is sent to the admin API given
+// by the listen-admin flag, the request is sent as configured by the admin
+// client flags, falling back on the admin server config in the local provider
+// config, if any: through its Unix domain socket, and with its credentials.
+func doHttpReq(req *http.Request) (*http.Response, error) {
 	cl := &http.Client{}
-	return cl.Do(httpReq)
+	if isAdminAPIReq(req) {
+		if err := configureAdminReq(req, cl); err != nil {
+			return nil, err
+		}
+	}
+	return cl.Do(req)
+}
+
+func isAdminAPIReq(req *http.Request) bool {
+	if adminAPIFlagValue == "" {
+		return false
+	}
+	adminURL, err := url.Parse(adminAPIFlagValue)
+	if err != nil {
+		return false
+	}
+	return req.URL.Host == adminURL.Host
+}
+
+// adminServerConfig returns the admin server config of the local provider
+// config, overridden by the admin client flags. A missing provider config is
+// not an error, since the CLI may be used against a remote admin server.
+func adminServerConfig() (*config.AdminServer, error) {
+	as := config.NewAdminServer()
+	cfg, err := config.Load("")
+	if err == nil {
+		as = cfg.AdminServer
+	} else if !errors.Is(err, config.ErrNotInitialized) {
+		return nil, fmt.Errorf("cannot load admin server config: %w", err)
+	}
+
+	if adminTokenFlagValue != "" {
+		as.Tokens = []config.AdminToken{{Token: adminTokenFlagValue}}
+	}
+	// Paths given by flags are relative to the working directory, rather than
+	// to the config directory.
+	if adminTLSCAFlagValue != "" {
+		if as.TLSCertPath, err = filepath.Abs(adminTLSCAFlagValue); err != nil {
+			return nil, err
+		}
+	}
+	if adminTLSCertFlagValue != "" {
+		if as.ClientCertPath, err = filepath.Abs(adminTLSCertFlagValue); err != nil {
+			return nil, err
+		}
+	}
+	if adminTLSKeyFlagValue != "" {
+		if as.ClientKeyPath, err = filepath.Abs(adminTLSKeyFlagValue); err != nil {
+			return nil, err
+		}
+	}
+	return &as, nil
+}
+
+// configureAdminReq sets up the request and the client to reach the admin
+// server as configured.
+func configureAdminReq(req *http.Request, cl *http.Client) error {
+	as, err := adminServerConfig()
+	if err != nil {
+		return err
+	}
+	if token := as.ClientToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	tlsConfig, err := as.ClientTLSConfig()
+	if err != nil {
+		return err
+	}
+	socketPath, _, err := as.UnixSocket()
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil && socketPath == "" {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		// The admin server only serves HTTPS when TLS is configured.
+		if req.URL.Scheme == "http" {
+			req.URL.Scheme = "https"
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	if socketPath != "" {
+		// The admin server does not listen on TCP when it listens on a Unix
+		// socket, so the host of the admin API URL only names the server,
+		// e.g. for TLS verification.
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		}
+	}
+	cl.Transport = transport
+	return nil
 }
 
 // errFromHttpResp constructs an error from a HTTP response.
@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/pem"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -36,6 +42,135 @@ func Test_doHttpPostReq_FailsToPostWhenServerIsNotAvailable(t *testing.T) {
 	require.Error(t, err)
 }
 
+func Test_doHttpReq_SendsConfiguredAdminCredentials(t *testing.T) {
+	var gotAuthz string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, httpReq *http.Request) {
+		gotAuthz = httpReq.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	configRoot := t.TempDir()
+	t.Setenv(config.EnvDir, configRoot)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(filepath.Join(configRoot, "admin.crt"), certPEM, 0600))
+	cfg := config.Config{
+		AdminServer: config.AdminServer{
+			Tokens: []config.AdminToken{
+				{Token: "fish", ReadOnly: true},
+				{Token: "lobster"},
+			},
+			TLSCertPath: "admin.crt",
+		},
+	}
+	require.NoError(t, cfg.Save(""))
+
+	// The admin API is given as plain HTTP, and upgraded to HTTPS since TLS is
+	// configured.
+	adminURL := strings.Replace(server.URL, "https://", "http://", 1)
+	prevAdminAPI := adminAPIFlagValue
+	adminAPIFlagValue = adminURL
+	t.Cleanup(func() { adminAPIFlagValue = prevAdminAPI })
+
+	resp, err := doHttpPostReq(context.Background(), adminURL+"/admin/connect", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Bearer lobster", gotAuthz)
+
+	// Credentials are not sent to other servers.
+	other := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, httpReq *http.Request) {
+		gotAuthz = httpReq.Header.Get("Authorization")
+	}))
+	defer other.Close()
+	resp, err = doHttpGetReq(context.Background(), other.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Empty(t, gotAuthz)
+}
+
+func Test_doHttpReq_AdminFlagsOverrideConfig(t *testing.T) {
+	var gotAuthz string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(_ http.ResponseWriter, httpReq *http.Request) {
+		gotAuthz = httpReq.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	configRoot := t.TempDir()
+	t.Setenv(config.EnvDir, configRoot)
+	certPath := filepath.Join(t.TempDir(), "admin.crt")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(certPath, certPEM, 0600))
+
+	adminURL := strings.Replace(server.URL, "https://", "http://", 1)
+	prevAdminAPI := adminAPIFlagValue
+	adminAPIFlagValue = adminURL
+	t.Cleanup(func() { adminAPIFlagValue = prevAdminAPI })
+	prevToken, prevCA := adminTokenFlagValue, adminTLSCAFlagValue
+	adminTokenFlagValue = "crab"
+	adminTLSCAFlagValue = certPath
+	t.Cleanup(func() { adminTokenFlagValue, adminTLSCAFlagValue = prevToken, prevCA })
+
+	// Without a provider config, the credentials are taken from the flags.
+	resp, err := doHttpPostReq(context.Background(), adminURL+"/admin/connect", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "Bearer crab", gotAuthz)
+
+	// The flags take precedence over the provider config.
+	cfg := config.Config{
+		AdminServer: config.AdminServer{
+			Tokens:      []config.AdminToken{{Token: "lobster"}},
+			TLSCertPath: "missing.crt",
+		},
+	}
+	require.NoError(t, cfg.Save(""))
+	resp, err = doHttpPostReq(context.Background(), adminURL+"/admin/connect", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "Bearer crab", gotAuthz)
+
+	// The provider config is the default.
+	adminTokenFlagValue = ""
+	adminTLSCAFlagValue = ""
+	_, err = doHttpPostReq(context.Background(), adminURL+"/admin/connect", nil)
+	require.ErrorContains(t, err, "cannot load admin server certificate")
+}
+
+func Test_doHttpPostReq_ConnectsThroughConfiguredUnixSocket(t *testing.T) {
+	configRoot := t.TempDir()
+	t.Setenv(config.EnvDir, configRoot)
+	cfg := config.Config{
+		AdminServer: config.AdminServer{
+			UnixSocketPath: "admin.sock",
+		},
+	}
+	require.NoError(t, cfg.Save(""))
+
+	l, err := net.Listen("unix", filepath.Join(configRoot, "admin.sock"))
+	require.NoError(t, err)
+	var gotPath string
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(_ http.ResponseWriter, httpReq *http.Request) {
+			gotPath = httpReq.URL.Path
+		}),
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	// No server listens on the TCP address of the admin API.
+	const adminURL = "http://localhost:47891"
+	prevAdminAPI := adminAPIFlagValue
+	adminAPIFlagValue = adminURL
+	t.Cleanup(func() { adminAPIFlagValue = prevAdminAPI })
+
+	resp, err := doHttpPostReq(context.Background(), adminURL+"/admin/import/car", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "/admin/import/car", gotPath)
+}
+
 func Test_errFromHttpResp(t *testing.T) {
 	r := httptest.NewRecorder()
 	_, err := r.WriteString("fish")
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+)
+
+var IdentityCmd = &cli.Command{
+	Name:  "identity",
+	Usage: "Manages the provider identity key.",
+	Description: `The provider identity private key is stored either in the config file or in
+a separate keystore file encrypted with a passphrase. The keystore passphrase is
+read from the file given by --passphrase-file, or else from the
+` + config.KeystorePassphraseEnvVar + ` or ` + config.KeystorePassphraseFileEnvVar + ` env var.
+
+The daemon must be restarted for changes to take effect.`,
+	Subcommands: []*cli.Command{
+		passwdIdentitySubCmd,
+		exportIdentitySubCmd,
+		importIdentitySubCmd,
+		rotateIdentitySubCmd,
+	},
+}
+
+var errRotationPending = errors.New("identity rotation is pending; start the daemon to complete it")
+
+var passphraseFileFlag = &cli.StringFlag{
+	Name:  "passphrase-file",
+	Usage: "Path to the file containing the keystore passphrase.",
+}
+
+var passwdIdentitySubCmd = &cli.Command{
+	Name:  "passwd",
+	Usage: "Changes the passphrase of the identity keystore.",
+	Description: `If the identity private key is stored in the config file, then it is moved to
+an encrypted keystore file, named by --keystore, and removed from the config file.`,
+	Action: doPasswdIdentity,
+	Flags: []cli.Flag{
+		passphraseFileFlag,
+		&cli.StringFlag{
+			Name:     "new-passphrase-file",
+			Usage:    "Path to the file containing the new keystore passphrase.",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "keystore",
+			Usage: "Path of the keystore file to create, if the private key is stored in the config file. The path is relative to the config root.",
+			Value: config.DefaultKeystoreFile,
+		},
+	},
+}
+
+var exportIdentitySubCmd = &cli.Command{
+	Name:   "export",
+	Usage:  "Exports the unencrypted identity private key to a file.",
+	Action: doExportIdentity,
+	Flags: []cli.Flag{
+		passphraseFileFlag,
+		&cli.StringFlag{
+			Name:     "output",
+			Usage:    "Path of the file to write the private key to. The file must not exist.",
+			Aliases:  []string{"o"},
+			Required: true,
+		},
+	},
+}
+
+var importIdentitySubCmd = &cli.Command{
+	Name:  "import",
+	Usage: "Imports the identity private key from a file.",
+	Description: `The imported private key replaces the current identity. If the identity is
+stored in a keystore, then the imported key is encrypted with the keystore
+passphrase.`,
+	Action: doImportIdentity,
+	Flags: []cli.Flag{
+		passphraseFileFlag,
+		&cli.StringFlag{
+			Name:     "input",
+			Usage:    "Path of the file containing the unencrypted private key, as written by export.",
+			Aliases:  []string{"i"},
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Import a key with a peer ID different from the current identity. This changes the provider ID.",
+		},
+	},
+}
+
+var rotateIdentitySubCmd = &cli.Command{
+	Name:  "rotate",
+	Usage: "Rotates the provider identity to a new key.",
+	Description: `Rotation changes the provider peer ID without orphaning the content advertised
+under the current identity. This command records the new identity in the
+config file; the rotation itself is done by the daemon when it next starts.
+
+The daemon keeps running under the current identity, and publishes from it
+either a chain level extended providers advertisement that points to the new
+identity (--mode xproviders) or a removal advertisement for each context ID
+(--mode remove). It then advertises all context IDs again under the new
+identity, on a new advertisement chain. Progress is saved, so an interrupted
+rotation resumes when the daemon restarts.
+
+Once the rotation is complete the config file is updated to use the new
+identity. Keep the daemon running long enough for indexers to sync the final
+advertisements of the current identity, then restart it and run the announce
+command so that indexers learn of the new chain.`,
+	Action: doRotateIdentity,
+	Flags: []cli.Flag{
+		passphraseFileFlag,
+		&cli.StringFlag{
+			Name:  "mode",
+			Usage: "How content is handed over to the new identity: 'xproviders' or 'remove'.",
+			Value: string(engine.RotateExtendedProviders),
+		},
+		&cli.StringFlag{
+			Name:  "new-key-file",
+			Usage: "Path of the file containing the unencrypted private key of the new identity. If not set, a new key is generated.",
+		},
+	},
+}
+
+// readPassphrase reads the keystore passphrase from the file named by the
+// flag, if set, or else from the environment.
+func readPassphrase(cctx *cli.Context, flagName string) ([]byte, error) {
+	if passFile := cctx.String(flagName); passFile != "" {
+		return config.ReadPassphraseFile(passFile)
+	}
+	return config.KeystorePassphrase()
+}
+
+func loadConfig() (*config.Config, string, error) {
+	configFile, err := config.Filename("")
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		if errors.Is(err, config.ErrNotInitialized) {
+			return nil, "", errors.New("reference provider is not initialized\nTo initialize, run using the \"init\" command")
+		}
+		return nil, "", fmt.Errorf("cannot load config file: %w", err)
+	}
+	return cfg, configFile, nil
+}
+
+// decodePrivKey decodes the private key of the configured identity, reading
+// the passphrase if the key is in a keystore.
+func decodePrivKey(cctx *cli.Context, identity config.Identity) (crypto.PrivKey, error) {
+	var passphrase []byte
+	if identity.KeystorePath != "" {
+		var err error
+		passphrase, err = readPassphrase(cctx, passphraseFileFlag.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return identity.DecodeOrCreatePrivateKey(cctx.App.Writer, string(passphrase))
+}
+
+func doPasswdIdentity(cctx *cli.Context) error {
+	cfg, configFile, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Identity.Rotation != nil {
+		return errRotationPending
+	}
+	privKey, err := decodePrivKey(cctx, cfg.Identity)
+	if err != nil {
+		return err
+	}
+	newPassphrase, err := config.ReadPassphraseFile(cctx.String("new-passphrase-file"))
+	if err != nil {
+		return err
+	}
+
+	if cfg.Identity.KeystorePath == "" {
+		cfg.Identity.KeystorePath = cctx.String("keystore")
+		ksFile, err := cfg.Identity.KeystoreFile()
+		if err != nil {
+			return err
+		}
+		if _, err = os.Stat(ksFile); !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("keystore file already exists: %s", ksFile)
+		}
+	}
+	if err = cfg.Identity.SetPrivKey(privKey, newPassphrase); err != nil {
+		return err
+	}
+	if err = cfg.Save(configFile); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(cctx.App.Writer, "Keystore passphrase changed.")
+	return err
+}
+
+func doExportIdentity(cctx *cli.Context) error {
+	cfg, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	privKey, err := decodePrivKey(cctx, cfg.Identity)
+	if err != nil {
+		return err
+	}
+	pkb, err := crypto.MarshalPrivateKey(privKey)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cctx.String("output"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(pkb); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	peerID, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cctx.App.Writer, "Exported private key of %s\n", peerID)
+	return err
+}
+
+func doImportIdentity(cctx *cli.Context) error {
+	cfg, configFile, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Identity.Rotation != nil {
+		return errRotationPending
+	}
+	privKey, err := config.LoadPrivKey(cctx.String("input"))
+	if err != nil {
+		return fmt.Errorf("cannot load private key: %w", err)
+	}
+	peerID, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return err
+	}
+	if cfg.Identity.PeerID != "" && cfg.Identity.PeerID != peerID.String() && !cctx.Bool("force") {
+		return fmt.Errorf("imported peer ID %s differs from current peer ID %s; use --force to change the provider ID", peerID, cfg.Identity.PeerID)
+	}
+
+	var passphrase []byte
+	if cfg.Identity.KeystorePath != "" {
+		passphrase, err = readPassphrase(cctx, passphraseFileFlag.Name)
+		if err != nil {
+			return err
+		}
+	}
+	if err = cfg.Identity.SetPrivKey(privKey, passphrase); err != nil {
+		return err
+	}
+	if err = cfg.Save(configFile); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cctx.App.Writer, "Imported private key of %s\n", peerID)
+	return err
+}
+
+func doRotateIdentity(cctx *cli.Context) error {
+	cfg, configFile, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Identity.Rotation != nil {
+		_, err = fmt.Fprintf(cctx.App.Writer, "Rotation to %s is pending; it continues when the daemon starts.\n", cfg.Identity.Rotation.Identity.PeerID)
+		return err
+	}
+	mode := engine.RotateMode(cctx.String("mode"))
+	switch mode {
+	case engine.RotateExtendedProviders, engine.RotateRemove:
+	default:
+		return fmt.Errorf("unknown rotation mode: %s", mode)
+	}
+
+	oldKey, err := decodePrivKey(cctx, cfg.Identity)
+	if err != nil {
+		return err
+	}
+	var newKey crypto.PrivKey
+	if keyFile := cctx.String("new-key-file"); keyFile != "" {
+		newKey, err = config.LoadPrivKey(keyFile)
+		if err != nil {
+			return fmt.Errorf("cannot load private key: %w", err)
+		}
+		if newKey.Equals(oldKey) {
+			return errors.New("new key must differ from the current key")
+		}
+	} else {
+		newKey, _, err = crypto.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			return err
+		}
+	}
+	newID, err := peer.IDFromPrivateKey(newKey)
+	if err != nil {
+		return err
+	}
+
+	var newIdentity config.Identity
+	var passphrase []byte
+	if cfg.Identity.KeystorePath != "" {
+		// Keep the new key encrypted with the same passphrase.
+		newIdentity.KeystorePath = cfg.Identity.KeystorePath + "-" + newID.String()
+		passphrase, err = readPassphrase(cctx, passphraseFileFlag.Name)
+		if err != nil {
+			return err
+		}
+	}
+	if err = newIdentity.SetPrivKey(newKey, passphrase); err != nil {
+		return err
+	}
+	cfg.Identity.Rotation = &config.IdentityRotation{
+		Identity: newIdentity,
+		Mode:     string(mode),
+	}
+	if err = cfg.Save(configFile); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cctx.App.Writer, "Rotation to %s recorded; it is performed when the daemon starts.\n", newID)
+	return err
+}
@@ -37,6 +37,10 @@ var (
 
 var importCarFlags = []cli.Flag{
 	adminAPIFlag,
+	adminTokenFlag,
+	adminTLSCAFlag,
+	adminTLSCertFlag,
+	adminTLSKeyFlag,
 	carPathFlag,
 	metadataFlag,
 	keyFlag,
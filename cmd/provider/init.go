@@ -20,9 +20,14 @@ var InitCmd = &cli.Command{
 var initFlags = []cli.Flag{
 	&cli.StringFlag{
 		Name:  "pubkind",
-		Usage: "Set publisher kind in config. Must be one of 'http', 'libp2p', 'libp2phttp'",
+		Usage: "Set publisher kind in config. Must be one of 'http', 'libp2p', 'libp2phttp', 'static'",
 		Value: "libp2p",
 	},
+	&cli.StringFlag{
+		Name:  "keystore",
+		Usage: "Store the identity private key in a keystore file, encrypted with a passphrase, instead of in the config file. The path is relative to the config root.",
+	},
+	passphraseFileFlag,
 }
 
 func initCommand(cctx *cli.Context) error {
@@ -57,11 +62,43 @@ func initCommand(cctx *cli.Context) error {
 	switch pubkind {
 	case "":
 		pubkind = config.Libp2pPublisherKind
-	case config.Libp2pPublisherKind, config.HttpPublisherKind, config.Libp2pHttpPublisherKind:
+	case config.Libp2pPublisherKind, config.HttpPublisherKind, config.Libp2pHttpPublisherKind, config.StaticPublisherKind:
 	default:
 		return fmt.Errorf("unknown publisher kind: %s", pubkind)
 	}
 	cfg.Ingest.PublisherKind = pubkind
 
+	if ksPath := cctx.String("keystore"); ksPath != "" {
+		if err = initKeystore(cctx, &cfg.Identity, ksPath); err != nil {
+			return err
+		}
+	}
+
 	return cfg.Save(configFile)
 }
+
+// initKeystore moves the private key of the identity into a new keystore file.
+func initKeystore(cctx *cli.Context, identity *config.Identity, ksPath string) error {
+	passphrase, err := readPassphrase(cctx, passphraseFileFlag.Name)
+	if err != nil {
+		return err
+	}
+	privKey, err := identity.DecodeOrCreatePrivateKey(cctx.App.Writer, "")
+	if err != nil {
+		return err
+	}
+	identity.PrivKey = ""
+	identity.KeystorePath = ksPath
+	ksFile, err := identity.KeystoreFile()
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(ksFile); !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("keystore file already exists: %s", ksFile)
+	}
+	if err = identity.SetPrivKey(privKey, passphrase); err != nil {
+		return err
+	}
+	fmt.Fprintln(cctx.App.Writer, "private key stored in keystore", ksFile)
+	return nil
+}
@@ -1,6 +1,13 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/multiformats/go-multiaddr"
@@ -11,6 +18,7 @@ const (
 	defaultAdminServerAddr = "/ip4/127.0.0.1/tcp/3102"
 	defaultReadTimeout     = Duration(30 * time.Second)
 	defaultWriteTimeout    = Duration(30 * time.Second)
+	defaultUnixSocketMode  = "0600"
 )
 
 type AdminServer struct {
@@ -18,6 +26,45 @@ type AdminServer struct {
 	ListenMultiaddr string
 	ReadTimeout     Duration
 	WriteTimeout    Duration
+	// UnixSocketPath, if set, is the path of a Unix domain socket that the
+	// admin server listens on instead of ListenMultiaddr, so that no network
+	// port is needed. The provider CLI commands then connect through the
+	// socket. A relative path is relative to the config directory.
+	UnixSocketPath string `json:",omitempty"`
+	// UnixSocketMode is the octal file mode of the Unix domain socket, which
+	// determines the local users that can connect to it. The default is
+	// "0600", i.e. only the user running the daemon.
+	UnixSocketMode string `json:",omitempty"`
+	// Tokens are the bearer tokens accepted by the admin server. If empty, the
+	// admin server does not require a token. The provider CLI commands send
+	// the first token that is not read-only, or else the first token.
+	Tokens []AdminToken `json:",omitempty"`
+	// TLSCertPath and TLSKeyPath are the paths to the PEM encoded certificate
+	// and key of the admin server. If set, the admin API is served over HTTPS,
+	// and the provider CLI commands trust this certificate in addition to the
+	// system certificate authorities. Relative paths are relative to the
+	// config directory.
+	TLSCertPath string `json:",omitempty"`
+	TLSKeyPath  string `json:",omitempty"`
+	// ClientCAPath is the path to the PEM encoded certificate authorities of
+	// the admin API clients. If set, clients must present a certificate signed
+	// by one of them (mutual TLS). Requires TLSCertPath and TLSKeyPath.
+	ClientCAPath string `json:",omitempty"`
+	// ClientCertPath and ClientKeyPath are the paths to the PEM encoded
+	// certificate and key that the provider CLI commands present to the admin
+	// server over HTTPS. Required when ClientCAPath is set.
+	ClientCertPath string `json:",omitempty"`
+	ClientKeyPath  string `json:",omitempty"`
+}
+
+// AdminToken is a bearer token accepted by the admin server.
+type AdminToken struct {
+	// Token is the secret sent by clients in the Authorization header, as
+	// "Bearer <Token>".
+	Token string
+	// ReadOnly restricts the token to the admin endpoints that do not change
+	// the state of the provider, such as listing CARs and finding content.
+	ReadOnly bool
 }
 
 // NewAdminServer instantiates a new AdminServer config with default values.
@@ -42,6 +89,135 @@ func (as *AdminServer) ListenNetAddr() (string, error) {
 	return netAddr.String(), nil
 }
 
+// UnixSocket returns the resolved path and the file mode of the Unix domain
+// socket of the admin server, or an empty path if it listens on TCP.
+func (as *AdminServer) UnixSocket() (string, fs.FileMode, error) {
+	if as.UnixSocketPath == "" {
+		return "", 0, nil
+	}
+	path, err := Path("", as.UnixSocketPath)
+	if err != nil {
+		return "", 0, err
+	}
+	modeStr := as.UnixSocketMode
+	if modeStr == "" {
+		modeStr = defaultUnixSocketMode
+	}
+	mode, err := strconv.ParseUint(modeStr, 8, 32)
+	if err != nil || mode&^uint64(fs.ModePerm) != 0 {
+		return "", 0, fmt.Errorf("invalid admin server UnixSocketMode %q: must be octal permission bits such as 0600", as.UnixSocketMode)
+	}
+	return path, fs.FileMode(mode), nil
+}
+
+// ClientToken returns the token sent by the provider CLI commands, or an empty
+// string if the admin server does not require a token.
+func (as *AdminServer) ClientToken() string {
+	for _, token := range as.Tokens {
+		if !token.ReadOnly {
+			return token.Token
+		}
+	}
+	if len(as.Tokens) != 0 {
+		return as.Tokens[0].Token
+	}
+	return ""
+}
+
+// ServerTLSConfig returns the TLS config of the admin server, or nil if the
+// admin API is served over plain HTTP.
+func (as *AdminServer) ServerTLSConfig() (*tls.Config, error) {
+	if as.TLSCertPath == "" && as.TLSKeyPath == "" {
+		if as.ClientCAPath != "" {
+			return nil, errors.New("admin server ClientCAPath requires TLSCertPath and TLSKeyPath")
+		}
+		return nil, nil
+	}
+	cert, err := loadX509KeyPair(as.TLSCertPath, as.TLSKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load admin server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if as.ClientCAPath != "" {
+		tlsConfig.ClientCAs, err = loadCertPool(nil, as.ClientCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load admin client certificate authorities: %w", err)
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// ClientTLSConfig returns the TLS config used by the provider CLI commands to
+// connect to the admin server, or nil if the admin API is served over plain
+// HTTP.
+func (as *AdminServer) ClientTLSConfig() (*tls.Config, error) {
+	if as.TLSCertPath == "" {
+		return nil, nil
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	roots, err = loadCertPool(roots, as.TLSCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load admin server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}
+	if as.ClientCAPath != "" && (as.ClientCertPath == "" || as.ClientKeyPath == "") {
+		return nil, errors.New("admin server requires a client certificate: ClientCertPath and ClientKeyPath must be set")
+	}
+	if as.ClientCertPath != "" || as.ClientKeyPath != "" {
+		if as.ClientCertPath == "" || as.ClientKeyPath == "" {
+			return nil, errors.New("ClientCertPath and ClientKeyPath must both be set")
+		}
+		cert, err := loadX509KeyPair(as.ClientCertPath, as.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load admin client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func loadX509KeyPair(certPath, keyPath string) (tls.Certificate, error) {
+	certPath, err := Path("", certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPath, err = Path("", keyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+// loadCertPool adds the PEM encoded certificates in the given file to pool,
+// creating the pool if nil.
+func loadCertPool(pool *x509.CertPool, filePath string) (*x509.CertPool, error) {
+	filePath, err := Path("", filePath)
+	if err != nil {
+		return nil, err
+	}
+	pemCerts, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return nil, fmt.Errorf("no certificates found in %s", filePath)
+	}
+	return pool, nil
+}
+
 // PopulateDefaults replaces zero-values in the config with default values.
 func (c *AdminServer) PopulateDefaults() {
 	if c.ListenMultiaddr == "" {
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminServerClientToken(t *testing.T) {
+	as := NewAdminServer()
+	require.Empty(t, as.ClientToken())
+
+	as.Tokens = []AdminToken{{Token: "fish", ReadOnly: true}}
+	require.Equal(t, "fish", as.ClientToken())
+
+	as.Tokens = append(as.Tokens, AdminToken{Token: "lobster"})
+	require.Equal(t, "lobster", as.ClientToken())
+}
+
+func TestAdminServerTLSConfig(t *testing.T) {
+	as := NewAdminServer()
+	tlsConfig, err := as.ServerTLSConfig()
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+	tlsConfig, err = as.ClientTLSConfig()
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	// Mutual TLS requires the admin server to serve TLS.
+	as.ClientCAPath = "clients.crt"
+	_, err = as.ServerTLSConfig()
+	require.ErrorContains(t, err, "ClientCAPath requires TLSCertPath")
+
+	as.TLSCertPath = t.TempDir() + "/missing.crt"
+	as.TLSKeyPath = t.TempDir() + "/missing.key"
+	_, err = as.ServerTLSConfig()
+	require.ErrorContains(t, err, "cannot load admin server certificate")
+}
+
+func TestAdminServerUnixSocket(t *testing.T) {
+	as := NewAdminServer()
+	path, _, err := as.UnixSocket()
+	require.NoError(t, err)
+	require.Empty(t, path)
+
+	configRoot := t.TempDir()
+	t.Setenv(EnvDir, configRoot)
+	as.UnixSocketPath = "admin.sock"
+	path, mode, err := as.UnixSocket()
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(configRoot, "admin.sock"), path)
+	require.Equal(t, fs.FileMode(0600), mode)
+
+	as.UnixSocketMode = "660"
+	_, mode, err = as.UnixSocket()
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0660), mode)
+
+	for _, bad := range []string{"fish", "0999", "10777"} {
+		as.UnixSocketMode = bad
+		_, _, err = as.UnixSocket()
+		require.Error(t, err, bad)
+	}
+}
@@ -11,14 +11,19 @@ import (
 
 // Config is used to load config files.
 type Config struct {
-	Identity         Identity
-	Datastore        Datastore
-	Ingest           Ingest
-	ProviderServer   ProviderServer
-	AdminServer      AdminServer
-	Bootstrap        Bootstrap
-	DirectAnnounce   DirectAnnounce
-	DelegatedRouting DelegatedRouting
+	Identity          Identity
+	Datastore         Datastore
+	Ingest            Ingest
+	ProviderServer    ProviderServer
+	AdminServer       AdminServer
+	Bootstrap         Bootstrap
+	DirectAnnounce    DirectAnnounce
+	DelegatedRouting  DelegatedRouting
+	FindServer        FindServer
+	GatewayServer     GatewayServer
+	Metrics           Metrics
+	ExtendedProviders ExtendedProviders
+	Webhooks          Webhooks
 }
 
 const (
@@ -96,13 +101,18 @@ func Load(filePath string) (*Config, error) {
 
 	// Populate with initial values in case they are not present in config.
 	cfg := Config{
-		Bootstrap:        NewBootstrap(),
-		Datastore:        NewDatastore(),
-		Ingest:           NewIngest(),
-		AdminServer:      NewAdminServer(),
-		ProviderServer:   NewProviderServer(),
-		DirectAnnounce:   NewDirectAnnounce(),
-		DelegatedRouting: NewDelegatedRouting(),
+		Bootstrap:         NewBootstrap(),
+		Datastore:         NewDatastore(),
+		Ingest:            NewIngest(),
+		AdminServer:       NewAdminServer(),
+		ProviderServer:    NewProviderServer(),
+		DirectAnnounce:    NewDirectAnnounce(),
+		DelegatedRouting:  NewDelegatedRouting(),
+		FindServer:        NewFindServer(),
+		GatewayServer:     NewGatewayServer(),
+		Metrics:           NewMetrics(),
+		ExtendedProviders: NewExtendedProviders(),
+		Webhooks:          NewWebhooks(),
 	}
 
 	if err = json.NewDecoder(f).Decode(&cfg); err != nil {
@@ -159,4 +169,7 @@ func (c *Config) PopulateDefaults() {
 	c.Ingest.PopulateDefaults()
 	c.ProviderServer.PopulateDefaults()
 	c.DelegatedRouting.PopulateDefaults()
+	c.FindServer.PopulateDefaults()
+	c.GatewayServer.PopulateDefaults()
+	c.Webhooks.PopulateDefaults()
 }
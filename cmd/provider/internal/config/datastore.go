@@ -11,6 +11,10 @@ type Datastore struct {
 	Type string
 	// Dir is the directory within the config root where the datastore is kept
 	Dir string
+	// ChainNamespace is the namespace within the datastore in which the
+	// advertisement chain is kept. It is empty unless the provider identity
+	// has been rotated, in which case each identity has its own chain.
+	ChainNamespace string `json:",omitempty"`
 }
 
 // NewDatastore instantiates a new Datastore config with default values.
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// FindServer configures the find server, which exposes the IPNI HTTP find API
+// for the content advertised by this provider. The find server is disabled
+// unless ListenMultiaddr is set. Enabling it also enables the reverse index;
+// see Ingest.ReverseIndex.
+type FindServer struct {
+	// ListenMultiaddr is the find server listen address, for example
+	// "/ip4/127.0.0.1/tcp/3105".
+	ListenMultiaddr string
+	ReadTimeout     Duration
+	WriteTimeout    Duration
+}
+
+// NewFindServer instantiates a new FindServer config with default values.
+func NewFindServer() FindServer {
+	return FindServer{
+		ReadTimeout:  defaultReadTimeout,
+		WriteTimeout: defaultWriteTimeout,
+	}
+}
+
+func (c *FindServer) ListenNetAddr() (string, error) {
+	maddr, err := multiaddr.NewMultiaddr(c.ListenMultiaddr)
+	if err != nil {
+		return "", err
+	}
+
+	netAddr, err := manet.ToNetAddr(maddr)
+	if err != nil {
+		return "", err
+	}
+	return netAddr.String(), nil
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *FindServer) PopulateDefaults() {
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = defaultReadTimeout
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = defaultWriteTimeout
+	}
+}
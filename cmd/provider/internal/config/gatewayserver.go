@@ -0,0 +1,70 @@
+package config
+
+import (
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const defaultGatewayWriteTimeout = Duration(10 * time.Minute)
+
+// GatewayServer configures the trustless HTTP gateway that serves the content
+// of imported CARs. The gateway is disabled unless ListenMultiaddr is set.
+// When enabled, imported CARs are also advertised with the IPFS gateway
+// retrieval protocol, and the reverse index is enabled; see
+// Ingest.ReverseIndex.
+type GatewayServer struct {
+	// ListenMultiaddr is the address of the interface to listen for gateway
+	// requests, for example "/ip4/0.0.0.0/tcp/3106/http".
+	ListenMultiaddr string
+	// AnnounceMultiaddr is the address advertised for retrieval over the
+	// gateway, for example "/dns4/example.com/tcp/443/https". If not
+	// specified, the ListenMultiaddr is used.
+	AnnounceMultiaddr string
+	ReadTimeout       Duration
+	WriteTimeout      Duration
+}
+
+// NewGatewayServer instantiates a new GatewayServer config with default
+// values.
+func NewGatewayServer() GatewayServer {
+	return GatewayServer{
+		ReadTimeout:  defaultReadTimeout,
+		WriteTimeout: defaultGatewayWriteTimeout,
+	}
+}
+
+func (c *GatewayServer) ListenNetAddr() (string, error) {
+	maddr, err := multiaddr.NewMultiaddr(c.ListenMultiaddr)
+	if err != nil {
+		return "", err
+	}
+	httpMultiaddr, _ := multiaddr.NewMultiaddr("/http")
+	maddr = maddr.Decapsulate(httpMultiaddr)
+
+	netAddr, err := manet.ToNetAddr(maddr)
+	if err != nil {
+		return "", err
+	}
+	return netAddr.String(), nil
+}
+
+// RetrievalMultiaddr returns the address advertised for retrieval over the
+// gateway.
+func (c *GatewayServer) RetrievalMultiaddr() string {
+	if c.AnnounceMultiaddr != "" {
+		return c.AnnounceMultiaddr
+	}
+	return c.ListenMultiaddr
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *GatewayServer) PopulateDefaults() {
+	if c.ReadTimeout == 0 {
+		c.ReadTimeout = defaultReadTimeout
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = defaultGatewayWriteTimeout
+	}
+}
@@ -9,7 +9,9 @@ type HttpPublisher struct {
 	// AnnounceMultiaddr is the address supplied in the announce message
 	// telling indexers the address to use to retrieve advertisements. This
 	// configures the addresses to announce when using a Libp2pPublisher,
-	// HttpPublisher, or Libp2pHttpPublisher.
+	// HttpPublisher, or Libp2pHttpPublisher. With the static publisher, this
+	// is the address of the static file host, and must be set for indexers to
+	// sync advertisements.
 	//
 	// If not specified, the ListenMultiaddr is used with HttpPubliser, the
 	// libp2p host address is used with Libp2pPublisher and both are used with
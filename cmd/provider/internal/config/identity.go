@@ -1,31 +1,63 @@
 package config
 
 import (
+	"bytes"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/ipni/index-provider/engine/signer"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 const (
 	PrivateKeyPathEnvVar = "INDEXPROVIDER_PRIV_KEY_PATH"
+	// KeystorePassphraseEnvVar is the environment variable holding the
+	// passphrase of the identity keystore.
+	KeystorePassphraseEnvVar = "INDEXPROVIDER_KEYSTORE_PASSPHRASE"
+	// KeystorePassphraseFileEnvVar is the environment variable holding the
+	// path to a file containing the passphrase of the identity keystore. It is
+	// only used if KeystorePassphraseEnvVar is not set.
+	KeystorePassphraseFileEnvVar = "INDEXPROVIDER_KEYSTORE_PASSPHRASE_FILE"
+
+	// DefaultKeystoreFile is the default name of the identity keystore file,
+	// relative to the config root.
+	DefaultKeystoreFile = "keystore"
 )
 
 // Identity tracks the configuration of the local node's identity.
 type Identity struct {
 	PeerID  string
 	PrivKey string `json:",omitempty"`
+	// KeystorePath is the path to the file holding the private key encrypted
+	// with a passphrase. The passphrase is read from KeystorePassphraseEnvVar
+	// or from the file named by KeystorePassphraseFileEnvVar. If the path is
+	// relative, it is relative to the config root. When set, PrivKey must be
+	// empty.
+	KeystorePath string `json:",omitempty"`
+	// Rotation records an identity rotation that is not yet complete.
+	Rotation *IdentityRotation `json:",omitempty"`
+}
+
+// IdentityRotation tracks the rotation of the provider identity to a new key.
+// The daemon performs the rotation when started, and replaces the identity
+// with the new one once the rotation is complete.
+type IdentityRotation struct {
+	// Identity is the identity being rotated to.
+	Identity Identity
+	// Mode is how content advertised by the current identity is handed over
+	// to the new identity: "xproviders" or "remove".
+	Mode string
 }
 
 func (identity Identity) DecodeOrCreate(out io.Writer) (peer.ID, ic.PrivKey, error) {
 
 	privKey, err := identity.DecodeOrCreatePrivateKey(out, "")
 	if err != nil {
-		return "", nil, fmt.Errorf("could not decode private key: %s", err)
+		return "", nil, fmt.Errorf("could not decode private key: %w", err)
 	}
 
 	peerIDFromPrivKey, err := peer.IDFromPrivateKey(privKey)
@@ -52,7 +84,30 @@ func (identity Identity) DecodeOrCreate(out io.Writer) (peer.ID, ic.PrivKey, err
 
 // DecodeOrCreatePrivateKey is a helper to decode the user's PrivateKey. If the key hasn't been provided in json config
 // then it's going to be read from PrivateKeyPathEnvVar. If that file doesn't exist then a new key is going to be generated and saved there.
+//
+// If the identity is stored in a keystore, then the key is decrypted using the
+// given passphrase, or if that is empty the passphrase given by
+// KeystorePassphrase.
 func (identity Identity) DecodeOrCreatePrivateKey(out io.Writer, passphrase string) (ic.PrivKey, error) {
+	if identity.KeystorePath != "" {
+		if identity.PrivKey != "" {
+			return nil, errors.New("private key must not be specified in config when keystore is used")
+		}
+		pass := []byte(passphrase)
+		if len(pass) == 0 {
+			var err error
+			pass, err = KeystorePassphrase()
+			if err != nil {
+				return nil, err
+			}
+		}
+		ksPath, err := identity.KeystoreFile()
+		if err != nil {
+			return nil, err
+		}
+		return signer.ReadKeystore(ksPath, pass)
+	}
+
 	if identity.PrivKey == "" {
 		pkb, err := loadPrivKeyFromFile(out)
 		if err != nil {
@@ -68,6 +123,70 @@ func (identity Identity) DecodeOrCreatePrivateKey(out io.Writer, passphrase stri
 	return ic.UnmarshalPrivateKey(pkb)
 }
 
+// SetPrivKey sets the identity to the given private key. If the identity is
+// stored in a keystore, then the key is written to the keystore encrypted with
+// the passphrase. Otherwise, the key is stored in the identity itself and the
+// passphrase is ignored.
+func (identity *Identity) SetPrivKey(key ic.PrivKey, passphrase []byte) error {
+	peerID, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if identity.KeystorePath != "" {
+		ksPath, err := identity.KeystoreFile()
+		if err != nil {
+			return err
+		}
+		if err = signer.WriteKeystore(ksPath, key, passphrase); err != nil {
+			return fmt.Errorf("cannot write keystore: %w", err)
+		}
+		identity.PrivKey = ""
+	} else {
+		pkb, err := ic.MarshalPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		identity.PrivKey = base64.StdEncoding.EncodeToString(pkb)
+	}
+	identity.PeerID = peerID.String()
+	return nil
+}
+
+// KeystoreFile returns the path to the identity keystore file.
+func (identity Identity) KeystoreFile() (string, error) {
+	if identity.KeystorePath == "" {
+		return "", errors.New("identity keystore not configured")
+	}
+	return Path("", identity.KeystorePath)
+}
+
+// KeystorePassphrase returns the keystore passphrase from
+// KeystorePassphraseEnvVar, or else from the file named by
+// KeystorePassphraseFileEnvVar.
+func KeystorePassphrase() ([]byte, error) {
+	if pass := os.Getenv(KeystorePassphraseEnvVar); pass != "" {
+		return []byte(pass), nil
+	}
+	if passFile := os.Getenv(KeystorePassphraseFileEnvVar); passFile != "" {
+		return ReadPassphraseFile(passFile)
+	}
+	return nil, fmt.Errorf("keystore passphrase not specified; it must be specified via %s or %s env var", KeystorePassphraseEnvVar, KeystorePassphraseFileEnvVar)
+}
+
+// ReadPassphraseFile reads a passphrase from the file at filePath. Any
+// trailing line break is removed.
+func ReadPassphraseFile(filePath string) ([]byte, error) {
+	pass, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read passphrase file: %w", err)
+	}
+	pass = bytes.TrimRight(pass, "\r\n")
+	if len(pass) == 0 {
+		return nil, errors.New("passphrase file is empty")
+	}
+	return pass, nil
+}
+
 func loadPrivKeyFromFile(out io.Writer) ([]byte, error) {
 	privKeyPath := os.Getenv(PrivateKeyPathEnvVar)
 	if privKeyPath == "" {
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/ipni/index-provider/engine/signer"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/stretchr/testify/require"
@@ -52,3 +53,45 @@ func TestDecodeOrCreate(t *testing.T) {
 	// verifying that the identity from the file matches the one returned by the function
 	require.Equal(t, peerIDFromFile, peerIDAfter)
 }
+
+func TestDecodeOrCreateWithKeystore(t *testing.T) {
+	t.Setenv(EnvDir, t.TempDir())
+	t.Setenv(KeystorePassphraseEnvVar, "")
+	passFile := filepath.Join(t.TempDir(), "passphrase")
+	require.NoError(t, os.WriteFile(passFile, []byte("fish\n"), 0o600))
+	t.Setenv(KeystorePassphraseFileEnvVar, passFile)
+
+	created, err := CreateIdentity(io.Discard)
+	require.NoError(t, err)
+	wantPeerID, wantKey, err := created.DecodeOrCreate(io.Discard)
+	require.NoError(t, err)
+
+	identity := Identity{KeystorePath: DefaultKeystoreFile}
+	require.NoError(t, identity.SetPrivKey(wantKey, []byte("fish")))
+	require.Empty(t, identity.PrivKey)
+	require.Equal(t, wantPeerID.String(), identity.PeerID)
+
+	// The passphrase is read from the file named in the environment.
+	gotPeerID, gotKey, err := identity.DecodeOrCreate(io.Discard)
+	require.NoError(t, err)
+	require.Equal(t, wantPeerID, gotPeerID)
+	require.True(t, wantKey.Equals(gotKey))
+
+	// An explicit passphrase takes precedence over the environment.
+	_, err = identity.DecodeOrCreatePrivateKey(io.Discard, "lobster")
+	require.ErrorIs(t, err, signer.ErrBadPassphrase)
+
+	// Changing the passphrase rewrites the keystore.
+	require.NoError(t, identity.SetPrivKey(wantKey, []byte("lobster")))
+	_, _, err = identity.DecodeOrCreate(io.Discard)
+	require.ErrorIs(t, err, signer.ErrBadPassphrase)
+	t.Setenv(KeystorePassphraseEnvVar, "lobster")
+	_, gotKey, err = identity.DecodeOrCreate(io.Discard)
+	require.NoError(t, err)
+	require.True(t, wantKey.Equals(gotKey))
+
+	// A key in the config is not allowed together with a keystore.
+	identity.PrivKey = created.PrivKey
+	_, _, err = identity.DecodeOrCreate(io.Discard)
+	require.Error(t, err)
+}
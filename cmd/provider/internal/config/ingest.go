@@ -14,6 +14,7 @@ const (
 	HttpPublisherKind       PublisherKind = "http"
 	Libp2pPublisherKind     PublisherKind = "libp2p"
 	Libp2pHttpPublisherKind PublisherKind = "libp2phttp"
+	StaticPublisherKind     PublisherKind = "static"
 )
 
 // Ingest configures settings related to the ingestion protocol.
@@ -31,14 +32,23 @@ type Ingest struct {
 	PubSubTopic string
 	// PurgeLinkCache tells whether to purge the link cache on daemon startup.
 	PurgeLinkCache bool
+	// ReverseIndex enables an index from each advertised multihash to the
+	// context IDs that advertise it, used by the find command. CARs imported
+	// while the index was not enabled are indexed when the daemon starts.
+	ReverseIndex bool `json:",omitempty"`
 
 	// HttpPublisher configures the dagsync ipnisync publisher.
 	HttpPublisher HttpPublisher
+	// StaticPublisher configures the publisher that writes the advertisement
+	// chain into a local directory, used when PublisherKind is "static".
+	StaticPublisher StaticPublisher
 
 	// PublisherKind specifies which dagsync.Publisher implementation to use.
 	// When set to "http", the publisher serves plain HTTP. When set to
 	// "libp2p" the publisher serves HTTP over libp2p. When set to
 	// "libp2phttp", the publisher serves both plain HTTP and HTTP over libp2p.
+	// When set to "static", the advertisement chain is written into the
+	// StaticPublisher directory, to be served by a static file host.
 	//
 	// Plain HTTP is disabled if HttpPublisher.ListenMultiaddr is set to "".
 	PublisherKind PublisherKind
@@ -55,6 +65,7 @@ func NewIngest() Ingest {
 		LinkedChunkSize: defaultLinkedChunkSize,
 		PubSubTopic:     defaultPubSubTopic,
 		HttpPublisher:   NewHttpPublisher(),
+		StaticPublisher: NewStaticPublisher(),
 		PublisherKind:   HttpPublisherKind,
 		SyncPolicy:      NewPolicy(),
 	}
@@ -71,4 +82,5 @@ func (c *Ingest) PopulateDefaults() {
 	if c.PubSubTopic == "" {
 		c.PubSubTopic = defaultPubSubTopic
 	}
+	c.StaticPublisher.PopulateDefaults()
 }
@@ -20,13 +20,18 @@ func Init(out io.Writer) (*Config, error) {
 
 func InitWithIdentity(identity Identity) (*Config, error) {
 	return &Config{
-		Identity:         identity,
-		Bootstrap:        NewBootstrap(),
-		Datastore:        NewDatastore(),
-		Ingest:           NewIngest(),
-		ProviderServer:   NewProviderServer(),
-		AdminServer:      NewAdminServer(),
-		DelegatedRouting: NewDelegatedRouting(),
+		Identity:          identity,
+		Bootstrap:         NewBootstrap(),
+		Datastore:         NewDatastore(),
+		Ingest:            NewIngest(),
+		ProviderServer:    NewProviderServer(),
+		AdminServer:       NewAdminServer(),
+		DelegatedRouting:  NewDelegatedRouting(),
+		FindServer:        NewFindServer(),
+		GatewayServer:     NewGatewayServer(),
+		Metrics:           NewMetrics(),
+		ExtendedProviders: NewExtendedProviders(),
+		Webhooks:          NewWebhooks(),
 	}, nil
 }
 
@@ -47,8 +52,8 @@ func CreateIdentity(out io.Writer) (Identity, error) {
 	sk = priv
 	pk = pub
 
-	// currently storing key unencrypted. in the future we need to encrypt it.
-	// TODO(security)
+	// The key is stored unencrypted in the config, unless it is moved to an
+	// encrypted keystore. See Identity.SetPrivKey.
 	skbytes, err := crypto.MarshalPrivateKey(sk)
 	if err != nil {
 		return ident, err
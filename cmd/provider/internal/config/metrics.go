@@ -0,0 +1,33 @@
+package config
+
+import (
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Metrics configures the metrics server of the daemon, which exposes
+// Prometheus metrics at /metrics. The metrics server is disabled unless
+// ListenMultiaddr is set.
+type Metrics struct {
+	// ListenMultiaddr is the metrics server listen address, for example
+	// "/ip4/127.0.0.1/tcp/3107".
+	ListenMultiaddr string
+}
+
+// NewMetrics instantiates a new Metrics config with default values.
+func NewMetrics() Metrics {
+	return Metrics{}
+}
+
+func (c *Metrics) ListenNetAddr() (string, error) {
+	maddr, err := multiaddr.NewMultiaddr(c.ListenMultiaddr)
+	if err != nil {
+		return "", err
+	}
+
+	netAddr, err := manet.ToNetAddr(maddr)
+	if err != nil {
+		return "", err
+	}
+	return netAddr.String(), nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// MirrorSources configures the providers mirrored by the mirror-manager
+// command. It is read from its own file, rather than from the config file of
+// the provider daemon.
+type MirrorSources struct {
+	// Sources is the list of mirrored providers.
+	Sources []MirrorSource
+}
+
+// MirrorSource configures a single mirrored provider.
+type MirrorSource struct {
+	// Source is the multiaddr of the mirrored provider, including its peer
+	// ID, for example "/ip4/1.2.3.4/tcp/3103/p2p/12D3KooW...".
+	Source string
+	// IdentityPath is the path to a file containing the private key of the
+	// mirror of this source, in the same format as the file specified by
+	// INDEXPROVIDER_PRIV_KEY_PATH. A relative path is relative to the
+	// directory of the sources file. If empty, a random identity is used.
+	IdentityPath string `json:",omitempty"`
+}
+
+// LoadMirrorSources reads the json-serialized mirror sources at the specified
+// path.
+func LoadMirrorSources(filePath string) (*MirrorSources, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ms MirrorSources
+	if err = json.NewDecoder(f).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("cannot decode mirror sources: %w", err)
+	}
+
+	// Resolve relative paths now, so that they do not depend on the working
+	// directory when used.
+	dir := filepath.Dir(filePath)
+	seen := make(map[peer.ID]struct{}, len(ms.Sources))
+	for i, s := range ms.Sources {
+		source, err := s.AddrInfo()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[source.ID]; ok {
+			return nil, fmt.Errorf("duplicate mirror source %s", source.ID)
+		}
+		seen[source.ID] = struct{}{}
+		if s.IdentityPath != "" {
+			ms.Sources[i].IdentityPath, err = Path(dir, s.IdentityPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &ms, nil
+}
+
+// AddrInfo returns the address info of the mirrored provider.
+func (s MirrorSource) AddrInfo() (*peer.AddrInfo, error) {
+	source, err := peer.AddrInfoFromString(s.Source)
+	if err != nil {
+		return nil, fmt.Errorf("bad mirror source %q: %w", s.Source, err)
+	}
+	return source, nil
+}
+
+// PrivKey loads the private key of the mirror of this source, or returns nil
+// if no identity is configured.
+func (s MirrorSource) PrivKey() (crypto.PrivKey, error) {
+	if s.IdentityPath == "" {
+		return nil, nil
+	}
+	priv, err := LoadPrivKey(s.IdentityPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load identity of mirror source %s: %w", s.Source, err)
+	}
+	return priv, nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMirrorSources(t *testing.T) {
+	dir := t.TempDir()
+	priv, _, err := ic.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	pkb, err := ic.MarshalPrivateKey(priv)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mirror.key"), pkb, 0600))
+
+	const source = "/ip4/1.2.3.4/tcp/3103/p2p/12D3KooWPw6bfQbJHfKa2o5XpusChoq67iZoqgfnhecygjKsQRmG"
+	path := filepath.Join(dir, "sources.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Sources": [{"Source": "`+source+`", "IdentityPath": "mirror.key"}]}`), 0600))
+
+	ms, err := LoadMirrorSources(path)
+	require.NoError(t, err)
+	require.Len(t, ms.Sources, 1)
+	info, err := ms.Sources[0].AddrInfo()
+	require.NoError(t, err)
+	require.Equal(t, "12D3KooWPw6bfQbJHfKa2o5XpusChoq67iZoqgfnhecygjKsQRmG", info.ID.String())
+	// Relative identity paths are relative to the sources file.
+	require.Equal(t, filepath.Join(dir, "mirror.key"), ms.Sources[0].IdentityPath)
+	gotPriv, err := ms.Sources[0].PrivKey()
+	require.NoError(t, err)
+	require.True(t, priv.Equals(gotPriv))
+
+	// Sources without identity use a random one.
+	gotPriv, err = MirrorSource{Source: source}.PrivKey()
+	require.NoError(t, err)
+	require.Nil(t, gotPriv)
+
+	// Duplicate and malformed sources are rejected.
+	require.NoError(t, os.WriteFile(path, []byte(`{"Sources": [{"Source": "`+source+`"}, {"Source": "`+source+`"}]}`), 0600))
+	_, err = LoadMirrorSources(path)
+	require.ErrorContains(t, err, "duplicate")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Sources": [{"Source": "/ip4/1.2.3.4/tcp/3103"}]}`), 0600))
+	_, err = LoadMirrorSources(path)
+	require.Error(t, err)
+}
@@ -1,6 +1,6 @@
 package config
 
-// Policy configures which indexer peers are allowed and which are blocked.
+// Policy configures which peers are allowed and which are blocked.
 type Policy struct {
 	// Allow is either false or true, and determines whether a peer is allowed
 	// (true) or is blocked (false), by default.
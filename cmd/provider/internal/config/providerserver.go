@@ -1,17 +1,35 @@
 package config
 
+const defaultMaxOpenBlockstores = 64
+
 type ProviderServer struct {
 	// ListenMultiaddr is the multiaddr string for the node's listen address
 	ListenMultiaddr string
 	// RetrievalMultiaddrs are the addresses to advertise for data retrieval.
 	// Defaults to the provider's libp2p host listen addresses.
 	RetrievalMultiaddrs []string
+	// Bitswap enables a bitswap server on the libp2p host that serves the
+	// blocks of imported CARs. When enabled, imported CARs are also
+	// advertised with the bitswap retrieval protocol, and the reverse index is
+	// enabled; see Ingest.ReverseIndex.
+	Bitswap bool `json:",omitempty"`
+	// MaxOpenBlockstores is the maximum number of imported CARs kept open
+	// for graphsync retrievals. Retrievals of the same CAR share one open
+	// CAR, and retrievals that need another CAR are rejected when all open
+	// CARs are in use.
+	MaxOpenBlockstores int
+	// RetrievalPolicy configures which peers may retrieve over graphsync, and
+	// limits their transfers. It can be changed at runtime through the admin
+	// server, though changes are not written to the config file.
+	RetrievalPolicy RetrievalPolicy
 }
 
 // NewProviderServer instantiates a new ProviderServer config with default values.
 func NewProviderServer() ProviderServer {
 	return ProviderServer{
-		ListenMultiaddr: "/ip4/0.0.0.0/tcp/3103",
+		ListenMultiaddr:    "/ip4/0.0.0.0/tcp/3103",
+		MaxOpenBlockstores: defaultMaxOpenBlockstores,
+		RetrievalPolicy:    NewRetrievalPolicy(),
 	}
 }
 
@@ -22,4 +40,7 @@ func (c *ProviderServer) PopulateDefaults() {
 	if c.ListenMultiaddr == "" {
 		c.ListenMultiaddr = def.ListenMultiaddr
 	}
+	if c.MaxOpenBlockstores == 0 {
+		c.MaxOpenBlockstores = def.MaxOpenBlockstores
+	}
 }
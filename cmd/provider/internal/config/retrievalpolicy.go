@@ -0,0 +1,24 @@
+package config
+
+// RetrievalPolicy configures which peers may retrieve content over graphsync,
+// and limits the transfers of each peer.
+type RetrievalPolicy struct {
+	// Peers configures which peers are allowed to retrieve. By default, all
+	// peers are allowed.
+	Peers Policy
+	// MaxTransfersPerPeer is the maximum number of concurrent transfers to a
+	// peer. Further retrievals by the peer are rejected until one of its
+	// transfers ends. Zero means no limit.
+	MaxTransfersPerPeer int `json:",omitempty"`
+	// MaxBytesPerSecondPerPeer is the maximum number of bytes per second sent
+	// to a peer over all of its transfers. Zero means no limit.
+	MaxBytesPerSecondPerPeer int `json:",omitempty"`
+}
+
+// NewRetrievalPolicy returns RetrievalPolicy with values set to their
+// defaults.
+func NewRetrievalPolicy() RetrievalPolicy {
+	return RetrievalPolicy{
+		Peers: NewPolicy(),
+	}
+}
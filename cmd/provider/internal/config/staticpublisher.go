@@ -0,0 +1,29 @@
+package config
+
+const defaultStaticPublisherDir = "static"
+
+// StaticPublisher configures the publisher that writes the advertisement chain
+// into a local directory, so that the directory can be synced to a static file
+// host from which indexers fetch advertisements over HTTP.
+//
+// The address of the static file host, supplied in announce messages, is set
+// by HttpPublisher.AnnounceMultiaddr.
+type StaticPublisher struct {
+	// Dir is the directory into which the advertisement chain is written. A
+	// relative path is relative to the config root.
+	Dir string
+}
+
+// NewStaticPublisher instantiates a new config with default values.
+func NewStaticPublisher() StaticPublisher {
+	return StaticPublisher{
+		Dir: defaultStaticPublisherDir,
+	}
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *StaticPublisher) PopulateDefaults() {
+	if c.Dir == "" {
+		c.Dir = defaultStaticPublisherDir
+	}
+}
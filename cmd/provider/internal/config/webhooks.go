@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ipni/index-provider/engine/webhook"
+)
+
+const (
+	defaultWebhookMaxAttempts    = 5
+	defaultWebhookRetryInterval  = Duration(time.Second)
+	defaultWebhookDeadLetterFile = "webhook-deadletter.jsonl"
+)
+
+// Webhooks configures the URLs that are notified of each published
+// advertisement. Each notification is a JSON payload with the advertisement
+// CID, provider, context ID, whether it is a removal, the metadata protocols
+// and a timestamp, POSTed to every URL.
+type Webhooks struct {
+	// URLs is the list of URLs to notify. No notifications are sent if empty.
+	URLs []string
+	// SecretFile is the path to a file containing the secret that payloads
+	// are signed with, using HMAC-SHA256. The signature is sent in the
+	// X-Index-Provider-Signature header. If empty, payloads are not signed. A
+	// relative path is relative to the config root directory.
+	SecretFile string `json:",omitempty"`
+	// MaxAttempts is the maximum number of attempts made to deliver each
+	// notification to each URL.
+	MaxAttempts int
+	// RetryInterval is the time to wait before retrying a failed delivery. It
+	// doubles after each further failure.
+	RetryInterval Duration
+	// DeadLetterFile is the path to the file that notifications which could
+	// not be delivered are appended to, as JSON lines. A relative path is
+	// relative to the config root directory.
+	DeadLetterFile string
+}
+
+// NewWebhooks returns Webhooks with values set to their defaults.
+func NewWebhooks() Webhooks {
+	return Webhooks{
+		MaxAttempts:    defaultWebhookMaxAttempts,
+		RetryInterval:  defaultWebhookRetryInterval,
+		DeadLetterFile: defaultWebhookDeadLetterFile,
+	}
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *Webhooks) PopulateDefaults() {
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if c.RetryInterval == 0 {
+		c.RetryInterval = defaultWebhookRetryInterval
+	}
+	if c.DeadLetterFile == "" {
+		c.DeadLetterFile = defaultWebhookDeadLetterFile
+	}
+}
+
+// Options returns the webhook.Notifier options for the config. Relative paths
+// are relative to configRoot, or the default config root if empty.
+func (c Webhooks) Options(configRoot string) ([]webhook.Option, error) {
+	deadLetterPath, err := Path(configRoot, c.DeadLetterFile)
+	if err != nil {
+		return nil, err
+	}
+	opts := []webhook.Option{
+		webhook.WithMaxAttempts(c.MaxAttempts),
+		webhook.WithRetryInterval(time.Duration(c.RetryInterval)),
+		webhook.WithDeadLetterFile(deadLetterPath),
+	}
+	if c.SecretFile != "" {
+		secretPath, err := Path(configRoot, c.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		secret, err := os.ReadFile(secretPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read webhook secret: %w", err)
+		}
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("webhook secret file %s is empty", secretPath)
+		}
+		opts = append(opts, webhook.WithSecret(secret))
+	}
+	return opts, nil
+}
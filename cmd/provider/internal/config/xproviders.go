@@ -0,0 +1,198 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ipni/index-provider/engine/xproviders"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// ExtendedProviders configures the providers that are advertised as
+// alternative retrieval providers for the content advertised by this
+// provider. See:
+// https://github.com/ipni/storetheindex/blob/main/doc/ingest.md#extendedprovider
+//
+// The configured set is published when the daemon starts, if it differs from
+// the set most recently published. If no providers are configured then any
+// previously published set is left unchanged, so that a set managed via the
+// admin API is retained.
+type ExtendedProviders struct {
+	// Providers is the list of extended providers.
+	Providers []ExtendedProvider
+	// ContextID is the base64 encoded context ID to which the extended
+	// providers apply. If empty, the extended providers apply to all content
+	// published by this provider.
+	ContextID string
+	// Metadata is the base64 encoded metadata of this provider, to include in
+	// the extended providers advertisement.
+	Metadata string
+	// Override specifies whether the extended providers for ContextID replace
+	// the ones that apply to all content. Only valid if ContextID is set.
+	Override bool
+	// KeysDir is the directory holding the private keys of extended providers
+	// that are managed through the admin API, in files named by the peer ID
+	// of each extended provider, in the same format as PrivKeyPath. The keys
+	// are loaded when signing on behalf of an extended provider whose key was
+	// not supplied since the daemon started, for example to remove it after a
+	// restart. Key files named in admin API requests must be in this
+	// directory; if unset, such requests are rejected. A relative path is
+	// relative to the config root directory.
+	KeysDir string `json:",omitempty"`
+}
+
+// ExtendedProvider configures a single extended provider.
+type ExtendedProvider struct {
+	// PeerID is the peer ID of the extended provider.
+	PeerID string
+	// PrivKeyPath is the path to a file containing the private key of the
+	// extended provider, in the same format as the file specified by
+	// INDEXPROVIDER_PRIV_KEY_PATH. The key is used to sign the advertisement
+	// on behalf of the extended provider. A relative path is relative to the
+	// config root directory.
+	PrivKeyPath string
+	// Addrs are the multiaddrs at which the extended provider serves content,
+	// for example "/dns4/boost.example.com/tcp/443/https".
+	Addrs []string
+	// Metadata is the base64 encoded metadata of the extended provider.
+	Metadata string
+}
+
+// NewExtendedProviders returns ExtendedProviders with values set to their
+// defaults.
+func NewExtendedProviders() ExtendedProviders {
+	return ExtendedProviders{}
+}
+
+// DecodeContextID returns the decoded context ID.
+func (x ExtendedProviders) DecodeContextID() ([]byte, error) {
+	if x.ContextID == "" {
+		return nil, nil
+	}
+	contextID, err := base64.StdEncoding.DecodeString(x.ContextID)
+	if err != nil {
+		return nil, fmt.Errorf("bad ExtendedProviders context id: %w", err)
+	}
+	return contextID, nil
+}
+
+// DecodeMetadata returns the decoded metadata of this provider.
+func (x ExtendedProviders) DecodeMetadata() ([]byte, error) {
+	if x.Metadata == "" {
+		return nil, nil
+	}
+	md, err := base64.StdEncoding.DecodeString(x.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("bad ExtendedProviders metadata: %w", err)
+	}
+	return md, nil
+}
+
+// Infos checks the configured extended providers and loads their private keys.
+// Relative key paths are resolved against configRoot, which is the default
+// config root if empty.
+func (x ExtendedProviders) Infos(configRoot string) ([]xproviders.Info, error) {
+	if x.Override && x.ContextID == "" {
+		return nil, errors.New("ExtendedProviders override requires a context id")
+	}
+	infos := make([]xproviders.Info, 0, len(x.Providers))
+	for _, xp := range x.Providers {
+		info, err := xp.Info(configRoot)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Info checks the configured extended provider and loads its private key.
+func (xp ExtendedProvider) Info(configRoot string) (xproviders.Info, error) {
+	peerID, err := peer.Decode(xp.PeerID)
+	if err != nil {
+		return xproviders.Info{}, fmt.Errorf("bad extended provider peer id %q: %w", xp.PeerID, err)
+	}
+	if len(xp.Addrs) == 0 {
+		return xproviders.Info{}, fmt.Errorf("no addresses for extended provider %s", peerID)
+	}
+	for _, a := range xp.Addrs {
+		if _, err = multiaddr.NewMultiaddr(a); err != nil {
+			return xproviders.Info{}, fmt.Errorf("bad multiaddr %q for extended provider %s: %w", a, peerID, err)
+		}
+	}
+	var md []byte
+	if xp.Metadata != "" {
+		md, err = base64.StdEncoding.DecodeString(xp.Metadata)
+		if err != nil {
+			return xproviders.Info{}, fmt.Errorf("bad metadata for extended provider %s: %w", peerID, err)
+		}
+	}
+	if xp.PrivKeyPath == "" {
+		return xproviders.Info{}, fmt.Errorf("no private key path for extended provider %s", peerID)
+	}
+	keyPath, err := Path(configRoot, xp.PrivKeyPath)
+	if err != nil {
+		return xproviders.Info{}, err
+	}
+	priv, err := LoadPrivKey(keyPath)
+	if err != nil {
+		return xproviders.Info{}, fmt.Errorf("cannot load private key for extended provider %s: %w", peerID, err)
+	}
+	keyID, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return xproviders.Info{}, err
+	}
+	if keyID != peerID {
+		return xproviders.Info{}, fmt.Errorf("private key in %s does not match extended provider %s", keyPath, peerID)
+	}
+	return xproviders.Info{
+		ID:       xp.PeerID,
+		Addrs:    xp.Addrs,
+		Metadata: md,
+		Priv:     priv,
+	}, nil
+}
+
+// LoadKey loads the private key of an extended provider, from the key file of
+// the configured extended provider with the given ID, or else from the file
+// named by the ID in KeysDir. It returns a nil key and no error if there is no
+// key for the extended provider. Relative paths are resolved against
+// configRoot, which is the default config root if empty.
+func (x ExtendedProviders) LoadKey(configRoot string, id peer.ID) (crypto.PrivKey, error) {
+	for _, xp := range x.Providers {
+		if xp.PeerID == id.String() && xp.PrivKeyPath != "" {
+			keyPath, err := Path(configRoot, xp.PrivKeyPath)
+			if err != nil {
+				return nil, err
+			}
+			return LoadPrivKey(keyPath)
+		}
+	}
+	if x.KeysDir == "" {
+		return nil, nil
+	}
+	keysDir, err := Path(configRoot, x.KeysDir)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := LoadPrivKey(filepath.Join(keysDir, id.String()))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return priv, err
+}
+
+// LoadPrivKey reads a private key, marshaled with crypto.MarshalPrivateKey,
+// from the given file.
+func LoadPrivKey(filePath string) (crypto.PrivKey, error) {
+	pkb, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.UnmarshalPrivateKey(pkb)
+}
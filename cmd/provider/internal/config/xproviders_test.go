@@ -0,0 +1,103 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendedProvidersInfos(t *testing.T) {
+	configRoot := t.TempDir()
+	priv, _, err := ic.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	peerID, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	pkb, err := ic.MarshalPrivateKey(priv)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(configRoot, "xp.key"), pkb, 0600))
+
+	xps := ExtendedProviders{
+		Providers: []ExtendedProvider{{
+			PeerID:      peerID.String(),
+			PrivKeyPath: "xp.key",
+			Addrs:       []string{"/dns4/boost.example.com/tcp/443/https"},
+			Metadata:    base64.StdEncoding.EncodeToString([]byte("meta")),
+		}},
+	}
+	infos, err := xps.Infos(configRoot)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, peerID.String(), infos[0].ID)
+	require.Equal(t, []byte("meta"), infos[0].Metadata)
+	require.True(t, priv.Equals(infos[0].Priv))
+
+	// Key that does not match the peer ID is rejected.
+	otherID, err := peer.Decode("12D3KooWQ9j3Ur5V9U63Vi6ved72TcA3sv34k74W3wpW5rwNvDc3")
+	require.NoError(t, err)
+	xps.Providers[0].PeerID = otherID.String()
+	_, err = xps.Infos(configRoot)
+	require.ErrorContains(t, err, "does not match")
+
+	// Override requires a context ID.
+	xps.Providers[0].PeerID = peerID.String()
+	xps.Override = true
+	_, err = xps.Infos(configRoot)
+	require.Error(t, err)
+	xps.ContextID = base64.StdEncoding.EncodeToString([]byte("fish"))
+	_, err = xps.Infos(configRoot)
+	require.NoError(t, err)
+}
+
+func TestExtendedProvidersLoadKey(t *testing.T) {
+	configRoot := t.TempDir()
+	writeKey := func(name string) peer.ID {
+		priv, _, err := ic.GenerateEd25519Key(rand.Reader)
+		require.NoError(t, err)
+		peerID, err := peer.IDFromPrivateKey(priv)
+		require.NoError(t, err)
+		pkb, err := ic.MarshalPrivateKey(priv)
+		require.NoError(t, err)
+		if name == "" {
+			name = filepath.Join("keys", peerID.String())
+		}
+		require.NoError(t, os.MkdirAll(filepath.Join(configRoot, filepath.Dir(name)), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(configRoot, name), pkb, 0600))
+		return peerID
+	}
+	configuredID := writeKey("xp.key")
+	managedID := writeKey("")
+
+	xps := ExtendedProviders{
+		Providers: []ExtendedProvider{{
+			PeerID:      configuredID.String(),
+			PrivKeyPath: "xp.key",
+		}},
+	}
+	for _, id := range []peer.ID{configuredID, managedID} {
+		priv, err := xps.LoadKey(configRoot, id)
+		require.NoError(t, err)
+		if id == managedID {
+			// Not loaded without KeysDir.
+			require.Nil(t, priv)
+			xps.KeysDir = "keys"
+			priv, err = xps.LoadKey(configRoot, id)
+			require.NoError(t, err)
+		}
+		require.NotNil(t, priv)
+		keyID, err := peer.IDFromPrivateKey(priv)
+		require.NoError(t, err)
+		require.Equal(t, id, keyID)
+	}
+
+	unknownID, err := peer.Decode("12D3KooWQ9j3Ur5V9U63Vi6ved72TcA3sv34k74W3wpW5rwNvDc3")
+	require.NoError(t, err)
+	priv, err := xps.LoadKey(configRoot, unknownID)
+	require.NoError(t, err)
+	require.Nil(t, priv)
+}
@@ -22,11 +22,15 @@ var listCarSubCmd = &cli.Command{
 	Action: doListCars,
 	Flags: []cli.Flag{
 		adminAPIFlag,
+		adminTokenFlag,
+		adminTLSCAFlag,
+		adminTLSCertFlag,
+		adminTLSKeyFlag,
 	},
 }
 
 func doListCars(cctx *cli.Context) error {
-	resp, err := http.Get(adminAPIFlagValue + "/admin/list/car")
+	resp, err := doHttpGetReq(cctx.Context, adminAPIFlagValue+"/admin/list/car")
 	if err != nil {
 		return err
 	}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	leveldb "github.com/ipfs/go-ds-leveldb"
 	"github.com/ipni/index-provider/metrics"
@@ -12,6 +17,7 @@ import (
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multicodec"
 	"github.com/multiformats/go-multihash"
 	"github.com/urfave/cli/v2"
@@ -36,6 +42,19 @@ var Mirror struct {
 		skipRemapOnEntriesTypeMatch *cli.BoolFlag
 		alwaysReSignAds             *cli.BoolFlag
 		metricsListenAddr           *cli.StringFlag
+		statusListenAddr            *cli.StringFlag
+		failurePolicy               *cli.StringFlag
+		forkPolicy                  *cli.StringFlag
+		forkDetectionDepth          *cli.UintFlag
+		failureRetries              *cli.UintFlag
+		failureRetryBackoff         *cli.DurationFlag
+		syncOnAnnounce              *cli.BoolFlag
+		announceListenAddr          *cli.StringFlag
+		filterProtocols             *cli.StringSliceFlag
+		filterContextIDPrefixes     *cli.StringSliceFlag
+		excludeExtendedProviders    *cli.StringSliceFlag
+		rewriteAddrs                *cli.StringSliceFlag
+		rewriteMetadata             *cli.StringSliceFlag
 	}
 
 	source  *peer.AddrInfo
@@ -125,6 +144,74 @@ func init() {
 		Usage: "The listen address on which metrics are exposed",
 		Value: "0.0.0.0:8989",
 	}
+	Mirror.flags.statusListenAddr = &cli.StringFlag{
+		Name:        "statusListenAddr",
+		Usage:       "The listen address on which the mirror progress is exposed as JSON, at the /status path.",
+		DefaultText: "Status is not exposed",
+	}
+	Mirror.flags.failurePolicy = &cli.StringFlag{
+		Name: "failurePolicy",
+		Usage: "What to do when an advertisement fails to be mirrored after any retries: " +
+			"`skip` it and record it as skipped, or `halt` and retry from it at the next sync.",
+		DefaultText: "skip",
+	}
+	Mirror.flags.forkPolicy = &cli.StringFlag{
+		Name: "forkPolicy",
+		Usage: "What to do when the source chain no longer links back to the advertisements already mirrored: " +
+			"`remirror` the new chain from scratch, or `halt` and keep serving the mirrored chain.",
+		DefaultText: "remirror",
+	}
+	Mirror.flags.forkDetectionDepth = &cli.UintFlag{
+		Name:        "forkDetectionDepth",
+		Usage:       "The number of advertisements synced from the source without linking back to the advertisements already mirrored, after which the source chain is handled as forked.",
+		DefaultText: "Forked only once the first advertisement of the source chain is synced",
+	}
+	Mirror.flags.failureRetries = &cli.UintFlag{
+		Name:        "failureRetries",
+		Usage:       "The number of times to retry mirroring an advertisement that failed for a transient reason, such as a failure to sync its entries, before the failure policy applies.",
+		DefaultText: "No retries",
+	}
+	Mirror.flags.failureRetryBackoff = &cli.DurationFlag{
+		Name:  "failureRetryBackoff",
+		Usage: "The wait before the first retry of a failed advertisement, doubled after each retry.",
+		Value: time.Second,
+	}
+	Mirror.flags.syncOnAnnounce = &cli.BoolFlag{
+		Name:  "syncOnAnnounce",
+		Usage: "Whether to sync immediately when the source announces over gossipsub, in addition to syncing at every sync interval.",
+	}
+	Mirror.flags.announceListenAddr = &cli.StringFlag{
+		Name:        "announceListenAddr",
+		Usage:       "The listen address on which to receive announcements sent by the source over HTTP, at the /announce path.",
+		DefaultText: "Announcements over HTTP are not received",
+	}
+	Mirror.flags.filterProtocols = &cli.StringSliceFlag{
+		Name:        "filterProtocol",
+		Usage:       "Only mirror advertisements whose metadata includes one of the given transport protocols, by multicodec name, e.g. `transport-bitswap`.",
+		DefaultText: "Advertisements are not filtered by protocol",
+	}
+	Mirror.flags.filterContextIDPrefixes = &cli.StringSliceFlag{
+		Name:        "filterContextIDPrefix",
+		Usage:       "Only mirror advertisements whose context ID starts with one of the given base64 encoded prefixes.",
+		DefaultText: "Advertisements are not filtered by context ID",
+	}
+	Mirror.flags.excludeExtendedProviders = &cli.StringSliceFlag{
+		Name:        "excludeExtendedProvider",
+		Usage:       "Do not mirror advertisements that list the given peer ID as an extended provider.",
+		DefaultText: "Advertisements are not filtered by extended provider",
+	}
+	Mirror.flags.rewriteAddrs = &cli.StringSliceFlag{
+		Name: "rewriteAddr",
+		Usage: "Rewrites the advertised addresses that start with a multiaddr prefix, as `prefix=replacement`. " +
+			"An empty prefix matches all addresses. The first matching rule applies.",
+		DefaultText: "Addresses are not rewritten",
+	}
+	Mirror.flags.rewriteMetadata = &cli.StringSliceFlag{
+		Name: "rewriteMetadata",
+		Usage: "Replaces the metadata of a transport protocol, as `protocol=replacement`, where protocol is a multicodec name " +
+			"and replacement is base64 encoded metadata. An empty replacement removes the protocol.",
+		DefaultText: "Metadata is not rewritten",
+	}
 	Mirror.Command = &cli.Command{
 		Name:  "mirror",
 		Usage: "Mirrors the advertisement chain from an existing index provider.",
@@ -145,10 +232,47 @@ func init() {
 			Mirror.flags.skipRemapOnEntriesTypeMatch,
 			Mirror.flags.alwaysReSignAds,
 			Mirror.flags.metricsListenAddr,
+			Mirror.flags.statusListenAddr,
+			Mirror.flags.failurePolicy,
+			Mirror.flags.forkPolicy,
+			Mirror.flags.forkDetectionDepth,
+			Mirror.flags.failureRetries,
+			Mirror.flags.failureRetryBackoff,
+			Mirror.flags.syncOnAnnounce,
+			Mirror.flags.announceListenAddr,
+			Mirror.flags.filterProtocols,
+			Mirror.flags.filterContextIDPrefixes,
+			Mirror.flags.excludeExtendedProviders,
+			Mirror.flags.rewriteAddrs,
+			Mirror.flags.rewriteMetadata,
 		},
 		Before: beforeMirror,
 		Action: doMirror,
 	}
+	runMirrorManagerSubCmd.Flags = append(runMirrorManagerSubCmd.Flags,
+		Mirror.flags.metricsListenAddr,
+		Mirror.flags.syncInterval,
+		Mirror.flags.initAdRecurLimit,
+		Mirror.flags.entriesRecurLimit,
+		Mirror.flags.remapWithEntryChunkSize,
+		Mirror.flags.remapWithHamtHashFunc,
+		Mirror.flags.remapWithHamtBitWidth,
+		Mirror.flags.remapWithHamtBucketSize,
+		Mirror.flags.topic,
+		Mirror.flags.skipRemapOnEntriesTypeMatch,
+		Mirror.flags.alwaysReSignAds,
+		Mirror.flags.failurePolicy,
+		Mirror.flags.forkPolicy,
+		Mirror.flags.forkDetectionDepth,
+		Mirror.flags.failureRetries,
+		Mirror.flags.failureRetryBackoff,
+		Mirror.flags.syncOnAnnounce,
+		Mirror.flags.filterProtocols,
+		Mirror.flags.filterContextIDPrefixes,
+		Mirror.flags.excludeExtendedProviders,
+		Mirror.flags.rewriteAddrs,
+		Mirror.flags.rewriteMetadata,
+	)
 }
 
 func beforeMirror(cctx *cli.Context) error {
@@ -157,9 +281,6 @@ func beforeMirror(cctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	if cctx.IsSet(Mirror.flags.syncInterval.Name) {
-		Mirror.options = append(Mirror.options, mirror.WithSyncInterval(Mirror.flags.syncInterval.Get(cctx)))
-	}
 	var hostOpts []libp2p.Option
 	var pk crypto.PrivKey
 	if cctx.IsSet(Mirror.flags.identityPath.Name) {
@@ -197,13 +318,33 @@ func beforeMirror(cctx *cli.Context) error {
 		}
 		Mirror.options = append(Mirror.options, mirror.WithDatastore(ds))
 	}
+	opts, err := mirrorOptionsFromFlags(cctx)
+	if err != nil {
+		return err
+	}
+	Mirror.options = append(Mirror.options, opts...)
+	if cctx.IsSet(Mirror.flags.announceListenAddr.Name) {
+		a := Mirror.flags.announceListenAddr.Get(cctx)
+		Mirror.options = append(Mirror.options, mirror.WithAnnounceHTTPListenAddr(a))
+	}
+	return nil
+}
+
+// mirrorOptionsFromFlags returns the mirror options set by the flags that
+// apply to every mirrored source, shared by the mirror and mirror-manager
+// commands.
+func mirrorOptionsFromFlags(cctx *cli.Context) ([]mirror.Option, error) {
+	var opts []mirror.Option
+	if cctx.IsSet(Mirror.flags.syncInterval.Name) {
+		opts = append(opts, mirror.WithSyncInterval(Mirror.flags.syncInterval.Get(cctx)))
+	}
 	if cctx.IsSet(Mirror.flags.initAdRecurLimit.Name) {
 		limit := int64(Mirror.flags.initAdRecurLimit.Get(cctx))
-		Mirror.options = append(Mirror.options, mirror.WithInitialAdRecursionLimit(limit))
+		opts = append(opts, mirror.WithInitialAdRecursionLimit(limit))
 	}
 	if cctx.IsSet(Mirror.flags.entriesRecurLimit.Name) {
 		limit := int64(Mirror.flags.entriesRecurLimit.Get(cctx))
-		Mirror.options = append(Mirror.options, mirror.WithEntriesRecursionLimit(limit))
+		opts = append(opts, mirror.WithEntriesRecursionLimit(limit))
 	}
 
 	remapEC := cctx.IsSet(Mirror.flags.remapWithEntryChunkSize.Name)
@@ -212,12 +353,12 @@ func beforeMirror(cctx *cli.Context) error {
 	remapHamtBS := cctx.IsSet(Mirror.flags.remapWithHamtBucketSize.Name)
 	switch {
 	case remapEC && (remapHamtBS || remapHamtBW || remapHamtHF):
-		return errors.New("only one entry remap kind can be specified; both EntryChunk and HAMT flags are set")
+		return nil, errors.New("only one entry remap kind can be specified; both EntryChunk and HAMT flags are set")
 	case remapHamtBS != remapHamtBW || remapHamtBS != remapHamtHF:
-		return errors.New("to remap entries as HAMT all three of hash function, bit-width and bucket size flags must be set")
+		return nil, errors.New("to remap entries as HAMT all three of hash function, bit-width and bucket size flags must be set")
 	case remapEC:
 		chunkSize := Mirror.flags.remapWithEntryChunkSize.Get(cctx)
-		Mirror.options = append(Mirror.options, mirror.WithEntryChunkRemapper(int(chunkSize)))
+		opts = append(opts, mirror.WithEntryChunkRemapper(int(chunkSize)))
 	case remapHamtBS && remapHamtBW && remapHamtHF:
 		hf := Mirror.flags.remapWithHamtHashFunc.Get(cctx)
 		bw := Mirror.flags.remapWithHamtBitWidth.Get(cctx)
@@ -225,23 +366,137 @@ func beforeMirror(cctx *cli.Context) error {
 
 		mhc, ok := multihash.Names[hf]
 		if !ok {
-			return fmt.Errorf("no multihash code found with name: %s", hf)
+			return nil, fmt.Errorf("no multihash code found with name: %s", hf)
 		}
-		Mirror.options = append(Mirror.options, mirror.WithHamtRemapper(multicodec.Code(mhc), int(bw), int(bs)))
+		opts = append(opts, mirror.WithHamtRemapper(multicodec.Code(mhc), int(bw), int(bs)))
 	}
 	if cctx.IsSet(Mirror.flags.topic.Name) {
 		topic := Mirror.flags.topic.Get(cctx)
-		Mirror.options = append(Mirror.options, mirror.WithTopicName(topic))
+		opts = append(opts, mirror.WithTopicName(topic))
 	}
 	if cctx.IsSet(Mirror.flags.skipRemapOnEntriesTypeMatch.Name) {
 		s := Mirror.flags.skipRemapOnEntriesTypeMatch.Get(cctx)
-		Mirror.options = append(Mirror.options, mirror.WithSkipRemapOnEntriesTypeMatch(s))
+		opts = append(opts, mirror.WithSkipRemapOnEntriesTypeMatch(s))
 	}
 	if cctx.IsSet(Mirror.flags.alwaysReSignAds.Name) {
 		r := Mirror.flags.alwaysReSignAds.Get(cctx)
-		Mirror.options = append(Mirror.options, mirror.WithAlwaysReSignAds(r))
+		opts = append(opts, mirror.WithAlwaysReSignAds(r))
 	}
-	return nil
+	if cctx.IsSet(Mirror.flags.failurePolicy.Name) {
+		p, err := mirror.ParseFailurePolicy(Mirror.flags.failurePolicy.Get(cctx))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, mirror.WithFailurePolicy(p))
+	}
+	if cctx.IsSet(Mirror.flags.forkPolicy.Name) {
+		p, err := mirror.ParseForkPolicy(Mirror.flags.forkPolicy.Get(cctx))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, mirror.WithForkPolicy(p))
+	}
+	if cctx.IsSet(Mirror.flags.forkDetectionDepth.Name) {
+		depth := int64(Mirror.flags.forkDetectionDepth.Get(cctx))
+		opts = append(opts, mirror.WithForkDetectionDepth(depth))
+	}
+	if cctx.IsSet(Mirror.flags.failureRetries.Name) {
+		retries := int(Mirror.flags.failureRetries.Get(cctx))
+		backoff := Mirror.flags.failureRetryBackoff.Get(cctx)
+		opts = append(opts, mirror.WithFailureRetries(retries, backoff))
+	}
+	if cctx.IsSet(Mirror.flags.syncOnAnnounce.Name) {
+		a := Mirror.flags.syncOnAnnounce.Get(cctx)
+		opts = append(opts, mirror.WithSyncOnAnnounce(a))
+	}
+	filter, err := mirrorFilterFromFlags(cctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		opts = append(opts, mirror.WithFilter(*filter))
+	}
+	rewrite, err := mirrorRewriteFromFlags(cctx)
+	if err != nil {
+		return nil, err
+	}
+	if rewrite != nil {
+		opts = append(opts, mirror.WithRewrite(*rewrite))
+	}
+	return opts, nil
+}
+
+// mirrorRewriteFromFlags returns the rewrite rules set by the rewrite flags,
+// or nil if none are set.
+func mirrorRewriteFromFlags(cctx *cli.Context) (*mirror.Rewrite, error) {
+	var rewrite mirror.Rewrite
+	for _, rule := range Mirror.flags.rewriteAddrs.Get(cctx) {
+		match, replace, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad address rewrite %q: must be prefix=replacement", rule)
+		}
+		var r mirror.AddrRewrite
+		var err error
+		if match != "" {
+			if r.Match, err = multiaddr.NewMultiaddr(match); err != nil {
+				return nil, fmt.Errorf("bad address rewrite prefix %q: %w", match, err)
+			}
+		}
+		if r.Replace, err = multiaddr.NewMultiaddr(replace); err != nil {
+			return nil, fmt.Errorf("bad address rewrite replacement %q: %w", replace, err)
+		}
+		rewrite.Addresses = append(rewrite.Addresses, r)
+	}
+	for _, rule := range Mirror.flags.rewriteMetadata.Get(cctx) {
+		protocol, replacement, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad metadata rewrite %q: must be protocol=replacement", rule)
+		}
+		var r mirror.MetadataRewrite
+		if err := r.Protocol.Set(protocol); err != nil {
+			return nil, fmt.Errorf("bad metadata rewrite protocol %q: %w", protocol, err)
+		}
+		var err error
+		if r.Replacement, err = base64.StdEncoding.DecodeString(replacement); err != nil {
+			return nil, fmt.Errorf("bad metadata rewrite replacement %q: %w", replacement, err)
+		}
+		rewrite.Metadata = append(rewrite.Metadata, r)
+	}
+	if len(rewrite.Addresses) == 0 && len(rewrite.Metadata) == 0 {
+		return nil, nil
+	}
+	return &rewrite, nil
+}
+
+// mirrorFilterFromFlags returns the filter set by the filter flags, or nil if
+// none are set.
+func mirrorFilterFromFlags(cctx *cli.Context) (*mirror.Filter, error) {
+	var filter mirror.Filter
+	for _, name := range Mirror.flags.filterProtocols.Get(cctx) {
+		var code multicodec.Code
+		if err := code.Set(name); err != nil {
+			return nil, fmt.Errorf("bad filter protocol %q: %w", name, err)
+		}
+		filter.Protocols = append(filter.Protocols, code)
+	}
+	for _, p := range Mirror.flags.filterContextIDPrefixes.Get(cctx) {
+		prefix, err := base64.StdEncoding.DecodeString(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad filter context id prefix %q: %w", p, err)
+		}
+		filter.ContextIDPrefixes = append(filter.ContextIDPrefixes, prefix)
+	}
+	for _, p := range Mirror.flags.excludeExtendedProviders.Get(cctx) {
+		peerID, err := peer.Decode(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad excluded extended provider %q: %w", p, err)
+		}
+		filter.ExcludedExtendedProviders = append(filter.ExcludedExtendedProviders, peerID)
+	}
+	if len(filter.Protocols) == 0 && len(filter.ContextIDPrefixes) == 0 && len(filter.ExcludedExtendedProviders) == 0 {
+		return nil, nil
+	}
+	return &filter, nil
 }
 
 func doMirror(cctx *cli.Context) error {
@@ -261,7 +516,30 @@ func doMirror(cctx *cli.Context) error {
 		return err
 	}
 
+	var statusServer *http.Server
+	if cctx.IsSet(Mirror.flags.statusListenAddr.Name) {
+		statusListener, err := net.Listen("tcp", Mirror.flags.statusListenAddr.Get(cctx))
+		if err != nil {
+			return err
+		}
+		statusServer = &http.Server{
+			Handler:     m.StatusHandler(),
+			ReadTimeout: 30 * time.Second,
+		}
+		go func() {
+			log.Infow("Exposing mirror status", "addr", statusListener.Addr())
+			if err := statusServer.Serve(statusListener); err != http.ErrServerClosed {
+				log.Errorw("Failed to serve mirror status", "err", err)
+			}
+		}()
+	}
+
 	<-cctx.Done()
+	if statusServer != nil {
+		if err := statusServer.Close(); err != nil {
+			log.Debugw("Failed to shut down mirror status server", "err", err)
+		}
+	}
 	if err := msvr.Shutdown(context.Background()); err != nil {
 		log.Debugw("Failed to shut down metrics server", "err", err)
 	}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/metrics"
+	"github.com/ipni/index-provider/mirror"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	mirrorAdminFlagValue string
+	mirrorAdminFlag      = &cli.StringFlag{
+		Name:        "mirror-admin",
+		Usage:       "Admin HTTP API address of the mirror manager",
+		EnvVars:     []string{"PROVIDER_MIRROR_ADMIN"},
+		Value:       "http://localhost:3107",
+		Destination: &mirrorAdminFlagValue,
+	}
+)
+
+var MirrorManagerCmd = &cli.Command{
+	Name:  "mirror-manager",
+	Usage: "Mirrors the advertisement chains of multiple index providers in one process.",
+	Description: `The mirror manager runs one mirror per source provider, with shared storage,
+HTTP listener and metrics server. The advertisements mirrored from each source
+are served under the path of the source peer ID, for example:
+
+    http://<listenAddr>/<source-peer-id>/ipni/v1/ad/head
+
+Announcements sent over HTTP by any source are received at /announce.
+
+The sources are read from a JSON file when the manager starts, and again when it
+receives SIGHUP, upon which sources no longer in the file are removed. Sources
+can also be listed, added and removed while the manager runs with the list, add
+and remove subcommands; such changes are not written to the file.`,
+	Subcommands: []*cli.Command{
+		runMirrorManagerSubCmd,
+		listMirrorSourcesSubCmd,
+		addMirrorSourceSubCmd,
+		removeMirrorSourceSubCmd,
+	},
+}
+
+var runMirrorManagerSubCmd = &cli.Command{
+	Name:   "run",
+	Usage:  "Runs the mirror manager.",
+	Action: doRunMirrorManager,
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:     "sources",
+			Usage:    "Path to the JSON file listing the sources to mirror.",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "listenAddr",
+			Usage: "The HTTP listen address on which mirrored advertisements are served and announcements received.",
+			Value: "0.0.0.0:3106",
+		},
+		&cli.StringFlag{
+			Name:  "adminListenAddr",
+			Usage: "The HTTP listen address of the admin API, used to list, add and remove sources.",
+			Value: "127.0.0.1:3107",
+		},
+		&cli.PathFlag{
+			Name:        "storePath",
+			Usage:       "The path at which to persist the data of all mirrors.",
+			DefaultText: "Ephemeral in-memory storage",
+		},
+		// The flags shared with the mirror command are added when they are
+		// created; see the init function of the mirror command.
+	},
+}
+
+var listMirrorSourcesSubCmd = &cli.Command{
+	Name:   "list",
+	Usage:  "Lists the sources mirrored by a running mirror manager.",
+	Action: doListMirrorSources,
+	Flags: []cli.Flag{
+		mirrorAdminFlag,
+	},
+}
+
+var addMirrorSourceSubCmd = &cli.Command{
+	Name:      "add",
+	Usage:     "Adds a source to a running mirror manager.",
+	ArgsUsage: "<source-multiaddr-with-peer-id>",
+	Action:    doAddMirrorSource,
+	Flags: []cli.Flag{
+		mirrorAdminFlag,
+	},
+}
+
+var removeMirrorSourceSubCmd = &cli.Command{
+	Name:      "remove",
+	Usage:     "Removes a source from a running mirror manager. The data mirrored from it is kept.",
+	ArgsUsage: "<source-peer-id>",
+	Action:    doRemoveMirrorSource,
+	Flags: []cli.Flag{
+		mirrorAdminFlag,
+	},
+}
+
+func doRunMirrorManager(cctx *cli.Context) error {
+	sourcesPath := cctx.Path("sources")
+	sources, err := config.LoadMirrorSources(sourcesPath)
+	if err != nil {
+		return err
+	}
+	mirrorOpts, err := mirrorOptionsFromFlags(cctx)
+	if err != nil {
+		return err
+	}
+
+	var ds datastore.Batching
+	if cctx.IsSet("storePath") {
+		ds, err = leveldb.NewDatastore(cctx.Path("storePath"), nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		ds = dssync.MutexWrap(datastore.NewMapDatastore())
+	}
+	defer ds.Close()
+
+	mgr, err := mirror.NewManager(ds,
+		mirror.WithManagerHTTPListenAddr(cctx.String("listenAddr")),
+		mirror.WithMirrorOptions(mirrorOpts...))
+	if err != nil {
+		return err
+	}
+	if err = syncMirrorSources(cctx.Context, mgr, sources); err != nil {
+		_ = mgr.Shutdown()
+		return err
+	}
+
+	msvr, err := metrics.NewServer(Mirror.flags.metricsListenAddr.Get(cctx))
+	if err != nil {
+		return err
+	}
+	if err = msvr.Start(); err != nil {
+		return err
+	}
+	if err = mgr.Start(); err != nil {
+		return err
+	}
+
+	adminListener, err := net.Listen("tcp", cctx.String("adminListenAddr"))
+	if err != nil {
+		return err
+	}
+	adminServer := &http.Server{
+		Handler:     mgr.AdminHandler(),
+		ReadTimeout: 30 * time.Second,
+	}
+	go func() {
+		if err := adminServer.Serve(adminListener); err != http.ErrServerClosed {
+			log.Errorw("Failed to serve mirror manager admin API", "err", err)
+		}
+	}()
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	for done := false; !done; {
+		select {
+		case <-reload:
+			log.Info("Reloading mirror sources")
+			sources, err := config.LoadMirrorSources(sourcesPath)
+			if err != nil {
+				log.Errorw("Failed to reload mirror sources", "err", err)
+				continue
+			}
+			if err = syncMirrorSources(cctx.Context, mgr, sources); err != nil {
+				log.Errorw("Failed to apply mirror sources", "err", err)
+			}
+		case <-cctx.Done():
+			done = true
+		}
+	}
+
+	if err := adminServer.Close(); err != nil {
+		log.Debugw("Failed to shut down mirror manager admin server", "err", err)
+	}
+	if err := msvr.Shutdown(context.Background()); err != nil {
+		log.Debugw("Failed to shut down metrics server", "err", err)
+	}
+	return mgr.Shutdown()
+}
+
+// syncMirrorSources adds the sources that are not mirrored by the manager, and
+// removes the mirrored sources that are not listed.
+func syncMirrorSources(ctx context.Context, mgr *mirror.Manager, sources *config.MirrorSources) error {
+	listed := make(map[peer.ID]struct{}, len(sources.Sources))
+	var errs error
+	for _, s := range sources.Sources {
+		source, err := s.AddrInfo()
+		if err != nil {
+			return err
+		}
+		listed[source.ID] = struct{}{}
+		if _, ok := mgr.Mirror(source.ID); ok {
+			continue
+		}
+		var opts []mirror.Option
+		priv, err := s.PrivKey()
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		if priv != nil {
+			opts = append(opts, mirror.WithHost(nil, priv))
+		}
+		if err = mgr.Add(ctx, *source, opts...); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("cannot add mirror source %s: %w", source.ID, err))
+		}
+	}
+	for _, source := range mgr.Sources() {
+		if _, ok := listed[source.ID]; !ok {
+			if err := mgr.Remove(source.ID); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("cannot remove mirror source %s: %w", source.ID, err))
+			}
+		}
+	}
+	return errs
+}
+
+func doListMirrorSources(cctx *cli.Context) error {
+	resp, err := http.Get(mirrorAdminFlagValue + "/admin/sources")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var sources []peer.AddrInfo
+	if err := json.NewDecoder(resp.Body).Decode(&sources); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	for _, source := range sources {
+		addrs, err := peer.AddrInfoToP2pAddrs(&source)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cctx.App.Writer, source.ID)
+		for _, a := range addrs {
+			fmt.Fprintf(cctx.App.Writer, "\t%s\n", a)
+		}
+	}
+	return nil
+}
+
+func doAddMirrorSource(cctx *cli.Context) error {
+	if cctx.NArg() != 1 {
+		return errors.New("exactly one source multiaddr must be specified")
+	}
+	source, err := peer.AddrInfoFromString(cctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("bad source multiaddr: %w", err)
+	}
+
+	resp, err := doHttpPostReq(cctx.Context, mirrorAdminFlagValue+"/admin/sources/add", source)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+	_, err = fmt.Fprintf(cctx.App.Writer, "Added mirror source %s\n", source.ID)
+	return err
+}
+
+func doRemoveMirrorSource(cctx *cli.Context) error {
+	if cctx.NArg() != 1 {
+		return errors.New("exactly one source peer ID must be specified")
+	}
+	sourceID, err := peer.Decode(cctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("bad source peer id: %w", err)
+	}
+
+	resp, err := doHttpPostReq(cctx.Context, mirrorAdminFlagValue+"/admin/sources/remove", mirror.RemoveSourceRequest{ID: sourceID})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+	_, err = fmt.Fprintf(cctx.App.Writer, "Removed mirror source %s\n", sourceID)
+	return err
+}
@@ -42,14 +42,21 @@ func run() int {
 		Commands: []*cli.Command{
 			AnnounceCmd,
 			AnnounceHttpCmd,
+			ChainCmd,
 			ConnectCmd,
 			DaemonCmd,
+			FindCmd,
+			FsckCmd,
+			IdentityCmd,
 			ImportCmd,
 			IndexCmd,
 			InitCmd,
 			ListCmd,
 			RemoveCmd,
+			RetrievalPolicyCmd,
 			Mirror.Command,
+			MirrorManagerCmd,
+			XProvidersCmd,
 		},
 	}
 
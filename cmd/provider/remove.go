@@ -44,6 +44,10 @@ specified, they key is simply calculated as the SHA_256 hash of the given path.`
 
 	removeCarFlags = []cli.Flag{
 		adminAPIFlag,
+		adminTokenFlag,
+		adminTLSCAFlag,
+		adminTLSCertFlag,
+		adminTLSKeyFlag,
 		optionalCarPathFlag,
 		keyFlag,
 	}
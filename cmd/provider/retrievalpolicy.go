@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ipni/index-provider/engine/peerutil"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+)
+
+var RetrievalPolicyCmd = &cli.Command{
+	Name:  "retrieval-policy",
+	Usage: "Shows or changes which peers may retrieve over graphsync, and their limits.",
+	Description: `The retrieval policy decides which peers may retrieve content from the provider
+daemon over graphsync, the number of concurrent transfers to each peer, and the
+bandwidth of each peer.
+
+Changes made with this command are not written to the config file. The policy
+configured in ProviderServer.RetrievalPolicy is restored when the daemon
+restarts.`,
+	Subcommands: []*cli.Command{
+		showRetrievalPolicySubCmd,
+		setRetrievalPolicySubCmd,
+	},
+}
+
+var showRetrievalPolicySubCmd = &cli.Command{
+	Name:   "show",
+	Usage:  "Shows the current retrieval policy.",
+	Action: doShowRetrievalPolicy,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		adminTokenFlag,
+		adminTLSCAFlag,
+		adminTLSCertFlag,
+		adminTLSKeyFlag,
+	},
+}
+
+var setRetrievalPolicySubCmd = &cli.Command{
+	Name:  "set",
+	Usage: "Changes the retrieval policy. Settings not specified are left unchanged.",
+	Description: `Peers are allowed or denied on top of the current policy, unless
+--allow-by-default is specified, which replaces the current peer policy.`,
+	Action: doSetRetrievalPolicy,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		adminTokenFlag,
+		adminTLSCAFlag,
+		adminTLSCertFlag,
+		adminTLSKeyFlag,
+		&cli.BoolFlag{
+			Name:  "allow-by-default",
+			Usage: "Whether peers not otherwise listed are allowed to retrieve. Clears the peers previously allowed or denied.",
+		},
+		&cli.StringSliceFlag{
+			Name:  "allow-peer",
+			Usage: "Peer ID to allow to retrieve.",
+		},
+		&cli.StringSliceFlag{
+			Name:  "deny-peer",
+			Usage: "Peer ID to deny from retrieving.",
+		},
+		&cli.IntFlag{
+			Name:  "max-transfers",
+			Usage: "Maximum number of concurrent transfers to a peer. Zero means no limit.",
+		},
+		&cli.IntFlag{
+			Name:  "max-bytes-per-second",
+			Usage: "Maximum number of bytes per second sent to a peer. Zero means no limit.",
+		},
+	},
+}
+
+func doShowRetrievalPolicy(cctx *cli.Context) error {
+	res, err := getRetrievalPolicy(cctx.Context)
+	if err != nil {
+		return err
+	}
+	return printRetrievalPolicy(cctx, res)
+}
+
+func doSetRetrievalPolicy(cctx *cli.Context) error {
+	req, err := getRetrievalPolicy(cctx.Context)
+	if err != nil {
+		return err
+	}
+
+	peers, err := peerutil.NewPolicyStrings(req.Allow, req.Except)
+	if err != nil {
+		return err
+	}
+	if cctx.IsSet("allow-by-default") {
+		peers = peerutil.NewPolicy(cctx.Bool("allow-by-default"))
+	}
+	for _, flag := range []string{"allow-peer", "deny-peer"} {
+		for _, s := range cctx.StringSlice(flag) {
+			peerID, err := peer.Decode(s)
+			if err != nil {
+				return fmt.Errorf("bad peer id %q: %w", s, err)
+			}
+			peers.SetPeer(peerID, flag == "allow-peer")
+		}
+	}
+	req.Allow = peers.Default()
+	req.Except = peers.ExceptStrings()
+
+	if cctx.IsSet("max-transfers") {
+		req.MaxTransfersPerPeer = cctx.Int("max-transfers")
+	}
+	if cctx.IsSet("max-bytes-per-second") {
+		req.MaxBytesPerSecondPerPeer = cctx.Int("max-bytes-per-second")
+	}
+	if req.MaxTransfersPerPeer < 0 || req.MaxBytesPerSecondPerPeer < 0 {
+		return errors.New("limits must not be negative")
+	}
+
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/retrievalpolicy/set", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.RetrievalPolicy
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	return printRetrievalPolicy(cctx, &res)
+}
+
+func getRetrievalPolicy(ctx context.Context) (*adminserver.RetrievalPolicy, error) {
+	resp, err := doHttpGetReq(ctx, adminAPIFlagValue+"/admin/retrievalpolicy")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errFromHttpResp(resp)
+	}
+
+	var res adminserver.RetrievalPolicy
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return nil, fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	return &res, nil
+}
+
+func printRetrievalPolicy(cctx *cli.Context, res *adminserver.RetrievalPolicy) error {
+	var b bytes.Buffer
+	exceptLabel := "Denied"
+	if res.Allow {
+		b.WriteString("Peers: allowed by default\n")
+	} else {
+		b.WriteString("Peers: denied by default\n")
+		exceptLabel = "Allowed"
+	}
+	for _, p := range res.Except {
+		fmt.Fprintf(&b, "\t %s: %s\n", exceptLabel, p)
+	}
+	fmt.Fprintf(&b, "Max transfers per peer: %s\n", limitString(res.MaxTransfersPerPeer))
+	fmt.Fprintf(&b, "Max bytes per second per peer: %s\n", limitString(res.MaxBytesPerSecondPerPeer))
+	_, err := cctx.App.Writer.Write(b.Bytes())
+	return err
+}
+
+func limitString(limit int) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(limit)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// chainDatastore returns the datastore in which the engine keeps the
+// advertisement chain.
+func chainDatastore(ds datastore.Batching, ns string) datastore.Batching {
+	if ns == "" {
+		return ds
+	}
+	return namespace.Wrap(ds, datastore.NewKey(ns))
+}
+
+// rotationNamespace returns the datastore namespace of the advertisement
+// chain of the given identity.
+func rotationNamespace(id peer.ID) string {
+	return "/identity/" + id.String()
+}
+
+// rotateIdentity hands over the content advertised by eng to the identity
+// being rotated to, and once done replaces the identity in the config file.
+// The new identity is used the next time the daemon starts.
+//
+// The engine of the new identity is built with engOpts, the options of eng
+// that do not depend on the identity, but does not publish: the publisher of
+// eng already serves the listen address and static directory, and the chain of
+// the new identity is published once the daemon restarts with it.
+func rotateIdentity(ctx context.Context, cfg *config.Config, eng *engine.Engine, ds datastore.Batching, lister provider.MultihashLister, addrs []multiaddr.Multiaddr, engOpts []engine.Option) error {
+	rotation := cfg.Identity.Rotation
+	newID, newKey, err := rotation.Identity.DecodeOrCreate(io.Discard)
+	if err != nil {
+		return fmt.Errorf("cannot decode new identity: %w", err)
+	}
+	ns := rotationNamespace(newID)
+
+	// The provider is set before engOpts so that the configured retrieval
+	// addresses take precedence over the host addresses.
+	opts := append([]engine.Option{
+		engine.WithDatastore(chainDatastore(ds, ns)),
+		engine.WithPrivateKey(newKey),
+		engine.WithProvider(peer.AddrInfo{ID: newID, Addrs: addrs}),
+	}, engOpts...)
+	opts = append(opts, engine.WithPublisherKind(engine.NoPublisher))
+	newEng, err := engine.New(opts...)
+	if err != nil {
+		return err
+	}
+	if err = newEng.Start(ctx); err != nil {
+		return err
+	}
+	defer newEng.Shutdown()
+	newEng.RegisterMultihashLister(lister)
+
+	log.Infow("Rotating provider identity", "to", newID, "mode", rotation.Mode)
+	if err = engine.RotateIdentity(ctx, eng, newEng, engine.RotateMode(rotation.Mode)); err != nil {
+		return err
+	}
+
+	// Switch to the new identity in the config file, reloaded in case it
+	// changed since the daemon started.
+	configFile, err := config.Filename("")
+	if err != nil {
+		return err
+	}
+	fileCfg, err := config.Load(configFile)
+	if err != nil {
+		return err
+	}
+	fileCfg.Identity = rotation.Identity
+	fileCfg.Datastore.ChainNamespace = ns
+	if err = fileCfg.Save(configFile); err != nil {
+		return fmt.Errorf("cannot save rotated identity to config: %w", err)
+	}
+	log.Infow("Provider identity rotated; restart the daemon to use the new identity", "peerID", newID)
+	return nil
+}
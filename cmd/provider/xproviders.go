@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var XProvidersCmd = &cli.Command{
+	Name:    "xproviders",
+	Aliases: []string{"xp"},
+	Usage:   "Manages the extended providers advertised by the provider daemon.",
+	Description: `Extended providers are alternative providers from which the content advertised
+by this provider can also be retrieved, for example an HTTP server or a bitswap
+peer. Each change publishes a new advertisement containing the full set of
+extended providers for the context ID, or for all content if no context ID is
+given.
+
+Changes made with this command are not written to the config file. The set
+configured in ExtendedProviders, if any, is re-published when the daemon
+restarts.`,
+	Subcommands: []*cli.Command{
+		listXProvidersSubCmd,
+		addXProvidersSubCmd,
+		removeXProvidersSubCmd,
+	},
+}
+
+var (
+	xpContextIDFlagValue string
+	xpContextIDFlag      = &cli.StringFlag{
+		Name:        "context-id",
+		Usage:       "Base64 encoded context ID to which the extended providers apply. If not set, they apply to all content.",
+		Aliases:     []string{"c"},
+		Destination: &xpContextIDFlagValue,
+	}
+)
+
+var listXProvidersSubCmd = &cli.Command{
+	Name:   "list",
+	Usage:  "Lists the extended providers most recently advertised.",
+	Action: doListXProviders,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		adminTokenFlag,
+		adminTLSCAFlag,
+		adminTLSCertFlag,
+		adminTLSKeyFlag,
+		xpContextIDFlag,
+	},
+}
+
+var addXProvidersSubCmd = &cli.Command{
+	Name:   "add",
+	Usage:  "Adds an extended provider and publishes the resulting set.",
+	Action: doAddXProvider,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		adminTokenFlag,
+		adminTLSCAFlag,
+		adminTLSCertFlag,
+		adminTLSKeyFlag,
+		xpContextIDFlag,
+		&cli.StringFlag{
+			Name:     "peer-id",
+			Usage:    "Peer ID of the extended provider.",
+			Aliases:  []string{"p"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "key-file",
+			Usage: "Path, on the daemon host, to the file containing the private key of the extended provider, which is read by the daemon. The file must be in ExtendedProviders.KeysDir. Required unless the daemon already holds the key or can load it from ExtendedProviders.KeysDir.",
+		},
+		addrFlag,
+		metadataFlag,
+	},
+}
+
+var removeXProvidersSubCmd = &cli.Command{
+	Name:   "remove",
+	Usage:  "Removes extended providers and publishes the resulting set.",
+	Action: doRemoveXProviders,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		adminTokenFlag,
+		adminTLSCAFlag,
+		adminTLSCertFlag,
+		adminTLSKeyFlag,
+		xpContextIDFlag,
+		&cli.StringSliceFlag{
+			Name:     "peer-id",
+			Usage:    "Peer ID of an extended provider to remove.",
+			Aliases:  []string{"p"},
+			Required: true,
+		},
+	},
+}
+
+func decodeXPContextID() ([]byte, error) {
+	if xpContextIDFlagValue == "" {
+		return nil, nil
+	}
+	contextID, err := base64.StdEncoding.DecodeString(xpContextIDFlagValue)
+	if err != nil {
+		return nil, errors.New("context ID is not a valid base64 encoded string")
+	}
+	return contextID, nil
+}
+
+func doListXProviders(cctx *cli.Context) error {
+	if _, err := decodeXPContextID(); err != nil {
+		return err
+	}
+	listURL := adminAPIFlagValue + "/admin/xproviders"
+	if xpContextIDFlagValue != "" {
+		listURL += "?contextID=" + url.QueryEscape(xpContextIDFlagValue)
+	}
+	resp, err := doHttpGetReq(cctx.Context, listURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.ListXProvidersRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	var b bytes.Buffer
+	if res.Override {
+		b.WriteString("Override: true\n")
+	}
+	for _, xp := range res.Providers {
+		b.WriteString(xp.PeerID)
+		b.WriteString("\n")
+		for _, a := range xp.Addrs {
+			b.WriteString("\t Address: ")
+			b.WriteString(a)
+			b.WriteString("\n")
+		}
+		if len(xp.Metadata) != 0 {
+			b.WriteString("\t Metadata: ")
+			b.WriteString(base64.StdEncoding.EncodeToString(xp.Metadata))
+			b.WriteString("\n")
+		}
+	}
+	_, err = cctx.App.Writer.Write(b.Bytes())
+	return err
+}
+
+func doAddXProvider(cctx *cli.Context) error {
+	contextID, err := decodeXPContextID()
+	if err != nil {
+		return err
+	}
+	xp := adminserver.ExtendedProvider{
+		PeerID: cctx.String("peer-id"),
+		Addrs:  cctx.StringSlice(addrFlag.Name),
+	}
+	if cctx.IsSet(metadataFlag.Name) {
+		xp.Metadata, err = base64.StdEncoding.DecodeString(metadataFlagValue)
+		if err != nil {
+			return errors.New("metadata is not a valid base64 encoded string")
+		}
+	}
+	if keyFile := cctx.String("key-file"); keyFile != "" {
+		// The daemon reads the key file, so that the key is never sent over
+		// the admin API.
+		xp.PrivKeyPath, err = filepath.Abs(keyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := adminserver.AddXProvidersReq{
+		ContextID: contextID,
+		Providers: []adminserver.ExtendedProvider{xp},
+	}
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/xproviders/add", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.XProvidersRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	msg := fmt.Sprintf("Added extended provider.\n\t Advertisement ID: %s\n", res.AdvId)
+	_, err = cctx.App.Writer.Write([]byte(msg))
+	return err
+}
+
+func doRemoveXProviders(cctx *cli.Context) error {
+	contextID, err := decodeXPContextID()
+	if err != nil {
+		return err
+	}
+	req := adminserver.RemoveXProvidersReq{
+		ContextID: contextID,
+		PeerIDs:   cctx.StringSlice("peer-id"),
+	}
+	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/xproviders/remove", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errFromHttpResp(resp)
+	}
+
+	var res adminserver.XProvidersRes
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	}
+	msg := fmt.Sprintf("Removed extended providers.\n\t Advertisement ID: %s\n", res.AdvId)
+	_, err = cctx.App.Writer.Write([]byte(msg))
+	return err
+}
@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	carv2 "github.com/ipld/go-car/v2"
+	carstorage "github.com/ipld/go-car/v2/storage"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipni/go-libipni/ingest/schema"
+)
+
+// ChainExport summarizes an advertisement chain exported by
+// Engine.ExportChain.
+type ChainExport struct {
+	// Head is the CID of the latest advertisement, and the root of the CAR.
+	Head cid.Cid
+	// Ads is the number of advertisements exported.
+	Ads int
+	// EntriesBlocks is the number of entries blocks exported.
+	EntriesBlocks int
+	// UncachedEntries is the number of advertisements whose entries were not
+	// exported, because they are neither stored nor cached.
+	UncachedEntries int
+}
+
+// ExportChain writes the advertisement chain to w as a CARv2, with the latest
+// advertisement as its root. Since CARv2 is written with random access, w must
+// also implement io.WriterAt, as *os.File does.
+//
+// If withEntries is true, then the entries blocks of each advertisement are
+// also exported if they are stored or cached by the engine. Entries are not
+// generated from the multihash lister for the purpose of exporting; the
+// engine must be started for cached entries to be exported.
+func (e *Engine) ExportChain(ctx context.Context, w io.Writer, withEntries bool) (*ChainExport, error) {
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	if head == cid.Undef {
+		return nil, errors.New("no advertisements to export")
+	}
+
+	car, err := carstorage.NewWritable(w, []cid.Cid{head}, carv2.UseWholeCIDs(true))
+	if err != nil {
+		return nil, err
+	}
+	export := &ChainExport{
+		Head: head,
+	}
+	for adCid := head; adCid != cid.Undef; {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := e.ds.Get(ctx, datastore.NewKey(adCid.String()))
+		if err != nil {
+			return nil, fmt.Errorf("cannot get advertisement %s: %w", adCid, err)
+		}
+		ad, err := decodeAd(adCid, data)
+		if err != nil {
+			return nil, err
+		}
+		if err = car.Put(ctx, adCid.KeyString(), data); err != nil {
+			return nil, err
+		}
+		export.Ads++
+
+		if withEntries && ad.Entries != schema.NoEntries {
+			// Advertisements may share the same entries.
+			has, err := car.Has(ctx, ad.Entries.(cidlink.Link).Cid.KeyString())
+			if err != nil {
+				return nil, err
+			}
+			if !has {
+				n, err := e.exportEntries(ctx, car, ad.Entries)
+				if err != nil {
+					return nil, fmt.Errorf("cannot export entries of advertisement %s: %w", adCid, err)
+				}
+				if n == 0 {
+					export.UncachedEntries++
+				}
+				export.EntriesBlocks += n
+			}
+		}
+
+		if ad.PreviousID == nil {
+			break
+		}
+		adCid = ad.PreviousID.(cidlink.Link).Cid
+	}
+
+	if err = car.Finalize(); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// exportEntries writes the stored or cached blocks of the entries DAG rooted
+// at root, and returns the number of blocks written.
+func (e *Engine) exportEntries(ctx context.Context, car carstorage.WritableCar, root ipld.Link) (int, error) {
+	lsys := cidlink.DefaultLinkSystem()
+	var count int
+	pending := []ipld.Link{root}
+	for len(pending) != 0 {
+		lnk := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		c := lnk.(cidlink.Link).Cid
+
+		if has, err := car.Has(ctx, c.KeyString()); err != nil {
+			return 0, err
+		} else if has {
+			continue
+		}
+		data, err := e.ds.Get(ctx, datastore.NewKey(c.String()))
+		if err != nil {
+			if !errors.Is(err, datastore.ErrNotFound) {
+				return 0, err
+			}
+			if e.entriesChunker != nil {
+				data, err = e.entriesChunker.GetRawCachedChunk(ctx, lnk)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		decoder, err := lsys.DecoderChooser(lnk)
+		if err != nil {
+			return 0, err
+		}
+		nb := basicnode.Prototype.Any.NewBuilder()
+		if err = decoder(nb, bytes.NewReader(data)); err != nil {
+			return 0, err
+		}
+		if err = car.Put(ctx, c.KeyString(), data); err != nil {
+			return 0, err
+		}
+		count++
+		pending = appendLinks(pending, nb.Build())
+	}
+	return count, nil
+}
+
+// appendLinks appends the links found in node n to links.
+func appendLinks(links []ipld.Link, n ipld.Node) []ipld.Link {
+	switch n.Kind() {
+	case datamodel.Kind_Link:
+		if lnk, err := n.AsLink(); err == nil {
+			links = append(links, lnk)
+		}
+	case datamodel.Kind_Map:
+		it := n.MapIterator()
+		for !it.Done() {
+			_, v, err := it.Next()
+			if err != nil {
+				break
+			}
+			links = appendLinks(links, v)
+		}
+	case datamodel.Kind_List:
+		it := n.ListIterator()
+		for !it.Done() {
+			_, v, err := it.Next()
+			if err != nil {
+				break
+			}
+			links = appendLinks(links, v)
+		}
+	}
+	return links
+}
+
+// ImportChain restores an advertisement chain from a CAR written by
+// ExportChain. The root of the CAR becomes the latest advertisement, and the
+// context ID mappings are rebuilt from the advertisements. Entries blocks in
+// the CAR are ignored; they are generated from the multihash lister on demand.
+//
+// The engine datastore must not already have an advertisement chain. The
+// returned report describes the restored chain; see Engine.Verify.
+func (e *Engine) ImportChain(ctx context.Context, r io.ReaderAt) (*VerifyReport, error) {
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	if head != cid.Undef {
+		return nil, fmt.Errorf("datastore already has advertisement chain with head %s", head)
+	}
+
+	car, err := carstorage.OpenReadable(r, carv2.UseWholeCIDs(true))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open car: %w", err)
+	}
+	roots := car.Roots()
+	if len(roots) != 1 {
+		return nil, fmt.Errorf("car must have exactly one root, found %d", len(roots))
+	}
+	head = roots[0]
+
+	for adCid := head; adCid != cid.Undef; {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := car.Get(ctx, adCid.KeyString())
+		if err != nil {
+			return nil, fmt.Errorf("cannot get advertisement %s from car: %w", adCid, err)
+		}
+		ad, err := decodeAd(adCid, data)
+		if err != nil {
+			return nil, err
+		}
+		if err = e.ds.Put(ctx, datastore.NewKey(adCid.String()), data); err != nil {
+			return nil, err
+		}
+		if ad.PreviousID == nil {
+			break
+		}
+		adCid = ad.PreviousID.(cidlink.Link).Cid
+	}
+
+	if err = e.putLatestAdv(ctx, head.Bytes()); err != nil {
+		return nil, err
+	}
+	return e.verify(ctx, true)
+}
+
+// decodeAd decodes the advertisement block data, checking that it matches the
+// CID.
+func decodeAd(adCid cid.Cid, data []byte) (*schema.Advertisement, error) {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(ipld.LinkContext, ipld.Link) (io.Reader, error) {
+		return bytes.NewReader(data), nil
+	}
+	n, err := lsys.Load(ipld.LinkContext{}, cidlink.Link{Cid: adCid}, schema.AdvertisementPrototype)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode advertisement %s: %w", adCid, err)
+	}
+	return schema.UnwrapAdvertisement(n)
+}
@@ -0,0 +1,111 @@
+package engine_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_ExportImportChain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	providerID, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	retrievalAddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/9999")
+	require.NoError(t, err)
+	prov := peer.AddrInfo{ID: providerID, Addrs: []multiaddr.Multiaddr{retrievalAddr}}
+	mhs := random.Multihashes(10)
+	lister := func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	}
+
+	subject, err := engine.New(engine.WithPrivateKey(priv), engine.WithProvider(prov))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+
+	// Exporting an empty chain fails.
+	carPath := filepath.Join(t.TempDir(), "chain.car")
+	f, err := os.Create(carPath)
+	require.NoError(t, err)
+	_, err = subject.ExportChain(ctx, f, true)
+	require.Error(t, err)
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		_, err = subject.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+	}
+	_, err = subject.NotifyRemove(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+
+	export, err := subject.ExportChain(ctx, f, true)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, 4, export.Ads)
+	require.NotZero(t, export.EntriesBlocks)
+	require.Zero(t, export.UncachedEntries)
+	wantAds := chainAds(t, ctx, subject)
+
+	// Import into a fresh datastore.
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	restored, err := engine.New(engine.WithPrivateKey(priv), engine.WithProvider(prov), engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, restored.Start(ctx))
+	defer restored.Shutdown()
+	restored.RegisterMultihashLister(lister)
+
+	f, err = os.Open(carPath)
+	require.NoError(t, err)
+	defer f.Close()
+	report, err := restored.ImportChain(ctx, f)
+	require.NoError(t, err)
+	require.Equal(t, export.Head, report.Head)
+	require.Equal(t, 4, report.Ads)
+	require.Equal(t, 2, report.ContextIDs)
+	// Every mapping was missing, and has been rebuilt.
+	require.Equal(t, len(report.Problems), report.Repaired())
+
+	headCid, _, err := restored.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, export.Head, headCid)
+	gotAds := chainAds(t, ctx, restored)
+	require.Len(t, gotAds, len(wantAds))
+	for i := range wantAds {
+		require.True(t, bytes.Equal(wantAds[i].ContextID, gotAds[i].ContextID))
+	}
+
+	report, err = restored.Verify(ctx, false)
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+
+	// Importing again over an existing chain fails.
+	_, err = restored.ImportChain(ctx, f)
+	require.ErrorContains(t, err, "already has advertisement chain")
+
+	// The rebuilt mappings are usable.
+	_, err = restored.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+	_, err = restored.NotifyRemove(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	_, err = restored.NotifyRemove(ctx, "", []byte("crab"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+}
@@ -72,6 +72,8 @@ type (
 		lock sync.Mutex
 		// chunker is the underlying chunker that generates a DAG from a provider.MultihashIterator.
 		chunker EntriesChunker
+		// evictHook, if set, is called with the root of each chain evicted from the cache.
+		evictHook func(root ipld.Link)
 	}
 
 	// NewChunkerFunc instantiates the core EntriesChunker to use for generating advertisement
@@ -228,9 +230,23 @@ func (ls *CachedEntriesChunker) onEvicted(k lru.Key, val interface{}) {
 	if err != nil {
 		log.Errorw("failed to prune persisted cache key after eviction", "err", err)
 		ls.onEvictedErr = err
+		return
+	}
+	if ls.evictHook != nil {
+		ls.evictHook(chunkRoot)
 	}
 }
 
+// SetEvictionHook sets a function that is called with the root of each chain
+// evicted from the cache, including chains evicted by Clear. The hook is
+// called while the cache is locked, so must not call back into the
+// CachedEntriesChunker.
+func (ls *CachedEntriesChunker) SetEvictionHook(hook func(root ipld.Link)) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.evictHook = hook
+}
+
 func dsKey(l ipld.Link) datastore.Key {
 	return datastore.NewKey(l.(cidlink.Link).Cid.String())
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/ipni/go-libipni/metadata"
 	provider "github.com/ipni/index-provider"
 	"github.com/ipni/index-provider/engine/chunker"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 )
@@ -59,6 +60,16 @@ type Engine struct {
 
 	mhLister provider.MultihashLister
 	cblk     sync.Mutex
+
+	// chainLock serializes all changes to the advertisement chain, so that
+	// each new advertisement links to the head it was built against.
+	chainLock sync.Mutex
+	// xpKeys holds the private keys of extended providers, by peer ID, that
+	// have been supplied to the engine during this process lifetime.
+	xpKeys map[peer.ID]crypto.PrivKey
+
+	// subs are the subscriptions to engine events.
+	subs subscriptions
 }
 
 var _ provider.Interface = (*Engine)(nil)
@@ -94,6 +105,7 @@ func New(o ...Option) (*Engine, error) {
 
 	e := &Engine{
 		options: opts,
+		xpKeys:  make(map[peer.ID]crypto.PrivKey),
 	}
 
 	e.lsys = e.mkLinkSystem()
@@ -116,6 +128,9 @@ func (e *Engine) Start(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	e.entriesChunker.SetEvictionHook(func(root ipld.Link) {
+		e.emit(CacheEvicted{Cid: root.(cidlink.Link).Cid})
+	})
 
 	e.publisher, err = e.newPublisher(e.pubHttpListenAddr, e.pubHttpHandlerPath)
 	if err != nil {
@@ -191,6 +206,15 @@ func (e *Engine) newPublisher(httpListenAddr, httpPath string) (dagsync.Publishe
 			log.Warn("Libp2p + HTTP publisher in use without address for announcements. Using HTTP listen and libp2p host addresses, but external addresses may be needed.", "addrs", libp2phttpPub.Addrs())
 		}
 		return libp2phttpPub, nil
+	case StaticPublisher:
+		staticPub, err := newStaticPublisher(e.pubStaticDir, e.lsys, e.key, e.pubTopicName, e.pubHttpAnnounceAddrs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create publisher: %w", err)
+		}
+		if len(e.pubHttpAnnounceAddrs) == 0 {
+			log.Warnw("Static publisher in use without address for announcements. Indexers cannot sync advertisements unless told the address of the static file host.", "dir", e.pubStaticDir)
+		}
+		return staticPub, nil
 	}
 	panic("bad publisher kind")
 }
@@ -246,14 +270,17 @@ func (e *Engine) createSenders(announceURLs []*url.URL, pubsubOK bool, extraGoss
 // announce uses the engines senders to send advertisement announcement messages.
 func (e *Engine) announce(ctx context.Context, c cid.Cid) {
 	// If announcements disabled.
-	if e.pubKind == NoPublisher {
+	if e.pubKind == NoPublisher || len(e.senders) == 0 {
 		return
 	}
 
 	err := announce.Send(ctx, c, e.pubHttpAnnounceAddrs, e.senders...)
 	if err != nil {
 		log.Errorw("Failed to announce advertisement", "err", err)
+		e.emit(AnnounceFailed{Cid: c, Err: err})
+		return
 	}
+	e.emit(AnnounceSent{Cid: c})
 }
 
 // PublishLocal stores the advertisement in the local link system and marks it
@@ -264,6 +291,12 @@ func (e *Engine) announce(ctx context.Context, c cid.Cid) {
 //
 // See: Engine.Publish.
 func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+	return e.publishLocal(ctx, adv)
+}
+
+func (e *Engine) publishLocal(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
 	if err := adv.Validate(); err != nil {
 		return cid.Undef, err
 	}
@@ -286,6 +319,7 @@ func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (ci
 		return cid.Undef, fmt.Errorf("failed to update reference to latest advertisement: %w", err)
 	}
 	log.Info("Updated reference to the latest advertisement successfully")
+	e.emit(AdPublished{Cid: c, Ad: &adv})
 	return c, nil
 }
 
@@ -297,7 +331,15 @@ func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (ci
 // The publication mechanism uses dagsync.Publisher internally.
 // See: https://github.com/ipni/go-libipni/tree/main/dagsync
 func (e *Engine) Publish(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
-	c, err := e.PublishLocal(ctx, adv)
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+	return e.publish(ctx, adv)
+}
+
+// publish stores and announces the advertisement. The caller must hold the
+// chain lock.
+func (e *Engine) publish(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
+	c, err := e.publishLocal(ctx, adv)
 	if err != nil {
 		log.Errorw("Failed to store advertisement locally", "err", err)
 		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
@@ -387,7 +429,12 @@ func (e *Engine) httpAnnounce(ctx context.Context, adCid cid.Cid, announceURLs [
 	}
 
 	log.Infow("Announcing advertisements over HTTP", "urls", announceURLs)
-	return announce.Send(ctx, adCid, e.pubHttpAnnounceAddrs, httpSender)
+	if err = announce.Send(ctx, adCid, e.pubHttpAnnounceAddrs, httpSender); err != nil {
+		e.emit(AnnounceFailed{Cid: adCid, Err: err})
+		return err
+	}
+	e.emit(AnnounceSent{Cid: adCid})
+	return nil
 }
 
 // RegisterMultihashLister registers a provider.MultihashLister that is used to
@@ -469,6 +516,7 @@ func (e *Engine) Shutdown() error {
 		}
 		e.entriesChunker = nil
 	}
+	e.closeSubscriptions()
 	return errs
 }
 
@@ -528,6 +576,9 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 	var err error
 	var cidsLnk cidlink.Link
 
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+
 	log := log.With("providerID", p).With("contextID", base64.StdEncoding.EncodeToString(contextID))
 
 	c, err := e.getKeyCidMap(ctx, p, contextID)
@@ -555,6 +606,13 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 			if err != nil {
 				return cid.Undef, err
 			}
+			var indexed *reverseIndexIterator
+			if e.reverseIndex {
+				if indexed, err = e.indexMultihashes(ctx, p, contextID, mhIter); err != nil {
+					return cid.Undef, fmt.Errorf("could not add entries to reverse index: %w", err)
+				}
+				mhIter = indexed
+			}
 			// Generate the linked list ipld.Link that is added to the
 			// advertisement and used for ingestion.
 			lnk, err := e.entriesChunker.Chunk(ctx, mhIter)
@@ -567,6 +625,12 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 			}
 			cidsLnk = lnk.(cidlink.Link)
 
+			if indexed != nil {
+				if err = indexed.commit(ctx, cidsLnk.Cid); err != nil {
+					return cid.Undef, fmt.Errorf("could not add entries to reverse index: %w", err)
+				}
+			}
+
 			// Store the relationship between providerID, contextID and CID of the
 			// advertised list of Cids.
 			err = e.putKeyCidMap(ctx, p, contextID, cidsLnk.Cid)
@@ -597,6 +661,11 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 		if err = e.putKeyMetadataMap(ctx, p, contextID, &md); err != nil {
 			return cid.Undef, fmt.Errorf("failed to write provider + context id to metadata mapping: %s", err)
 		}
+		if e.reverseIndex && len(addrs) != 0 {
+			if err = e.putProviderAddrs(ctx, p, addrs); err != nil {
+				return cid.Undef, fmt.Errorf("failed to write provider addresses: %w", err)
+			}
+		}
 	} else {
 		log.Info("Creating removal advertisement")
 
@@ -604,6 +673,14 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 			return cid.Undef, provider.ErrContextIDNotFound
 		}
 
+		if e.reverseIndex {
+			if err = e.unindexMultihashes(ctx, p, contextID, c); err != nil {
+				// Entries left in the reverse index are ignored, and removed,
+				// by FindContexts.
+				log.Warnw("Could not remove entries from reverse index", "err", err)
+			}
+		}
+
 		// If removing by context ID, it means the list of CIDs is not needed
 		// anymore, so we can remove the entry from the datastore.
 		err = e.deleteKeyCidMap(ctx, p, contextID)
@@ -665,7 +742,7 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 	if err = adv.Sign(e.key); err != nil {
 		return cid.Undef, err
 	}
-	return e.Publish(ctx, adv)
+	return e.publish(ctx, adv)
 }
 
 func (e *Engine) keyToCidKey(provider peer.ID, contextID []byte) datastore.Key {
@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	leveldb "github.com/ipfs/go-ds-leveldb"
 	"github.com/ipfs/go-test/random"
 	"github.com/ipld/go-ipld-prime"
@@ -22,13 +25,16 @@ import (
 	selectorbuilder "github.com/ipld/go-ipld-prime/traversal/selector/builder"
 	"github.com/ipni/go-libipni/announce/message"
 	"github.com/ipni/go-libipni/dagsync/ipnisync"
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
 	"github.com/ipni/go-libipni/ingest/schema"
 	"github.com/ipni/go-libipni/metadata"
 	provider "github.com/ipni/index-provider"
 	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/engine/signer"
 	"github.com/ipni/index-provider/testutil"
 	"github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multihash"
@@ -324,6 +330,165 @@ func TestEngine_PublishWithLibp2pHttpPublisher(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestEngine_PublishWithStaticPublisher(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	// Serve the static publisher directory as a static file host would.
+	dir := t.TempDir()
+	ts := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(ts.Close)
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	require.NoError(t, err)
+	announceAddr := "/ip4/127.0.0.1/tcp/" + port + "/http"
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.StaticPublisher),
+		engine.WithStaticPublisherDir(dir),
+		engine.WithHttpPublisherAnnounceAddr(announceAddr),
+		engine.WithPubsubAnnounce(false))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, subject.Shutdown()) })
+
+	mhs := map[string][]multihash.Multihash{
+		"fish":    random.Multihashes(42),
+		"lobster": random.Multihashes(7),
+	}
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		if got, ok := mhs[string(contextID)]; ok {
+			return provider.SliceMultihashIterator(got), nil
+		}
+		return nil, errors.New("not found")
+	})
+	sub := subject.Subscribe(100, engine.DropNewest)
+	defer sub.Close()
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	headAdCid, err := subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	// Writing the directory is not reported as served to indexers.
+	for pending := true; pending; {
+		select {
+		case ev := <-sub.Events():
+			require.IsType(t, engine.AdPublished{}, ev)
+		default:
+			pending = false
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "ipni", "v1", "ad", headAdCid.String()))
+	require.NoError(t, err)
+
+	// The whole chain, including entries, is synced from the static host.
+	ls := cidlink.DefaultLinkSystem()
+	store := &memstore.Store{}
+	ls.SetReadStorage(store)
+	ls.SetWriteStorage(store)
+	sync := ipnisync.NewSync(ls, nil)
+	t.Cleanup(func() { sync.Close() })
+	maddr, err := multiaddr.NewMultiaddr(announceAddr)
+	require.NoError(t, err)
+	syncer, err := sync.NewSyncer(peer.AddrInfo{ID: subject.Host().ID(), Addrs: []multiaddr.Multiaddr{maddr}})
+	require.NoError(t, err)
+	gotHead, err := syncer.GetHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, headAdCid, gotHead)
+
+	ssb := selectorbuilder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	adSel := ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreFields(
+		func(efsb selectorbuilder.ExploreFieldsSpecBuilder) {
+			efsb.Insert("PreviousID", ssb.ExploreRecursiveEdge())
+			efsb.Insert("Next", ssb.ExploreRecursiveEdge())
+			efsb.Insert("Entries", ssb.ExploreRecursiveEdge())
+		})).Node()
+	require.NoError(t, syncer.Sync(ctx, gotHead, adSel))
+
+	for adCid := gotHead; adCid != cid.Undef; {
+		n, err := ls.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: adCid}, schema.AdvertisementPrototype)
+		require.NoError(t, err)
+		ad, err := schema.UnwrapAdvertisement(n)
+		require.NoError(t, err)
+		gotMhs, err := provider.EntryChunkMultihashIterator(ad.Entries, ls)
+		require.NoError(t, err)
+		var count int
+		for {
+			_, err := gotMhs.Next()
+			if err != nil {
+				break
+			}
+			count++
+		}
+		require.Equal(t, len(mhs[string(ad.ContextID)]), count)
+		if ad.PreviousID == nil {
+			break
+		}
+		adCid = ad.PreviousID.(cidlink.Link).Cid
+	}
+}
+
+func TestEngine_StaticPublisherSkipsEntriesOfRemovedContexts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	mhs := map[string][]multihash.Multihash{
+		"fish":    random.Multihashes(42),
+		"lobster": random.Multihashes(7),
+		"crab":    random.Multihashes(3),
+	}
+	lister := func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		if got, ok := mhs[string(contextID)]; ok {
+			return provider.SliceMultihashIterator(got), nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	subject.RegisterMultihashLister(lister)
+	fishAdCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	delete(mhs, "fish")
+	require.NoError(t, subject.Chunker().Clear(ctx))
+	fishAd, err := subject.GetAdv(ctx, fishAdCid)
+	require.NoError(t, err)
+	require.NoError(t, subject.Shutdown())
+
+	// Enable the static publisher on the existing chain.
+	dir := t.TempDir()
+	subject, err = engine.New(
+		engine.WithDatastore(ds),
+		engine.WithHost(subject.Host()),
+		engine.WithPublisherKind(engine.StaticPublisher),
+		engine.WithStaticPublisherDir(dir),
+		engine.WithPubsubAnnounce(false))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, subject.Shutdown()) })
+	subject.RegisterMultihashLister(lister)
+	headAdCid, err := subject.NotifyPut(ctx, nil, []byte("crab"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	// The head is written despite the entries of the removed context.
+	adDir := filepath.Join(dir, "ipni", "v1", "ad")
+	headData, err := os.ReadFile(filepath.Join(adDir, "head"))
+	require.NoError(t, err)
+	signedHead, err := headschema.Decode(bytes.NewReader(headData))
+	require.NoError(t, err)
+	require.Equal(t, headAdCid, signedHead.Head.(cidlink.Link).Cid)
+	_, err = os.Stat(filepath.Join(adDir, fishAdCid.String()))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(adDir, fishAd.Entries.(cidlink.Link).Cid.String()))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func TestEngine_NotifyPutWithoutListerIsError(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	t.Cleanup(cancel)
@@ -759,3 +924,65 @@ func requireEqualDagsyncMessage(t *testing.T, want, got message.Message) {
 	require.NoError(t, err)
 	require.ElementsMatch(t, wantAddrs, gotAddrs)
 }
+
+func TestEngine_NotifyPutWithExternalSigner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+
+	// Run an external signer holding the host key.
+	sockDir, err := os.MkdirTemp("", "signer")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(sockDir) })
+	sockPath := filepath.Join(sockDir, "signer.sock")
+	l, err := net.Listen("unix", sockPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go signer.Serve(l, signer.NewKeySigner(h.Peerstore().PrivKey(h.ID())))
+
+	extSigner, err := signer.NewExternalSigner(ctx, sockPath)
+	require.NoError(t, err)
+
+	subject, err := engine.New(engine.WithHost(h), engine.WithSigner(extSigner))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	mhs := random.Multihashes(42)
+	subject.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	signerID, err := ad.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, h.ID(), signerID)
+}
+
+func TestEngine_SignerSetsProviderID(t *testing.T) {
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	keyID, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+
+	// Without a provider ID, the signer identity is the provider, and not the
+	// identity of the host created by the engine.
+	subject, err := engine.New(engine.WithSigner(signer.NewKeySigner(key)))
+	require.NoError(t, err)
+	require.Equal(t, keyID, subject.ProviderID())
+	require.NotEqual(t, keyID, subject.Host().ID())
+
+	// A provider that does not match the signer is rejected.
+	otherID := random.Peers(1)[0]
+	_, err = engine.New(
+		engine.WithSigner(signer.NewKeySigner(key)),
+		engine.WithProvider(peer.AddrInfo{ID: otherID, Addrs: random.Multiaddrs(1)}))
+	require.ErrorContains(t, err, "does not match provider id")
+}
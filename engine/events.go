@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Event is an event emitted by the engine to subscribers. The concrete type
+// of an Event is one of AdPublished, AnnounceSent, AnnounceFailed,
+// EntriesRegenerated, CacheEvicted or SyncServed.
+//
+// See: Engine.Subscribe.
+type Event interface {
+	isEvent()
+}
+
+// AdPublished is emitted when an advertisement is stored as the new head of
+// the advertisement chain.
+type AdPublished struct {
+	// Cid is the CID of the advertisement.
+	Cid cid.Cid
+	// Ad is the published advertisement.
+	Ad *schema.Advertisement
+}
+
+// AnnounceSent is emitted when an announcement of an advertisement is sent.
+type AnnounceSent struct {
+	// Cid is the CID of the announced advertisement.
+	Cid cid.Cid
+}
+
+// AnnounceFailed is emitted when an announcement of an advertisement could not
+// be sent.
+type AnnounceFailed struct {
+	// Cid is the CID of the advertisement that was to be announced.
+	Cid cid.Cid
+	// Err is the error that caused the announcement to fail.
+	Err error
+}
+
+// EntriesRegenerated is emitted when the entries of an advertisement are
+// regenerated from the multihash lister, because they were not cached when
+// requested.
+type EntriesRegenerated struct {
+	// Cid is the CID of the root of the entries.
+	Cid cid.Cid
+	// Provider is the provider ID that the entries were listed for.
+	Provider peer.ID
+	// ContextID is the context ID that the entries were listed for.
+	ContextID []byte
+}
+
+// CacheEvicted is emitted when the entries of an advertisement are evicted
+// from the entries cache.
+type CacheEvicted struct {
+	// Cid is the CID of the root of the evicted entries.
+	Cid cid.Cid
+}
+
+// SyncServed is emitted when an advertisement or entries block is served by
+// the engine link system, which happens when an indexer syncs with the
+// publisher. The publisher does not reveal which peer requested the block.
+// Reads internal to the engine, such as writing the StaticPublisher
+// directory, are not reported.
+type SyncServed struct {
+	// Cid is the CID of the served block.
+	Cid cid.Cid
+	// Advertisement is true if the block is an advertisement, and false if it
+	// is an entries block.
+	Advertisement bool
+}
+
+func (AdPublished) isEvent()        {}
+func (AnnounceSent) isEvent()       {}
+func (AnnounceFailed) isEvent()     {}
+func (EntriesRegenerated) isEvent() {}
+func (CacheEvicted) isEvent()       {}
+func (SyncServed) isEvent()         {}
+
+// DropPolicy determines which event is dropped when an event is emitted to a
+// subscriber whose buffer is full.
+type DropPolicy int
+
+const (
+	// DropNewest drops the event being emitted, keeping the buffered events.
+	DropNewest DropPolicy = iota
+	// DropOldest drops the oldest buffered event to make room for the event
+	// being emitted.
+	DropOldest
+)
+
+// Subscription receives the events emitted by an engine. See:
+// Engine.Subscribe.
+type Subscription struct {
+	events  chan Event
+	policy  DropPolicy
+	dropped atomic.Uint64
+	e       *Engine
+}
+
+// subscriptions is the set of subscriptions of an engine.
+type subscriptions struct {
+	lock sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscribe returns a new Subscription that receives the events emitted by the
+// engine from now on. Events are buffered for the subscriber, up to
+// bufferSize. The engine never blocks on a subscriber; when the buffer is
+// full, events are dropped according to policy and counted by
+// Subscription.Dropped.
+//
+// The subscription must be closed by calling Subscription.Close when no longer
+// needed. Engine.Shutdown closes all subscriptions.
+func (e *Engine) Subscribe(bufferSize int, policy DropPolicy) *Subscription {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	sub := &Subscription{
+		events: make(chan Event, bufferSize),
+		policy: policy,
+		e:      e,
+	}
+	e.subs.lock.Lock()
+	defer e.subs.lock.Unlock()
+	if e.subs.subs == nil {
+		e.subs.subs = make(map[*Subscription]struct{})
+	}
+	e.subs.subs[sub] = struct{}{}
+	return sub
+}
+
+// Events returns the channel on which events are received. The channel is
+// closed when the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns the number of events that were dropped because the
+// subscription buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription and closes its event channel. It is safe to
+// call Close more than once.
+func (s *Subscription) Close() {
+	s.e.subs.lock.Lock()
+	defer s.e.subs.lock.Unlock()
+	if _, ok := s.e.subs.subs[s]; ok {
+		delete(s.e.subs.subs, s)
+		close(s.events)
+	}
+}
+
+// emit sends the event to all subscriptions without blocking.
+func (e *Engine) emit(event Event) {
+	e.subs.lock.RLock()
+	defer e.subs.lock.RUnlock()
+	for sub := range e.subs.subs {
+		sub.send(event)
+	}
+}
+
+func (s *Subscription) send(event Event) {
+	select {
+	case s.events <- event:
+		return
+	default:
+	}
+	if s.policy == DropOldest {
+		select {
+		case <-s.events:
+			s.dropped.Add(1)
+		default:
+		}
+		select {
+		case s.events <- event:
+			return
+		default:
+		}
+	}
+	s.dropped.Add(1)
+}
+
+// closeSubscriptions closes all subscriptions.
+func (e *Engine) closeSubscriptions() {
+	e.subs.lock.Lock()
+	defer e.subs.lock.Unlock()
+	for sub := range e.subs.subs {
+		close(sub.events)
+	}
+	e.subs.subs = nil
+}
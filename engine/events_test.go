@@ -0,0 +1,143 @@
+package engine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(
+		engine.WithEntriesCacheCapacity(1),
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	listed := map[string][]multihash.Multihash{
+		"fish":    random.Multihashes(10),
+		"lobster": random.Multihashes(10),
+	}
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(listed[string(contextID)]), nil
+	})
+
+	sub := subject.Subscribe(100, engine.DropNewest)
+	defer sub.Close()
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	fishCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	// The entries of fish are evicted from the cache to make room for those of
+	// lobster.
+	lobsterCid, err := subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+
+	ev := requireEvent[engine.AdPublished](t, sub)
+	require.Equal(t, fishCid, ev.Cid)
+	require.Equal(t, "fish", string(ev.Ad.ContextID))
+	fishEntries := ev.Ad.Entries
+	// Announcements are sent over gossipsub, whether or not there are peers.
+	require.Equal(t, fishCid, requireEvent[engine.AnnounceSent](t, sub).Cid)
+	require.Equal(t, fishEntries.(cidlink.Link).Cid, requireEvent[engine.CacheEvicted](t, sub).Cid)
+	require.Equal(t, lobsterCid, requireEvent[engine.AdPublished](t, sub).Cid)
+	require.Equal(t, lobsterCid, requireEvent[engine.AnnounceSent](t, sub).Cid)
+
+	// Syncing the entries of fish regenerates them.
+	lsys := subject.LinkSystem()
+	_, err = lsys.Load(ipld.LinkContext{Ctx: ctx}, fishEntries, basicnode.Prototype.Any)
+	require.NoError(t, err)
+	requireEvent[engine.CacheEvicted](t, sub)
+	regen := requireEvent[engine.EntriesRegenerated](t, sub)
+	require.Equal(t, fishEntries.(cidlink.Link).Cid, regen.Cid)
+	require.Equal(t, "fish", string(regen.ContextID))
+	served := requireEvent[engine.SyncServed](t, sub)
+	require.Equal(t, fishEntries.(cidlink.Link).Cid, served.Cid)
+	require.False(t, served.Advertisement)
+
+	_, err = lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: lobsterCid}, basicnode.Prototype.Any)
+	require.NoError(t, err)
+	served = requireEvent[engine.SyncServed](t, sub)
+	require.Equal(t, lobsterCid, served.Cid)
+	require.True(t, served.Advertisement)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	tsURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	_, err = subject.PublishLatestHTTP(ctx, tsURL)
+	require.NoError(t, err)
+	require.Equal(t, lobsterCid, requireEvent[engine.AnnounceSent](t, sub).Cid)
+	require.Zero(t, sub.Dropped())
+
+	sub.Close()
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+}
+
+func TestEngine_SubscribeDropPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(random.Multihashes(1)), nil
+	})
+
+	newest := subject.Subscribe(1, engine.DropNewest)
+	oldest := subject.Subscribe(1, engine.DropOldest)
+	md := metadata.Default.New(metadata.Bitswap{})
+	firstCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	lastCid, err := subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(1), newest.Dropped())
+	require.Equal(t, firstCid, requireEvent[engine.AdPublished](t, newest).Cid)
+	require.Equal(t, uint64(1), oldest.Dropped())
+	require.Equal(t, lastCid, requireEvent[engine.AdPublished](t, oldest).Cid)
+
+	// Shutdown closes all subscriptions.
+	require.NoError(t, subject.Shutdown())
+	_, ok := <-newest.Events()
+	require.False(t, ok)
+	_, ok = <-oldest.Events()
+	require.False(t, ok)
+	newest.Close()
+}
+
+// requireEvent requires that the next event of the subscription is of type T.
+func requireEvent[T engine.Event](t *testing.T, sub *engine.Subscription) T {
+	t.Helper()
+	select {
+	case ev := <-sub.Events():
+		typed, ok := ev.(T)
+		require.True(t, ok, "unexpected event %T", ev)
+		return typed
+	default:
+		require.FailNow(t, "no event")
+	}
+	var zero T
+	return zero
+}
@@ -0,0 +1,347 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine/xproviders"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const keyToExtendedProvidersMapPrefix = "map/keyXP/"
+
+// ErrNoExtendedProviderKey signals that an extended provider is part of the
+// set to advertise, but the engine has no private key with which to sign on
+// its behalf, neither supplied nor loaded by the ExtendedProviderKeyLoader.
+var ErrNoExtendedProviderKey = errors.New("no private key for extended provider")
+
+// ExtendedProviders is the set of extended providers most recently advertised
+// by the engine for a context ID. An empty context ID represents the chain
+// level extended providers, which apply to all content published by the
+// engine's default provider.
+type ExtendedProviders struct {
+	// Override signals whether the extended providers override the chain
+	// level ones for the context ID.
+	Override bool
+	// Metadata is the metadata of the main provider.
+	Metadata []byte
+	// Providers are the extended providers. The private key of each provider
+	// is never returned.
+	Providers []xproviders.Info
+}
+
+// extendedProvidersRecord is the persisted form of ExtendedProviders.
+type extendedProvidersRecord struct {
+	Override  bool                     `json:"o,omitempty"`
+	Metadata  []byte                   `json:"m,omitempty"`
+	Providers []extendedProviderRecord `json:"p"`
+}
+
+type extendedProviderRecord struct {
+	ID       string   `json:"i"`
+	Addrs    []string `json:"a"`
+	Metadata []byte   `json:"m,omitempty"`
+}
+
+// NotifyExtendedProviders publishes an advertisement that sets the extended
+// providers for the given context ID to exactly the given list, replacing any
+// previously advertised set. An empty contextID sets the chain level extended
+// providers. Override may only be set when contextID is not empty.
+//
+// The advertisement is built against the current head of the chain, signed by
+// the engine's key as the main provider and by the key of each extended
+// provider. The key of each xproviders.Info is remembered by the engine once
+// the advertisement is published, so that later incremental changes can be
+// signed without supplying it again. Keys are remembered in memory only; after
+// a restart they must be supplied again, unless they can be loaded by the
+// loader set with WithExtendedProviderKeyLoader.
+//
+// If the set is identical to the previously advertised one then
+// provider.ErrAlreadyAdvertised is returned.
+//
+// See: Engine.AddExtendedProviders, Engine.RemoveExtendedProviders.
+func (e *Engine) NotifyExtendedProviders(ctx context.Context, contextID, md []byte, override bool, eps ...xproviders.Info) (cid.Cid, error) {
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+
+	rec := &extendedProvidersRecord{
+		Override:  override,
+		Metadata:  md,
+		Providers: make([]extendedProviderRecord, 0, len(eps)),
+	}
+	keys := make(map[peer.ID]crypto.PrivKey)
+	for _, ep := range eps {
+		if err := checkExtendedProvider(ep, keys); err != nil {
+			return cid.Undef, err
+		}
+		rec.Providers = append(rec.Providers, extendedProviderRecord{
+			ID:       ep.ID,
+			Addrs:    ep.Addrs,
+			Metadata: ep.Metadata,
+		})
+	}
+	return e.publishExtendedProviders(ctx, contextID, rec, keys)
+}
+
+// AddExtendedProviders adds the given extended providers to the set most
+// recently advertised for contextID and publishes the resulting set. An
+// extended provider that is already in the set is replaced with the given
+// info. Override and metadata of the previous set are retained.
+//
+// See: Engine.NotifyExtendedProviders.
+func (e *Engine) AddExtendedProviders(ctx context.Context, contextID []byte, eps ...xproviders.Info) (cid.Cid, error) {
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+
+	rec, err := e.getExtendedProvidersRecord(ctx, contextID)
+	if err != nil {
+		if !errors.Is(err, datastore.ErrNotFound) {
+			return cid.Undef, err
+		}
+		rec = &extendedProvidersRecord{}
+	}
+
+	keys := make(map[peer.ID]crypto.PrivKey)
+	for _, ep := range eps {
+		if err = checkExtendedProvider(ep, keys); err != nil {
+			return cid.Undef, err
+		}
+		epRec := extendedProviderRecord{
+			ID:       ep.ID,
+			Addrs:    ep.Addrs,
+			Metadata: ep.Metadata,
+		}
+		var replaced bool
+		for i := range rec.Providers {
+			if rec.Providers[i].ID == ep.ID {
+				rec.Providers[i] = epRec
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			rec.Providers = append(rec.Providers, epRec)
+		}
+	}
+	return e.publishExtendedProviders(ctx, contextID, rec, keys)
+}
+
+// RemoveExtendedProviders removes the extended providers with the given IDs
+// from the set most recently advertised for contextID and publishes the
+// resulting set. If no set was previously advertised for contextID then
+// provider.ErrContextIDNotFound is returned.
+//
+// See: Engine.NotifyExtendedProviders.
+func (e *Engine) RemoveExtendedProviders(ctx context.Context, contextID []byte, ids ...peer.ID) (cid.Cid, error) {
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+
+	rec, err := e.getExtendedProvidersRecord(ctx, contextID)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return cid.Undef, provider.ErrContextIDNotFound
+		}
+		return cid.Undef, err
+	}
+
+	remove := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		remove[id.String()] = struct{}{}
+	}
+	kept := rec.Providers[:0]
+	for _, epRec := range rec.Providers {
+		if _, ok := remove[epRec.ID]; !ok {
+			kept = append(kept, epRec)
+		}
+	}
+	rec.Providers = kept
+	return e.publishExtendedProviders(ctx, contextID, rec, nil)
+}
+
+// GetExtendedProviders returns the set of extended providers most recently
+// advertised for contextID. If none were advertised, then nil is returned.
+func (e *Engine) GetExtendedProviders(ctx context.Context, contextID []byte) (*ExtendedProviders, error) {
+	rec, err := e.getExtendedProvidersRecord(ctx, contextID)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	xps := &ExtendedProviders{
+		Override:  rec.Override,
+		Metadata:  rec.Metadata,
+		Providers: make([]xproviders.Info, 0, len(rec.Providers)),
+	}
+	for _, epRec := range rec.Providers {
+		xps.Providers = append(xps.Providers, xproviders.Info{
+			ID:       epRec.ID,
+			Addrs:    epRec.Addrs,
+			Metadata: epRec.Metadata,
+		})
+	}
+	return xps, nil
+}
+
+// publishExtendedProviders builds, signs and publishes an advertisement for
+// the given set of extended providers, and persists the set once published.
+// The given keys, supplied with the set, take precedence over the remembered
+// and loaded ones, and are only remembered once the advertisement is
+// published. The caller must hold the chain lock.
+func (e *Engine) publishExtendedProviders(ctx context.Context, contextID []byte, rec *extendedProvidersRecord, keys map[peer.ID]crypto.PrivKey) (cid.Cid, error) {
+	log := log.With("contextID", base64.StdEncoding.EncodeToString(contextID))
+
+	recBytes, err := json.Marshal(rec)
+	if err != nil {
+		return cid.Undef, err
+	}
+	prevBytes, err := e.ds.Get(ctx, e.keyToExtendedProvidersKey(contextID))
+	if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+		return cid.Undef, fmt.Errorf("could not get extended providers for context id: %w", err)
+	}
+	if bytes.Equal(prevBytes, recBytes) {
+		// The keys belong to providers already advertised.
+		e.rememberExtendedProviderKeys(keys)
+		return cid.Undef, provider.ErrAlreadyAdvertised
+	}
+
+	eps := make([]xproviders.Info, 0, len(rec.Providers))
+	for _, epRec := range rec.Providers {
+		epID, err := peer.Decode(epRec.ID)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("invalid extended provider peer id %q: %w", epRec.ID, err)
+		}
+		priv, err := e.extendedProviderKey(epID, keys)
+		if err != nil {
+			return cid.Undef, err
+		}
+		eps = append(eps, xproviders.Info{
+			ID:       epRec.ID,
+			Addrs:    epRec.Addrs,
+			Metadata: epRec.Metadata,
+			Priv:     priv,
+		})
+	}
+
+	prevAdID, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not get latest advertisement: %s", err)
+	}
+
+	log.Infow("Creating extended providers advertisement", "count", len(eps))
+	adv, err := xproviders.NewAdBuilder(e.provider.ID, e.key, e.provider.Addrs).
+		WithContextID(contextID).
+		WithMetadata(rec.Metadata).
+		WithOverride(rec.Override).
+		WithExtendedProviders(eps...).
+		WithLastAdID(prevAdID).
+		BuildAndSign()
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	c, err := e.publish(ctx, *adv)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	e.rememberExtendedProviderKeys(keys)
+
+	if err = e.ds.Put(ctx, e.keyToExtendedProvidersKey(contextID), recBytes); err != nil {
+		return cid.Undef, fmt.Errorf("failed to write context id to extended providers mapping: %w", err)
+	}
+	return c, nil
+}
+
+func (e *Engine) rememberExtendedProviderKeys(keys map[peer.ID]crypto.PrivKey) {
+	for epID, priv := range keys {
+		e.xpKeys[epID] = priv
+	}
+}
+
+// extendedProviderKey returns the private key with which to sign on behalf of
+// the given extended provider. The caller must hold the chain lock.
+func (e *Engine) extendedProviderKey(epID peer.ID, keys map[peer.ID]crypto.PrivKey) (crypto.PrivKey, error) {
+	if epID == e.provider.ID {
+		return e.key, nil
+	}
+	if priv := keys[epID]; priv != nil {
+		return priv, nil
+	}
+	if priv := e.xpKeys[epID]; priv != nil {
+		return priv, nil
+	}
+	if e.xpKeyLoader == nil {
+		return nil, fmt.Errorf("%w %s", ErrNoExtendedProviderKey, epID)
+	}
+	priv, err := e.xpKeyLoader(epID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load private key of extended provider %s: %w", epID, err)
+	}
+	if priv == nil {
+		return nil, fmt.Errorf("%w %s", ErrNoExtendedProviderKey, epID)
+	}
+	keyID, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	if keyID != epID {
+		return nil, fmt.Errorf("loaded private key does not match extended provider id %s", epID)
+	}
+	return priv, nil
+}
+
+// checkExtendedProvider checks the given extended provider, and adds its
+// private key, if present, to keys after checking that it matches the
+// provider ID.
+func checkExtendedProvider(ep xproviders.Info, keys map[peer.ID]crypto.PrivKey) error {
+	epID, err := peer.Decode(ep.ID)
+	if err != nil {
+		return fmt.Errorf("invalid extended provider peer id %q: %w", ep.ID, err)
+	}
+	if len(ep.Addrs) == 0 {
+		return fmt.Errorf("addresses of extended provider %s can not be empty", epID)
+	}
+	for _, a := range ep.Addrs {
+		if _, err = multiaddr.NewMultiaddr(a); err != nil {
+			return fmt.Errorf("bad multiaddr %q for extended provider %s: %w", a, epID, err)
+		}
+	}
+	if ep.Priv == nil {
+		return nil
+	}
+	keyID, err := peer.IDFromPrivateKey(ep.Priv)
+	if err != nil {
+		return err
+	}
+	if keyID != epID {
+		return fmt.Errorf("private key does not match extended provider id %s", epID)
+	}
+	keys[epID] = ep.Priv
+	return nil
+}
+
+func (e *Engine) keyToExtendedProvidersKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(keyToExtendedProvidersMapPrefix + string(contextID))
+}
+
+func (e *Engine) getExtendedProvidersRecord(ctx context.Context, contextID []byte) (*extendedProvidersRecord, error) {
+	b, err := e.ds.Get(ctx, e.keyToExtendedProvidersKey(contextID))
+	if err != nil {
+		return nil, err
+	}
+	var rec extendedProvidersRecord
+	if err = json.Unmarshal(b, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
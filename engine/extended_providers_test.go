@@ -0,0 +1,218 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-test/random"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/engine/xproviders"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_NotifyExtendedProviders(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	mhs := random.Multihashes(42)
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+	putAdCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	ep1ID, ep1 := randomXProvider()
+	xpAdCid, err := subject.NotifyExtendedProviders(ctx, nil, nil, false, ep1)
+	require.NoError(t, err)
+
+	ad, err := subject.GetAdv(ctx, xpAdCid)
+	require.NoError(t, err)
+	require.Equal(t, putAdCid, ad.PreviousID.(cidlink.Link).Cid)
+	signer, err := ad.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, subject.ProviderID(), signer)
+	require.Equal(t, subject.ProviderID().String(), ad.Provider)
+	require.Len(t, ad.ExtendedProvider.Providers, 2)
+
+	// Publishing the same set again is a no-op.
+	_, err = subject.NotifyExtendedProviders(ctx, nil, nil, false, ep1)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+
+	// Adding a provider keeps the existing one, signing with the key
+	// remembered from the previous call.
+	ep2ID, ep2 := randomXProvider()
+	addAdCid, err := subject.AddExtendedProviders(ctx, nil, ep2)
+	require.NoError(t, err)
+	ad, err = subject.GetAdv(ctx, addAdCid)
+	require.NoError(t, err)
+	require.Equal(t, xpAdCid, ad.PreviousID.(cidlink.Link).Cid)
+	_, err = ad.VerifySignature()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{ep1ID.String(), ep2ID.String(), subject.ProviderID().String()}, xpIDs(ad.ExtendedProvider.Providers))
+
+	rmAdCid, err := subject.RemoveExtendedProviders(ctx, nil, ep1ID)
+	require.NoError(t, err)
+	ad, err = subject.GetAdv(ctx, rmAdCid)
+	require.NoError(t, err)
+	require.Equal(t, addAdCid, ad.PreviousID.(cidlink.Link).Cid)
+	require.ElementsMatch(t, []string{ep2ID.String(), subject.ProviderID().String()}, xpIDs(ad.ExtendedProvider.Providers))
+
+	xps, err := subject.GetExtendedProviders(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, xps.Providers, 1)
+	require.Equal(t, ep2ID.String(), xps.Providers[0].ID)
+	require.Nil(t, xps.Providers[0].Priv)
+
+	latest, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, rmAdCid, latest)
+}
+
+func TestEngine_NotifyExtendedProvidersPerContextID(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	_, ep := randomXProvider()
+	contextID := []byte("fish")
+	adCid, err := subject.NotifyExtendedProviders(ctx, contextID, []byte("meta"), true, ep)
+	require.NoError(t, err)
+
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, contextID, ad.ContextID)
+	require.True(t, ad.ExtendedProvider.Override)
+
+	xps, err := subject.GetExtendedProviders(ctx, nil)
+	require.NoError(t, err)
+	require.Nil(t, xps)
+
+	_, err = subject.RemoveExtendedProviders(ctx, []byte("bird"), peer.ID(ep.ID))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+}
+
+func TestEngine_AddExtendedProvidersRequiresKeys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+
+	_, ep1 := randomXProvider()
+	_, err = subject.NotifyExtendedProviders(ctx, nil, nil, false, ep1)
+	require.NoError(t, err)
+	require.NoError(t, subject.Shutdown())
+
+	// A new engine over the same datastore knows the set, but not the keys.
+	subject, err = engine.New(engine.WithDatastore(ds), engine.WithHost(subject.Host()))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	_, ep2 := randomXProvider()
+	c, err := subject.AddExtendedProviders(ctx, nil, ep2)
+	require.True(t, errors.Is(err, engine.ErrNoExtendedProviderKey))
+	require.Equal(t, cid.Undef, c)
+
+	// Supplying the missing key again allows publishing.
+	_, err = subject.AddExtendedProviders(ctx, nil, ep1, ep2)
+	require.NoError(t, err)
+}
+
+func TestEngine_ExtendedProviderKeysRememberedOnlyOncePublished(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	_, ep1 := randomXProvider()
+	_, ep2 := randomXProvider()
+	ep2NoKey := ep2
+	ep2NoKey.Priv = nil
+	_, err = subject.AddExtendedProviders(ctx, nil, ep1, ep2NoKey)
+	require.ErrorIs(t, err, engine.ErrNoExtendedProviderKey)
+
+	// The key of ep1 was not remembered, since nothing was published.
+	ep1NoKey := ep1
+	ep1NoKey.Priv = nil
+	_, err = subject.AddExtendedProviders(ctx, nil, ep1NoKey)
+	require.ErrorIs(t, err, engine.ErrNoExtendedProviderKey)
+
+	_, err = subject.AddExtendedProviders(ctx, nil, ep1)
+	require.NoError(t, err)
+	_, err = subject.AddExtendedProviders(ctx, nil, ep1NoKey, ep2)
+	require.NoError(t, err)
+}
+
+func TestEngine_ExtendedProviderKeyLoader(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	ep1ID, ep1 := randomXProvider()
+	ep2ID, ep2 := randomXProvider()
+	keys := map[peer.ID]crypto.PrivKey{ep1ID: ep1.Priv}
+	loader := func(id peer.ID) (crypto.PrivKey, error) {
+		return keys[id], nil
+	}
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithExtendedProviderKeyLoader(loader))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	_, err = subject.NotifyExtendedProviders(ctx, nil, nil, false, ep1, ep2)
+	require.NoError(t, err)
+	require.NoError(t, subject.Shutdown())
+
+	// After a restart, the key of ep1 is loaded to remove ep2.
+	subject, err = engine.New(engine.WithDatastore(ds), engine.WithHost(subject.Host()),
+		engine.WithExtendedProviderKeyLoader(loader))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	_, err = subject.RemoveExtendedProviders(ctx, nil, ep2ID)
+	require.NoError(t, err)
+
+	// A loaded key must match the extended provider.
+	ep3ID, ep3 := randomXProvider()
+	keys[ep3ID] = ep2.Priv
+	ep3.Priv = nil
+	_, err = subject.AddExtendedProviders(ctx, nil, ep3)
+	require.ErrorContains(t, err, "does not match")
+}
+
+func randomXProvider() (peer.ID, xproviders.Info) {
+	id, priv, _ := random.Identity()
+	return id, xproviders.NewInfo(id, priv, []byte("meta"), random.Multiaddrs(1))
+}
+
+func xpIDs(ps []schema.Provider) []string {
+	ids := make([]string, 0, len(ps))
+	for _, p := range ps {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
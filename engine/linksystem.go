@@ -2,6 +2,7 @@ package engine
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 
@@ -22,6 +23,23 @@ var (
 	ErrEntriesLinkMismatch = errors.New("regenerated link from multihash lister did not match the original link; multihashes returned by the lister for the same key are not consistent")
 )
 
+type internalReadKey struct{}
+
+// withInternalRead marks the reads through the main engine link system with
+// the returned context as internal to the engine, so that they are not
+// reported as SyncServed.
+func withInternalRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalReadKey{}, true)
+}
+
+// emitServed emits SyncServed, unless the read is internal to the engine.
+func (e *Engine) emitServed(ctx context.Context, event SyncServed) {
+	if ctx != nil && ctx.Value(internalReadKey{}) != nil {
+		return
+	}
+	e.emit(event)
+}
+
 // Creates the main engine linksystem.
 func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 	lsys := cidlink.DefaultLinkSystem()
@@ -55,6 +73,7 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			// If this was an advertisement, then return it.
 			if isAdvertisement(n) {
 				log.Debugw("Retrieved advertisement from datastore", "cid", c, "size", len(val))
+				e.emitServed(ctx, SyncServed{Cid: c, Advertisement: true})
 				return bytes.NewBuffer(val), nil
 			}
 			log.Debugw("Retrieved non-advertisement object from datastore", "cid", c, "size", len(val))
@@ -130,6 +149,7 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 				log.Errorw("Regeneration of entries link from multihash iterator did not match the original link. Check that multihash iterator consistently returns the same entries for the same key.", "want", lnk, "got", regeneratedLink)
 				return nil, ErrEntriesLinkMismatch
 			}
+			e.emit(EntriesRegenerated{Cid: c, Provider: provider, ContextID: key.ContextID})
 		} else {
 			log.Debugw("Found cache entry for CID", "cid", c)
 		}
@@ -148,6 +168,7 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			return nil, datastore.ErrNotFound
 		}
 
+		e.emitServed(ctx, SyncServed{Cid: c})
 		return bytes.NewBuffer(val), nil
 	}
 
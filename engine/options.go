@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 
@@ -10,6 +11,7 @@ import (
 	_ "github.com/ipni/go-libipni/maurl"
 	"github.com/ipni/index-provider/engine/chunker"
 	"github.com/ipni/index-provider/engine/policy"
+	"github.com/ipni/index-provider/engine/signer"
 	"github.com/libp2p/go-libp2p"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/crypto"
@@ -36,11 +38,27 @@ const (
 	// Libp2pPublisher configurable as a single option.
 	Libp2pHttpPublisher PublisherKind = "libp2phttp"
 
+	// StaticPublisher writes advertisements and their entries into a local
+	// directory, in the layout served by HttpPublisher, whenever an
+	// advertisement is published. The directory can be copied to any static
+	// file host, from which indexers sync over HTTP. The address of the host
+	// must be set with WithHttpPublisherAnnounceAddr.
+	//
+	// See: WithStaticPublisherDir.
+	StaticPublisher PublisherKind = "static"
+
 	// Deprecated. Use Libp2pPublisher.
 	DataTransferPublisher PublisherKind = "dtsync"
 )
 
 type (
+	// ExtendedProviderKeyLoader loads the private key of an extended provider
+	// from a persistent source, such as a key file. It returns a nil key and
+	// no error if it has no key for the provider.
+	//
+	// See: WithExtendedProviderKeyLoader.
+	ExtendedProviderKeyLoader func(peer.ID) (crypto.PrivKey, error)
+
 	// PublisherKind represents the kind of publisher to use in order to announce a new
 	// advertisement to the network.
 	// See: WithPublisherKind
@@ -58,6 +76,8 @@ type (
 		// host identity. Otherwise, the signature of advertisement will not match the libp2p host
 		// ID.
 		key crypto.PrivKey
+		// signer, if set, signs in place of key.
+		signer signer.Signer
 
 		// It is important to not to change this parameter when running against
 		// existing datastores. The reason for that is to maintain backward
@@ -78,6 +98,7 @@ type (
 		pubHttpListenAddr    string
 		pubHttpWithoutServer bool
 		pubHttpHandlerPath   string
+		pubStaticDir         string
 		pubTopicName         string
 		pubTopic             *pubsub.Topic
 
@@ -98,6 +119,13 @@ type (
 
 		syncPolicy *policy.Policy
 
+		// reverseIndex enables the multihash to context ID index.
+		reverseIndex bool
+
+		// xpKeyLoader loads the private keys of extended providers that were
+		// not supplied since the engine started.
+		xpKeyLoader ExtendedProviderKeyLoader
+
 		storageReadOpenerErrorHook func(lctx ipld.LinkContext, lnk ipld.Link, err error) error
 	}
 )
@@ -135,6 +163,21 @@ func newOptions(o ...Option) (*options, error) {
 		opts.ds = dssync.MutexWrap(datastore.NewMapDatastore())
 	}
 
+	if opts.signer != nil {
+		opts.key = signer.PrivKey(opts.signer)
+		// The advertisements are signed by the signer on behalf of the
+		// provider, so the provider must be the signer identity.
+		signerID, err := signer.ID(opts.signer)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get signer peer id: %w", err)
+		}
+		if opts.provider.ID == "" {
+			opts.provider.ID = signerID
+		} else if opts.provider.ID != signerID {
+			return nil, fmt.Errorf("signer peer id %s does not match provider id %s", signerID, opts.provider.ID)
+		}
+	}
+
 	if (opts.key == nil || len(opts.provider.Addrs) == 0 || opts.provider.ID == "") && opts.h == nil {
 		// need a host
 		h, err := libp2p.New()
@@ -185,6 +228,34 @@ func WithPurgeCacheOnStart(p bool) Option {
 	}
 }
 
+// WithReverseIndex sets whether the engine keeps an index from each advertised
+// multihash to the provider and context IDs that advertise it. The index is
+// maintained by Engine.NotifyPut and Engine.NotifyRemove, using the multihash
+// lister, and queried with Engine.FindContexts. Content advertised while the
+// index is not enabled is only indexed once passed to Engine.IndexContext.
+//
+// If unset, the reverse index is not kept.
+func WithReverseIndex(enable bool) Option {
+	return func(o *options) error {
+		o.reverseIndex = enable
+		return nil
+	}
+}
+
+// WithExtendedProviderKeyLoader sets the loader of the private keys of
+// extended providers, used to sign advertisements on behalf of extended
+// providers whose keys were not supplied to the engine since it started, for
+// example when removing an extended provider after a restart.
+//
+// If unset, only keys supplied with Engine.NotifyExtendedProviders and
+// Engine.AddExtendedProviders since the engine started are used.
+func WithExtendedProviderKeyLoader(loader ExtendedProviderKeyLoader) Option {
+	return func(o *options) error {
+		o.xpKeyLoader = loader
+		return nil
+	}
+}
+
 // WithChainedEntries sets format of advertisement entries to chained Entry Chunk with the
 // given chunkSize as the maximum number of multihashes per chunk.
 //
@@ -249,11 +320,11 @@ func WithEntriesCacheCapacity(s int) Option {
 func WithPublisherKind(k PublisherKind) Option {
 	return func(o *options) error {
 		switch k {
-		case NoPublisher, HttpPublisher, Libp2pPublisher, Libp2pHttpPublisher:
+		case NoPublisher, HttpPublisher, Libp2pPublisher, Libp2pHttpPublisher, StaticPublisher:
 		case DataTransferPublisher:
 			return fmt.Errorf("publisher kind %q is no longer supported", DataTransferPublisher)
 		default:
-			return fmt.Errorf("unknown publisher kind %q, expecting one of %v", k, []PublisherKind{HttpPublisher, Libp2pPublisher, Libp2pHttpPublisher})
+			return fmt.Errorf("unknown publisher kind %q, expecting one of %v", k, []PublisherKind{HttpPublisher, Libp2pPublisher, Libp2pHttpPublisher, StaticPublisher})
 		}
 		o.pubKind = k
 		return nil
@@ -292,6 +363,20 @@ func WithHttpPublisherHandlerPath(handlerPath string) Option {
 	}
 }
 
+// WithStaticPublisherDir sets the directory into which the StaticPublisher
+// writes the advertisement chain. The head is written at ipni/v1/ad/head
+// under the directory, and advertisement and entries blocks at
+// ipni/v1/ad/<cid>.
+//
+// This option only takes effect if the PublisherKind is set to StaticPublisher.
+// See: WithPublisherKind.
+func WithStaticPublisherDir(dir string) Option {
+	return func(o *options) error {
+		o.pubStaticDir = dir
+		return nil
+	}
+}
+
 // WithHttpPublisherAnnounceAddr sets the address to be supplied in announce
 // messages to tell indexers where to retrieve advertisements.
 //
@@ -391,6 +476,24 @@ func WithProvider(provider peer.AddrInfo) Option {
 	}
 }
 
+// WithSigner sets the Signer used to sign advertisements and published heads.
+// This allows the provider's private key to be kept outside the engine, for
+// example in an encrypted keystore or in an external signer process. The
+// signer identity must match the provider ID, otherwise indexers will reject
+// the advertisements, so the engine fails to start if they differ. If no
+// provider ID is set, the signer identity is used as the provider ID.
+//
+// WithSigner overrides WithPrivateKey.
+func WithSigner(s signer.Signer) Option {
+	return func(o *options) error {
+		if s == nil {
+			return errors.New("signer must not be nil")
+		}
+		o.signer = s
+		return nil
+	}
+}
+
 func WithPrivateKey(key crypto.PrivKey) Option {
 	return func(o *options) error {
 		o.key = key
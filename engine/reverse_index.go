@@ -0,0 +1,501 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/ipni/go-libipni/ingest/schema"
+	provider "github.com/ipni/index-provider"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	mhToContextMapPrefix     = "map/mhCtx/"
+	contextIndexedMapPrefix  = "map/ctxIndexed/"
+	providerToAddrsMapPrefix = "map/provAddrs/"
+)
+
+// ErrNoReverseIndex signals that the reverse index is not enabled. See:
+// WithReverseIndex.
+var ErrNoReverseIndex = errors.New("reverse index not enabled")
+
+// ContextRef identifies a context ID advertised by a provider.
+type ContextRef struct {
+	Provider  peer.ID
+	ContextID []byte
+}
+
+// FindContexts returns the provider and context IDs currently advertised that
+// include the multihash mh, according to the reverse index. If the multihash
+// is not advertised, then an empty slice is returned.
+//
+// Returns ErrNoReverseIndex if the engine was not created with the reverse
+// index enabled. See: WithReverseIndex.
+func (e *Engine) FindContexts(ctx context.Context, mh multihash.Multihash) ([]ContextRef, error) {
+	if !e.reverseIndex {
+		return nil, ErrNoReverseIndex
+	}
+
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix:   mhToContextPrefix(mh),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var refs []ContextRef
+	var stale []datastore.Key
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return nil, r.Error
+		}
+		key := datastore.RawKey(r.Key)
+		ref, err := decodeContextRef(key.BaseNamespace())
+		if err != nil {
+			log.Warnw("Invalid reverse index key", "key", r.Key, "err", err)
+			continue
+		}
+		// Entries of context IDs that could not be listed when they were
+		// removed are cleaned up here.
+		if _, err = e.getKeyCidMap(ctx, ref.Provider, ref.ContextID); err != nil {
+			if !errors.Is(err, datastore.ErrNotFound) {
+				results.Close()
+				return nil, err
+			}
+			stale = append(stale, key)
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	results.Close()
+
+	for _, key := range stale {
+		if err = e.ds.Delete(ctx, key); err != nil {
+			log.Errorw("Failed to delete stale reverse index entry", "key", key, "err", err)
+		}
+	}
+	return refs, nil
+}
+
+// FindProviderResults returns the records of the context IDs currently
+// advertised that include the multihash mh, in the form an indexer returns
+// them in a find response. Each result carries the stored metadata of the
+// context ID and the addresses most recently advertised by its provider.
+// Context IDs of the engine's default provider are followed by a result for
+// each of their extended providers, which carries the extended provider's own
+// metadata if it has any. Chain level extended providers are included unless
+// the context level ones override them.
+//
+// Returns ErrNoReverseIndex if the engine was not created with the reverse
+// index enabled. See: WithReverseIndex.
+func (e *Engine) FindProviderResults(ctx context.Context, mh multihash.Multihash) ([]model.ProviderResult, error) {
+	refs, err := e.FindContexts(ctx, mh)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]model.ProviderResult, 0, len(refs))
+	for _, ref := range refs {
+		md, err := e.getKeyMetadataMap(ctx, ref.Provider, ref.ContextID)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				// Removed since the reverse index was read.
+				continue
+			}
+			return nil, err
+		}
+		mdBytes, err := md.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := e.getProviderAddrs(ctx, ref.Provider)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, model.ProviderResult{
+			ContextID: ref.ContextID,
+			Metadata:  mdBytes,
+			Provider:  &peer.AddrInfo{ID: ref.Provider, Addrs: addrs},
+		})
+		if ref.Provider != e.provider.ID {
+			continue
+		}
+		if results, err = e.appendExtendedProviderResults(ctx, results, ref.ContextID, mdBytes); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// appendExtendedProviderResults appends a result for each extended provider of
+// the context ID of the default provider, as an indexer would.
+func (e *Engine) appendExtendedProviderResults(ctx context.Context, results []model.ProviderResult, contextID, md []byte) ([]model.ProviderResult, error) {
+	var recs []*extendedProvidersRecord
+	override := false
+	if len(contextID) != 0 {
+		rec, err := e.getExtendedProvidersRecord(ctx, contextID)
+		if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+			return nil, err
+		}
+		if rec != nil {
+			recs = append(recs, rec)
+			override = rec.Override
+		}
+	}
+	if !override {
+		rec, err := e.getExtendedProvidersRecord(ctx, nil)
+		if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+			return nil, err
+		}
+		if rec != nil {
+			recs = append(recs, rec)
+		}
+	}
+
+	seen := map[peer.ID]struct{}{e.provider.ID: {}}
+	for _, rec := range recs {
+		for _, epRec := range rec.Providers {
+			epID, err := peer.Decode(epRec.ID)
+			if err != nil {
+				return nil, fmt.Errorf("bad extended provider id %q: %w", epRec.ID, err)
+			}
+			if _, ok := seen[epID]; ok {
+				continue
+			}
+			seen[epID] = struct{}{}
+			addrs, err := stringsToMultiaddrs(epRec.Addrs)
+			if err != nil {
+				return nil, fmt.Errorf("bad extended provider address: %w", err)
+			}
+			epMd := epRec.Metadata
+			if len(epMd) == 0 {
+				epMd = md
+			}
+			results = append(results, model.ProviderResult{
+				ContextID: contextID,
+				Metadata:  epMd,
+				Provider:  &peer.AddrInfo{ID: epID, Addrs: addrs},
+			})
+		}
+	}
+	return results, nil
+}
+
+// putProviderAddrs stores the addresses most recently advertised by the
+// provider, which are returned by FindProviderResults.
+func (e *Engine) putProviderAddrs(ctx context.Context, p peer.ID, addrs []multiaddr.Multiaddr) error {
+	strs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		strs = append(strs, addr.String())
+	}
+	b, err := json.Marshal(strs)
+	if err != nil {
+		return err
+	}
+	return e.ds.Put(ctx, providerToAddrsKey(p), b)
+}
+
+// getProviderAddrs returns the addresses most recently advertised by the
+// provider. The configured addresses of the default provider are returned if
+// none were stored.
+func (e *Engine) getProviderAddrs(ctx context.Context, p peer.ID) ([]multiaddr.Multiaddr, error) {
+	b, err := e.ds.Get(ctx, providerToAddrsKey(p))
+	if err != nil {
+		if !errors.Is(err, datastore.ErrNotFound) {
+			return nil, err
+		}
+		if p == e.provider.ID {
+			return e.provider.Addrs, nil
+		}
+		return nil, nil
+	}
+	var strs []string
+	if err = json.Unmarshal(b, &strs); err != nil {
+		return nil, err
+	}
+	return stringsToMultiaddrs(strs)
+}
+
+func contextIndexedKey(ref string) datastore.Key {
+	return datastore.NewKey(contextIndexedMapPrefix + ref)
+}
+
+func providerToAddrsKey(p peer.ID) datastore.Key {
+	return datastore.NewKey(providerToAddrsMapPrefix + p.String())
+}
+
+func stringsToMultiaddrs(strs []string) ([]multiaddr.Multiaddr, error) {
+	addrs := make([]multiaddr.Multiaddr, 0, len(strs))
+	for _, s := range strs {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// reverseIndexIterator adds the multihashes it returns to the reverse index,
+// so that the reverse index is written in the same pass over the multihashes
+// that chunks them into entries.
+type reverseIndexIterator struct {
+	provider.MultihashIterator
+	ctx   context.Context
+	batch datastore.Batch
+	ref   string
+}
+
+// indexMultihashes wraps mhIter so that the multihashes it returns are added
+// to the reverse index for the provider and context ID. The additions are
+// written by commit, once mhIter has been read to the end.
+func (e *Engine) indexMultihashes(ctx context.Context, p peer.ID, contextID []byte, mhIter provider.MultihashIterator) (*reverseIndexIterator, error) {
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &reverseIndexIterator{
+		MultihashIterator: mhIter,
+		ctx:               ctx,
+		batch:             b,
+		ref:               encodeContextRef(p, contextID),
+	}, nil
+}
+
+func (it *reverseIndexIterator) Next() (multihash.Multihash, error) {
+	mh, err := it.MultihashIterator.Next()
+	if err != nil {
+		return nil, err
+	}
+	if err = it.batch.Put(it.ctx, datastore.NewKey(mhToContextPrefix(mh)+it.ref), []byte{}); err != nil {
+		return nil, err
+	}
+	return mh, nil
+}
+
+// commit writes the additions to the reverse index, and records that the
+// context ID is indexed with the given entries.
+func (it *reverseIndexIterator) commit(ctx context.Context, entries cid.Cid) error {
+	if err := it.batch.Put(ctx, contextIndexedKey(it.ref), entries.Bytes()); err != nil {
+		return err
+	}
+	return it.batch.Commit(ctx)
+}
+
+// IndexContext adds the multihashes of the provider and context ID to the
+// reverse index, unless they are already indexed. It backfills the reverse
+// index with content advertised while the reverse index was not enabled.
+// Context IDs that are not currently advertised are ignored. An empty
+// provider ID represents the default provider of the engine.
+//
+// The multihashes are listed with the registered provider.MultihashLister
+// without holding up publishing.
+//
+// Returns ErrNoReverseIndex if the engine was not created with the reverse
+// index enabled. See: WithReverseIndex.
+func (e *Engine) IndexContext(ctx context.Context, p peer.ID, contextID []byte) error {
+	if !e.reverseIndex {
+		return ErrNoReverseIndex
+	}
+	if p == "" {
+		p = e.provider.ID
+	}
+	ref := encodeContextRef(p, contextID)
+
+	entries, indexed, err := e.contextIndexState(ctx, p, contextID, ref)
+	if err != nil || entries == cid.Undef || entries == indexed {
+		return err
+	}
+	if e.mhLister == nil {
+		return provider.ErrNoMultihashLister
+	}
+	// Drop the multihashes indexed with previously advertised entries, if
+	// they are still known.
+	if indexed != cid.Undef {
+		if err = e.removeCachedFromReverseIndex(ctx, ref, indexed); err != nil {
+			return err
+		}
+	}
+	mhIter, err := e.mhLister(ctx, p, contextID)
+	if err != nil {
+		return err
+	}
+	it, err := e.indexMultihashes(ctx, p, contextID, mhIter)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err = it.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+	}
+
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+	// Only write the additions if the context ID was neither removed nor
+	// indexed while its multihashes were listed.
+	current, nowIndexed, err := e.contextIndexState(ctx, p, contextID, ref)
+	if err != nil || current != entries || nowIndexed == entries {
+		return err
+	}
+	return it.commit(ctx, entries)
+}
+
+// contextIndexState returns the entries currently advertised for the context
+// ID, and those it was last indexed with. Either is cid.Undef if there are
+// none.
+func (e *Engine) contextIndexState(ctx context.Context, p peer.ID, contextID []byte, ref string) (cid.Cid, cid.Cid, error) {
+	entries, err := e.getKeyCidMap(ctx, p, contextID)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return cid.Undef, cid.Undef, nil
+		}
+		return cid.Undef, cid.Undef, err
+	}
+	b, err := e.ds.Get(ctx, contextIndexedKey(ref))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return entries, cid.Undef, nil
+		}
+		return cid.Undef, cid.Undef, err
+	}
+	_, indexed, err := cid.CidFromBytes(b)
+	if err != nil {
+		return cid.Undef, cid.Undef, err
+	}
+	return entries, indexed, nil
+}
+
+// removeCachedFromReverseIndex removes the multihashes in the cached entry
+// chunks of entries from the reverse index for the context ref. Nothing is
+// removed if the chunks are no longer cached.
+func (e *Engine) removeCachedFromReverseIndex(ctx context.Context, ref string, entries cid.Cid) error {
+	mhs, err := e.cachedMultihashes(ctx, entries)
+	if err != nil || mhs == nil {
+		return err
+	}
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mh := range mhs {
+		if err = b.Delete(ctx, datastore.NewKey(mhToContextPrefix(mh)+ref)); err != nil {
+			return err
+		}
+	}
+	return b.Commit(ctx)
+}
+
+// unindexMultihashes removes the multihashes of the provider and context ID
+// from the reverse index. The multihashes are read from the cached entry
+// chunks of the entries link, and are only listed again if the chunks are no
+// longer cached.
+func (e *Engine) unindexMultihashes(ctx context.Context, p peer.ID, contextID []byte, entries cid.Cid) error {
+	mhs, err := e.cachedMultihashes(ctx, entries)
+	if err != nil {
+		return err
+	}
+	var mhIter provider.MultihashIterator
+	if mhs != nil {
+		mhIter = provider.SliceMultihashIterator(mhs)
+	} else {
+		if e.mhLister == nil {
+			return nil
+		}
+		if mhIter, err = e.mhLister(ctx, p, contextID); err != nil {
+			return err
+		}
+	}
+	b, err := e.ds.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	ref := encodeContextRef(p, contextID)
+	if err = b.Delete(ctx, contextIndexedKey(ref)); err != nil {
+		return err
+	}
+	for {
+		mh, err := mhIter.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		if err = b.Delete(ctx, datastore.NewKey(mhToContextPrefix(mh)+ref)); err != nil {
+			return err
+		}
+	}
+	return b.Commit(ctx)
+}
+
+// cachedMultihashes returns the multihashes in the entry chunks linked from
+// entries, or nil if any of the chunks is not cached.
+func (e *Engine) cachedMultihashes(ctx context.Context, entries cid.Cid) ([]multihash.Multihash, error) {
+	var mhs []multihash.Multihash
+	next := entries
+	for next != cid.Undef && next != schema.NoEntries.Cid {
+		raw, err := e.entriesChunker.GetRawCachedChunk(ctx, cidlink.Link{Cid: next})
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			return nil, nil
+		}
+		chunk, err := schema.BytesToEntryChunk(next, raw)
+		if err != nil {
+			return nil, err
+		}
+		mhs = append(mhs, chunk.Entries...)
+		next = cid.Undef
+		if chunk.Next != nil {
+			next = chunk.Next.(cidlink.Link).Cid
+		}
+	}
+	return mhs, nil
+}
+
+func mhToContextPrefix(mh multihash.Multihash) string {
+	return "/" + mhToContextMapPrefix + mh.B58String() + "/"
+}
+
+// encodeContextRef encodes the provider and context ID into a single datastore
+// key namespace.
+func encodeContextRef(p peer.ID, contextID []byte) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(p)) + "." + base64.RawURLEncoding.EncodeToString(contextID)
+}
+
+func decodeContextRef(s string) (ContextRef, error) {
+	ps, cs, ok := strings.Cut(s, ".")
+	if !ok {
+		return ContextRef{}, errors.New("missing separator")
+	}
+	pb, err := base64.RawURLEncoding.DecodeString(ps)
+	if err != nil {
+		return ContextRef{}, fmt.Errorf("bad provider: %w", err)
+	}
+	p, err := peer.IDFromBytes(pb)
+	if err != nil {
+		return ContextRef{}, fmt.Errorf("bad provider: %w", err)
+	}
+	contextID, err := base64.RawURLEncoding.DecodeString(cs)
+	if err != nil {
+		return ContextRef{}, fmt.Errorf("bad context id: %w", err)
+	}
+	return ContextRef{Provider: p, ContextID: contextID}, nil
+}
@@ -0,0 +1,205 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_FindContexts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	shared := random.Multihashes(1)[0]
+	contents := map[string][]multihash.Multihash{
+		"fish":    append(random.Multihashes(5), shared),
+		"lobster": append(random.Multihashes(5), shared),
+	}
+	var listErr error
+	var listed int
+	lister := func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		listed++
+		if listErr != nil {
+			return nil, listErr
+		}
+		return provider.SliceMultihashIterator(contents[string(contextID)]), nil
+	}
+
+	disabled, err := engine.New()
+	require.NoError(t, err)
+	_, err = disabled.FindContexts(ctx, shared)
+	require.ErrorIs(t, err, engine.ErrNoReverseIndex)
+
+	subject, err := engine.New(engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	otherProvider := random.Peers(1)[0]
+	_, err = subject.NotifyPut(ctx, &peer.AddrInfo{ID: otherProvider}, []byte("lobster"), md)
+	require.NoError(t, err)
+	// The reverse index is written while chunking the entries.
+	require.Equal(t, 2, listed)
+
+	refs, err := subject.FindContexts(ctx, contents["fish"][0])
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	require.Equal(t, "fish", string(refs[0].ContextID))
+
+	refs, err = subject.FindContexts(ctx, shared)
+	require.NoError(t, err)
+	require.Len(t, refs, 2)
+
+	refs, err = subject.FindContexts(ctx, random.Multihashes(1)[0])
+	require.NoError(t, err)
+	require.Empty(t, refs)
+
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	// The removed entries are read from the cached entry chunks.
+	require.Equal(t, 2, listed)
+	refs, err = subject.FindContexts(ctx, shared)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	require.Equal(t, otherProvider, refs[0].Provider)
+	require.Equal(t, "lobster", string(refs[0].ContextID))
+
+	// Content that is no longer cached nor can be listed when removed is
+	// still dropped from the results.
+	require.NoError(t, subject.Chunker().Clear(ctx))
+	listErr = errors.New("content gone")
+	_, err = subject.NotifyRemove(ctx, otherProvider, []byte("lobster"))
+	require.NoError(t, err)
+	refs, err = subject.FindContexts(ctx, shared)
+	require.NoError(t, err)
+	require.Empty(t, refs)
+}
+
+func TestEngine_FindProviderResults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	mhs := random.Multihashes(3)
+	subject, err := engine.New(engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	mdBytes, err := md.MarshalBinary()
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	other := peer.AddrInfo{ID: random.Peers(1)[0], Addrs: random.Multiaddrs(2)}
+	_, err = subject.NotifyPut(ctx, &other, []byte("lobster"), md)
+	require.NoError(t, err)
+
+	chainXpID, chainXp := randomXProvider()
+	_, err = subject.NotifyExtendedProviders(ctx, nil, nil, false, chainXp)
+	require.NoError(t, err)
+	ctxXpID, ctxXp := randomXProvider()
+	ctxXp.Metadata = nil
+	_, err = subject.NotifyExtendedProviders(ctx, []byte("fish"), mdBytes, false, ctxXp)
+	require.NoError(t, err)
+
+	byProvider := func() map[peer.ID]model.ProviderResult {
+		results, err := subject.FindProviderResults(ctx, mhs[0])
+		require.NoError(t, err)
+		found := make(map[peer.ID]model.ProviderResult, len(results))
+		for _, r := range results {
+			found[r.Provider.ID] = r
+		}
+		require.Len(t, found, len(results))
+		return found
+	}
+
+	found := byProvider()
+	require.Len(t, found, 4)
+	require.Equal(t, "fish", string(found[subject.ProviderID()].ContextID))
+	require.Equal(t, "lobster", string(found[other.ID].ContextID))
+	require.Equal(t, other.Addrs, found[other.ID].Provider.Addrs)
+	// Extended providers of the default provider carry their own metadata,
+	// or that of the context ID if they have none.
+	require.Equal(t, "fish", string(found[ctxXpID].ContextID))
+	require.Equal(t, mdBytes, found[ctxXpID].Metadata)
+	require.Len(t, found[ctxXpID].Provider.Addrs, 1)
+	require.Equal(t, "fish", string(found[chainXpID].ContextID))
+	require.Equal(t, []byte("meta"), found[chainXpID].Metadata)
+
+	// Context level extended providers that override the chain level ones
+	// hide them.
+	_, err = subject.NotifyExtendedProviders(ctx, []byte("fish"), mdBytes, true, ctxXp)
+	require.NoError(t, err)
+	found = byProvider()
+	require.Len(t, found, 3)
+	require.NotContains(t, found, chainXpID)
+}
+
+func TestEngine_IndexContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	mhs := random.Multihashes(3)
+	var listed int
+	lister := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		listed++
+		return provider.SliceMultihashIterator(mhs), nil
+	}
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	_, priv, _ := random.Identity()
+
+	// Content advertised before the reverse index is enabled.
+	before, err := engine.New(engine.WithDatastore(ds), engine.WithPrivateKey(priv))
+	require.NoError(t, err)
+	require.NoError(t, before.Start(ctx))
+	before.RegisterMultihashLister(lister)
+	_, err = before.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.NoError(t, before.Shutdown())
+	require.ErrorIs(t, before.IndexContext(ctx, "", []byte("fish")), engine.ErrNoReverseIndex)
+
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithPrivateKey(priv), engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+
+	refs, err := subject.FindContexts(ctx, mhs[0])
+	require.NoError(t, err)
+	require.Empty(t, refs)
+
+	listed = 0
+	require.NoError(t, subject.IndexContext(ctx, "", []byte("fish")))
+	require.Equal(t, 1, listed)
+	refs, err = subject.FindContexts(ctx, mhs[0])
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	require.Equal(t, "fish", string(refs[0].ContextID))
+
+	// Context IDs already indexed, or not advertised, are not listed.
+	require.NoError(t, subject.IndexContext(ctx, "", []byte("fish")))
+	require.NoError(t, subject.IndexContext(ctx, "", []byte("lobster")))
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	listed = 0
+	require.NoError(t, subject.IndexContext(ctx, "", []byte("lobster")))
+	require.Zero(t, listed)
+}
@@ -0,0 +1,429 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine/xproviders"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const rotatePrefix = "rotate/"
+
+// RotateMode determines how the advertisements of the old identity are
+// handed over to the new identity during RotateIdentity.
+type RotateMode string
+
+const (
+	// RotateRemove removes every context ID advertised by the old identity
+	// before it is re-advertised by the new identity.
+	RotateRemove RotateMode = "remove"
+	// RotateExtendedProviders adds the new identity as a chain level extended
+	// provider of the old identity, so that content advertised by the old
+	// identity remains discoverable via the new identity.
+	RotateExtendedProviders RotateMode = "xproviders"
+)
+
+// Phases of an identity rotation, recorded so that it can be resumed.
+const (
+	rotatePhaseSnapshot = iota + 1
+	rotatePhaseOld
+	rotatePhaseNew
+)
+
+// States of each context ID being rotated.
+const (
+	rotateCtxPending byte = iota
+	rotateCtxOldDone
+	rotateCtxNewDone
+)
+
+// rotateRecord is the persisted progress of an identity rotation.
+type rotateRecord struct {
+	Mode  RotateMode `json:"m"`
+	Phase int        `json:"p"`
+	// Head is the head of the chain of the old identity when context IDs
+	// were last recorded.
+	Head string `json:"h,omitempty"`
+}
+
+func (r *rotateRecord) head() cid.Cid {
+	c, err := cid.Decode(r.Head)
+	if err != nil {
+		return cid.Undef
+	}
+	return c
+}
+
+// RotateIdentity hands over everything currently advertised by the from
+// engine to the to engine, whose default provider ID must differ. This is
+// used to change the identity of a provider without leaving indexers with
+// orphaned advertisements.
+//
+// First, the from engine publishes, depending on mode, either a removal
+// advertisement for each advertised context ID or a chain level extended
+// providers advertisement that points to the identity of the to engine. Then,
+// the to engine advertises each context ID again on its own chain, with the
+// same metadata, using its registered multihash lister. Context IDs
+// advertised for providers other than the default provider of the from engine
+// are re-advertised for the same provider. The chain level extended providers
+// of the from engine are advertised on the chain of the to engine too.
+//
+// The from engine may keep publishing during the rotation. Context IDs it
+// advertises meanwhile are handed over too, and RotateIdentity only returns
+// once a walk of the from chain finds nothing left to hand over.
+//
+// Progress is recorded in the datastore of the from engine, so that
+// RotateIdentity can be called again after a failure to resume the rotation
+// where it stopped. The to engine must therefore use a separate datastore, or
+// a separate namespace within the same datastore, from the from engine. A
+// resumed rotation must use the same mode as the original one.
+func RotateIdentity(ctx context.Context, from, to *Engine, mode RotateMode) error {
+	if from.provider.ID == to.provider.ID {
+		return errors.New("rotation requires a different provider identity")
+	}
+	switch mode {
+	case RotateRemove, RotateExtendedProviders:
+	default:
+		return fmt.Errorf("unknown rotation mode: %s", mode)
+	}
+
+	log := log.With("from", from.provider.ID, "to", to.provider.ID)
+
+	rec, err := from.getRotateRecord(ctx, to.provider.ID)
+	if err != nil {
+		if !errors.Is(err, datastore.ErrNotFound) {
+			return err
+		}
+		rec = &rotateRecord{Mode: mode}
+	}
+	if rec.Mode != mode {
+		return fmt.Errorf("rotation already started with mode %s", rec.Mode)
+	}
+
+	for {
+		if rec.Phase < rotatePhaseSnapshot {
+			count, head, err := from.snapshotAdvertised(ctx, to.provider.ID, rec.head())
+			if err != nil {
+				return fmt.Errorf("cannot record advertised context ids: %w", err)
+			}
+			log.Infow("Recorded context IDs to rotate", "count", count)
+			rec.Head = head.String()
+			rec.Phase = rotatePhaseSnapshot
+			if err = from.putRotateRecord(ctx, to.provider.ID, rec); err != nil {
+				return err
+			}
+		}
+
+		if rec.Phase < rotatePhaseOld {
+			switch mode {
+			case RotateRemove:
+				err = from.rotateEach(ctx, to.provider.ID, rotateCtxPending, rotateCtxOldDone, func(ad *rotateAd) error {
+					_, err := from.NotifyRemove(ctx, ad.provider, ad.contextID)
+					if errors.Is(err, provider.ErrContextIDNotFound) {
+						return nil
+					}
+					return err
+				})
+			case RotateExtendedProviders:
+				err = from.addRotatedProvider(ctx, to)
+			}
+			if err != nil {
+				return fmt.Errorf("cannot publish advertisements for old identity: %w", err)
+			}
+			log.Info("Published advertisements for old identity")
+			rec.Phase = rotatePhaseOld
+			if err = from.putRotateRecord(ctx, to.provider.ID, rec); err != nil {
+				return err
+			}
+		}
+
+		if rec.Phase >= rotatePhaseNew {
+			return nil
+		}
+		if err = from.rotateExtendedProviders(ctx, to); err != nil {
+			return fmt.Errorf("cannot advertise extended providers for new identity: %w", err)
+		}
+		// Context IDs are not removed individually when adding the new
+		// identity as an extended provider.
+		state := rotateCtxOldDone
+		if mode == RotateExtendedProviders {
+			state = rotateCtxPending
+		}
+		err = from.rotateEach(ctx, to.provider.ID, state, rotateCtxNewDone, func(ad *rotateAd) error {
+			p := peer.AddrInfo{
+				ID:    ad.provider,
+				Addrs: ad.addrs,
+			}
+			if p.ID == from.provider.ID {
+				p = to.provider
+			}
+			_, err := to.NotifyPut(ctx, &p, ad.contextID, ad.metadata)
+			if errors.Is(err, provider.ErrAlreadyAdvertised) {
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("cannot advertise content for new identity: %w", err)
+		}
+		log.Info("Advertised content for new identity")
+
+		// The old identity keeps publishing during the rotation, so rotate
+		// again whatever it advertised since the snapshot, until nothing new
+		// is advertised.
+		count, head, err := from.snapshotAdvertised(ctx, to.provider.ID, rec.head())
+		if err != nil {
+			return fmt.Errorf("cannot record advertised context ids: %w", err)
+		}
+		rec.Head = head.String()
+		if count == 0 {
+			rec.Phase = rotatePhaseNew
+		} else {
+			log.Infow("Recorded context IDs advertised during rotation", "count", count)
+			rec.Phase = rotatePhaseSnapshot
+		}
+		if err = from.putRotateRecord(ctx, to.provider.ID, rec); err != nil {
+			return err
+		}
+	}
+}
+
+// rotateAd is an advertisement of a context ID to hand over in a rotation.
+type rotateAd struct {
+	provider  peer.ID
+	addrs     []multiaddr.Multiaddr
+	contextID []byte
+	metadata  metadata.Metadata
+}
+
+// snapshotAdvertised records the CID of the latest advertisement of every
+// context ID that is currently advertised, by walking the chain from its head
+// back to stopAt, exclusive, or to the start of the chain if stopAt is
+// cid.Undef. It returns the number of context IDs recorded, and the head from
+// which the chain was walked. The chain lock is held during the walk, so that
+// no advertisement is published between the walk and reading the head.
+func (e *Engine) snapshotAdvertised(ctx context.Context, to peer.ID, stopAt cid.Cid) (int, cid.Cid, error) {
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return 0, cid.Undef, err
+	}
+	seen := make(map[string]struct{})
+	var count int
+	for adCid := head; adCid != cid.Undef && adCid != stopAt; {
+		ad, err := e.GetAdv(ctx, adCid)
+		if err != nil {
+			return 0, cid.Undef, fmt.Errorf("cannot load advertisement %s: %w", adCid, err)
+		}
+		key := ad.Provider + "/" + string(ad.ContextID)
+		// Extended provider advertisements do not change what is advertised
+		// for a context ID, so are skipped.
+		if _, ok := seen[key]; !ok && ad.ExtendedProvider == nil {
+			seen[key] = struct{}{}
+			p, err := peer.Decode(ad.Provider)
+			if err != nil {
+				return 0, cid.Undef, err
+			}
+			// Only the latest advertisement of a context ID that still has a
+			// mapping to its entries is current.
+			if !ad.IsRm {
+				recorded, err := e.recordRotateCtx(ctx, to, p, ad.ContextID, adCid)
+				if err != nil {
+					return 0, cid.Undef, err
+				}
+				if recorded {
+					count++
+				}
+			}
+		}
+		if ad.PreviousID == nil {
+			break
+		}
+		adCid = ad.PreviousID.(cidlink.Link).Cid
+	}
+	return count, head, nil
+}
+
+// recordRotateCtx records the context ID advertised by adCid as pending, if it
+// still has a mapping to its entries and is not already recorded.
+func (e *Engine) recordRotateCtx(ctx context.Context, to, p peer.ID, contextID []byte, adCid cid.Cid) (bool, error) {
+	if _, err := e.getKeyCidMap(ctx, p, contextID); err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	key := rotateCtxKey(to, adCid)
+	if has, err := e.ds.Has(ctx, key); err != nil || has {
+		return false, err
+	}
+	if err := e.ds.Put(ctx, key, []byte{rotateCtxPending}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rotateEach calls fn for each recorded context ID in the given state, and
+// sets the state of the context ID to next once fn succeeds.
+func (e *Engine) rotateEach(ctx context.Context, to peer.ID, state, next byte, fn func(*rotateAd) error) error {
+	results, err := e.ds.Query(ctx, query.Query{Prefix: rotateCtxPrefix(to)})
+	if err != nil {
+		return err
+	}
+	var adCids []cid.Cid
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return r.Error
+		}
+		if len(r.Value) != 1 || r.Value[0] != state {
+			continue
+		}
+		adCid, err := cid.Decode(datastore.RawKey(r.Key).BaseNamespace())
+		if err != nil {
+			results.Close()
+			return err
+		}
+		adCids = append(adCids, adCid)
+	}
+	results.Close()
+
+	for _, adCid := range adCids {
+		ad, err := e.GetAdv(ctx, adCid)
+		if err != nil {
+			return fmt.Errorf("cannot load advertisement %s: %w", adCid, err)
+		}
+		rad := &rotateAd{
+			contextID: ad.ContextID,
+			metadata:  metadata.Default.New(),
+		}
+		if rad.provider, err = peer.Decode(ad.Provider); err != nil {
+			return err
+		}
+		for _, a := range ad.Addresses {
+			ma, err := multiaddr.NewMultiaddr(a)
+			if err != nil {
+				return err
+			}
+			rad.addrs = append(rad.addrs, ma)
+		}
+		if err = rad.metadata.UnmarshalBinary(ad.Metadata); err != nil {
+			return err
+		}
+		if err = fn(rad); err != nil {
+			return err
+		}
+		if err = e.ds.Put(ctx, rotateCtxKey(to, adCid), []byte{next}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRotatedProvider adds the default provider of the given engine as a chain
+// level extended provider of this engine.
+func (e *Engine) addRotatedProvider(ctx context.Context, to *Engine) error {
+	ep := xproviders.Info{
+		ID:    to.provider.ID.String(),
+		Addrs: to.retrievalAddrsAsString(),
+		Priv:  to.key,
+	}
+	_, err := e.AddExtendedProviders(ctx, nil, ep)
+	if errors.Is(err, provider.ErrAlreadyAdvertised) {
+		return nil
+	}
+	return err
+}
+
+// rotateExtendedProviders advertises the chain level extended providers of
+// this engine on the chain of the given engine, so that they keep applying to
+// the content advertised by the new identity. The old identity, if it is an
+// extended provider of itself, is replaced with the new one, and the new
+// identity, if added by RotateExtendedProviders, is left out since it is the
+// main provider of its own chain.
+func (e *Engine) rotateExtendedProviders(ctx context.Context, to *Engine) error {
+	xps, err := e.GetExtendedProviders(ctx, nil)
+	if err != nil || xps == nil {
+		return err
+	}
+
+	eps := make([]xproviders.Info, 0, len(xps.Providers))
+	e.chainLock.Lock()
+	for _, xp := range xps.Providers {
+		epID, err := peer.Decode(xp.ID)
+		if err != nil {
+			e.chainLock.Unlock()
+			return fmt.Errorf("invalid extended provider peer id %q: %w", xp.ID, err)
+		}
+		switch epID {
+		case to.provider.ID:
+			continue
+		case e.provider.ID:
+			xp.ID = to.provider.ID.String()
+			xp.Addrs = to.retrievalAddrsAsString()
+			xp.Priv = to.key
+		default:
+			if xp.Priv, err = e.extendedProviderKey(epID, nil); err != nil {
+				e.chainLock.Unlock()
+				return err
+			}
+		}
+		eps = append(eps, xp)
+	}
+	e.chainLock.Unlock()
+
+	if len(eps) == 0 {
+		return nil
+	}
+	_, err = to.NotifyExtendedProviders(ctx, nil, xps.Metadata, false, eps...)
+	if errors.Is(err, provider.ErrAlreadyAdvertised) {
+		return nil
+	}
+	return err
+}
+
+func (e *Engine) getRotateRecord(ctx context.Context, to peer.ID) (*rotateRecord, error) {
+	b, err := e.ds.Get(ctx, rotateKey(to))
+	if err != nil {
+		return nil, err
+	}
+	var rec rotateRecord
+	if err = json.Unmarshal(b, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (e *Engine) putRotateRecord(ctx context.Context, to peer.ID, rec *rotateRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return e.ds.Put(ctx, rotateKey(to), b)
+}
+
+func rotateKey(to peer.ID) datastore.Key {
+	return datastore.NewKey(rotatePrefix + to.String())
+}
+
+// rotateCtxKey returns the key that records the state of the context ID
+// advertised by adCid.
+func rotateCtxKey(to peer.ID, adCid cid.Cid) datastore.Key {
+	return datastore.NewKey(rotateCtxPrefix(to) + adCid.String())
+}
+
+func rotateCtxPrefix(to peer.ID) string {
+	return "/" + rotatePrefix + to.String() + "/ctx/"
+}
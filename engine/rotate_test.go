@@ -0,0 +1,216 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dsn "github.com/ipfs/go-datastore/namespace"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-test/random"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateIdentity(t *testing.T) {
+	for _, mode := range []engine.RotateMode{engine.RotateRemove, engine.RotateExtendedProviders} {
+		t.Run(string(mode), func(t *testing.T) {
+			testRotateIdentity(t, mode)
+		})
+	}
+}
+
+func testRotateIdentity(t *testing.T, mode engine.RotateMode) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	mhs := random.Multihashes(42)
+	lister := func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	}
+	newEngine := func(ds datastore.Batching) *engine.Engine {
+		id, priv, _ := random.Identity()
+		e, err := engine.New(
+			engine.WithDatastore(ds),
+			engine.WithPrivateKey(priv),
+			engine.WithProvider(peer.AddrInfo{ID: id, Addrs: random.Multiaddrs(1)}),
+		)
+		require.NoError(t, err)
+		require.NoError(t, e.Start(ctx))
+		t.Cleanup(func() { e.Shutdown() })
+		e.RegisterMultihashLister(lister)
+		return e
+	}
+	from := newEngine(ds)
+	to := newEngine(dsn.Wrap(ds, datastore.NewKey("/rotated")))
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		_, err := from.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+	}
+	_, err := from.NotifyRemove(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+	fromHead, _, err := from.GetLatestAdv(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.RotateIdentity(ctx, from, to, mode))
+
+	// The new chain advertises the current context IDs under the new identity.
+	newAds := chainAds(t, ctx, to)
+	require.Len(t, newAds, 2)
+	var gotContextIDs []string
+	for _, ad := range newAds {
+		require.Equal(t, to.ProviderID().String(), ad.Provider)
+		require.False(t, ad.IsRm)
+		gotMd := metadata.Default.New()
+		require.NoError(t, gotMd.UnmarshalBinary(ad.Metadata))
+		require.True(t, md.Equal(gotMd))
+		gotContextIDs = append(gotContextIDs, string(ad.ContextID))
+	}
+	require.ElementsMatch(t, []string{"fish", "lobster"}, gotContextIDs)
+
+	// The old chain hands over to the new identity.
+	oldAds := chainAds(t, ctx, from)
+	switch mode {
+	case engine.RotateRemove:
+		require.Len(t, oldAds, 6)
+		for _, ad := range oldAds[:2] {
+			require.True(t, ad.IsRm)
+			require.Contains(t, []string{"fish", "lobster"}, string(ad.ContextID))
+		}
+	case engine.RotateExtendedProviders:
+		require.Len(t, oldAds, 5)
+		require.NotNil(t, oldAds[0].ExtendedProvider)
+		require.Contains(t, xpIDs(oldAds[0].ExtendedProvider.Providers), to.ProviderID().String())
+	}
+	require.Equal(t, fromHead, oldAds[len(oldAds)-5].PreviousID.(cidlink.Link).Cid)
+
+	// Rotating again is a no-op, as the rotation is complete.
+	fromHead, _, err = from.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	toHead, _, err := to.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.NoError(t, engine.RotateIdentity(ctx, from, to, mode))
+	gotFromHead, _, err := from.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	gotToHead, _, err := to.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, fromHead, gotFromHead)
+	require.Equal(t, toHead, gotToHead)
+}
+
+func TestRotateIdentity_CarriesOverAdsPublishedDuringRotation(t *testing.T) {
+	for _, mode := range []engine.RotateMode{engine.RotateRemove, engine.RotateExtendedProviders} {
+		t.Run(string(mode), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			t.Cleanup(cancel)
+
+			ds := dssync.MutexWrap(datastore.NewMapDatastore())
+			md := metadata.Default.New(metadata.Bitswap{})
+			mhs := random.Multihashes(42)
+			var from, to *engine.Engine
+			var published bool
+			lister := func(_ context.Context, p peer.ID, _ []byte) (provider.MultihashIterator, error) {
+				// The old identity publishes while the new identity advertises
+				// the first rotated context ID.
+				if p == to.ProviderID() && !published {
+					published = true
+					_, err := from.NotifyPut(ctx, nil, []byte("shrimp"), md)
+					require.NoError(t, err)
+				}
+				return provider.SliceMultihashIterator(mhs), nil
+			}
+			from = newRotateEngine(t, ctx, ds, lister)
+			to = newRotateEngine(t, ctx, dsn.Wrap(ds, datastore.NewKey("/rotated")), lister)
+
+			for _, contextID := range []string{"fish", "lobster"} {
+				_, err := from.NotifyPut(ctx, nil, []byte(contextID), md)
+				require.NoError(t, err)
+			}
+			require.NoError(t, engine.RotateIdentity(ctx, from, to, mode))
+			require.True(t, published)
+
+			var gotContextIDs []string
+			for _, ad := range chainAds(t, ctx, to) {
+				gotContextIDs = append(gotContextIDs, string(ad.ContextID))
+			}
+			require.ElementsMatch(t, []string{"fish", "lobster", "shrimp"}, gotContextIDs)
+
+			if mode == engine.RotateRemove {
+				var removed []string
+				for _, ad := range chainAds(t, ctx, from) {
+					if ad.IsRm {
+						removed = append(removed, string(ad.ContextID))
+					}
+				}
+				require.ElementsMatch(t, []string{"fish", "lobster", "shrimp"}, removed)
+			}
+		})
+	}
+}
+
+func TestRotateIdentity_CarriesOverChainLevelExtendedProviders(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	mhs := random.Multihashes(42)
+	lister := func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	}
+	from := newRotateEngine(t, ctx, ds, lister)
+	to := newRotateEngine(t, ctx, dsn.Wrap(ds, datastore.NewKey("/rotated")), lister)
+
+	epID, ep := randomXProvider()
+	_, err := from.NotifyExtendedProviders(ctx, nil, nil, false, ep)
+	require.NoError(t, err)
+	_, err = from.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	require.NoError(t, engine.RotateIdentity(ctx, from, to, engine.RotateExtendedProviders))
+
+	xps, err := to.GetExtendedProviders(ctx, nil)
+	require.NoError(t, err)
+	require.NotNil(t, xps)
+	require.Len(t, xps.Providers, 1)
+	require.Equal(t, epID.String(), xps.Providers[0].ID)
+}
+
+func newRotateEngine(t *testing.T, ctx context.Context, ds datastore.Batching, lister provider.MultihashLister) *engine.Engine {
+	id, priv, _ := random.Identity()
+	e, err := engine.New(
+		engine.WithDatastore(ds),
+		engine.WithPrivateKey(priv),
+		engine.WithProvider(peer.AddrInfo{ID: id, Addrs: random.Multiaddrs(1)}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, e.Start(ctx))
+	t.Cleanup(func() { e.Shutdown() })
+	e.RegisterMultihashLister(lister)
+	return e
+}
+
+// chainAds returns the advertisements of the engine's chain, latest first.
+func chainAds(t *testing.T, ctx context.Context, e *engine.Engine) []*schema.Advertisement {
+	var ads []*schema.Advertisement
+	adCid, ad, err := e.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	for adCid != cid.Undef {
+		ads = append(ads, ad)
+		if ad.PreviousID == nil {
+			break
+		}
+		adCid = ad.PreviousID.(cidlink.Link).Cid
+		ad, err = e.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+	}
+	return ads
+}
@@ -0,0 +1,10 @@
+// Package signer provides the means for the engine to sign advertisements
+// without the provider's private key being held by the engine.
+//
+// A Signer may hold the key in memory, see NewKeySigner, load it from an
+// encrypted keystore file unlocked by a passphrase, see NewKeystoreSigner, or
+// delegate signing to an external process listening on a Unix domain socket,
+// see NewExternalSigner and Serve.
+//
+// See: engine.WithSigner.
+package signer
@@ -0,0 +1,167 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+var log = logging.Logger("provider/signer")
+
+const (
+	opPublicKey = "pubkey"
+	opSign      = "sign"
+
+	// externalTimeout is the time allowed for a single exchange with an
+	// external signer.
+	externalTimeout = 10 * time.Second
+)
+
+// externalReq is a request sent to an external signer. Requests and responses
+// are JSON objects, one per line, exchanged over a Unix domain socket
+// connection. A connection may carry any number of exchanges.
+type externalReq struct {
+	Op   string `json:"op"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// externalRes is the response of an external signer to an externalReq.
+type externalRes struct {
+	PubKey    []byte `json:"pubkey,omitempty"`
+	Signature []byte `json:"sig,omitempty"`
+	Err       string `json:"err,omitempty"`
+}
+
+// externalSigner delegates signing to an external process.
+type externalSigner struct {
+	socketPath string
+	pubKey     crypto.PubKey
+}
+
+// NewExternalSigner returns a Signer that delegates signing to an external
+// process listening on the Unix domain socket at socketPath. The public key of
+// the external signer is fetched once, when this function is called; each
+// signature is then requested over a new connection so that the external
+// process can be restarted independently of the engine.
+//
+// An external signer can be implemented using Serve.
+func NewExternalSigner(ctx context.Context, socketPath string) (Signer, error) {
+	s := &externalSigner{
+		socketPath: socketPath,
+	}
+	res, err := s.call(ctx, &externalReq{Op: opPublicKey})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get public key from external signer: %w", err)
+	}
+	s.pubKey, err = crypto.UnmarshalPublicKey(res.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("bad public key from external signer: %w", err)
+	}
+	return s, nil
+}
+
+func (s *externalSigner) PublicKey() crypto.PubKey {
+	return s.pubKey
+}
+
+func (s *externalSigner) Sign(data []byte) ([]byte, error) {
+	res, err := s.call(context.Background(), &externalReq{Op: opSign, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("external signer failed to sign: %w", err)
+	}
+	// Do not trust the external signer blindly.
+	ok, err := s.pubKey.Verify(data, res.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("external signer returned invalid signature")
+	}
+	return res.Signature, nil
+}
+
+func (s *externalSigner) call(ctx context.Context, req *externalReq) (*externalRes, error) {
+	ctx, cancel := context.WithTimeout(ctx, externalTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", s.socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var res externalRes
+	if err = json.NewDecoder(conn).Decode(&res); err != nil {
+		return nil, err
+	}
+	if res.Err != "" {
+		return nil, errors.New(res.Err)
+	}
+	return &res, nil
+}
+
+// Serve serves signing requests from external signer clients, see
+// NewExternalSigner, on the listener using the given Signer. This allows a
+// separate process, that holds the private key, to sign on behalf of the
+// engine. Serve returns when the listener is closed.
+func Serve(l net.Listener, s Signer) error {
+	pkb, err := crypto.MarshalPublicKey(s.PublicKey())
+	if err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveConn(conn, s, pkb)
+	}
+}
+
+func serveConn(conn net.Conn, s Signer, pkb []byte) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req externalReq
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		var res externalRes
+		switch req.Op {
+		case opPublicKey:
+			res.PubKey = pkb
+		case opSign:
+			sig, err := s.Sign(req.Data)
+			if err != nil {
+				log.Errorw("Failed to sign", "err", err)
+				res.Err = err.Error()
+			} else {
+				res.Signature = sig
+			}
+		default:
+			res.Err = fmt.Sprintf("unknown operation %q", req.Op)
+		}
+		if err := enc.Encode(&res); err != nil {
+			return
+		}
+	}
+}
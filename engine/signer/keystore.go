@@ -0,0 +1,206 @@
+package signer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keystoreVersion = 1
+	keystoreKDF     = "scrypt"
+
+	// Default scrypt cost parameters, as recommended for interactive logins.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	keyLen  = 32
+	saltLen = 32
+)
+
+// ErrBadPassphrase signals that a keystore could not be decrypted with the
+// given passphrase.
+var ErrBadPassphrase = errors.New("cannot decrypt keystore: wrong passphrase or corrupt keystore")
+
+// keystore is the on-disk format of an encrypted private key. The private key,
+// marshaled with crypto.MarshalPrivateKey, is encrypted with AES-256-GCM using
+// a key derived from the passphrase with scrypt.
+type keystore struct {
+	Version    int    `json:"version"`
+	PeerID     string `json:"peerID"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptKey encrypts the private key with the passphrase, and returns the
+// encrypted keystore data.
+func EncryptKey(key crypto.PrivKey, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase must not be empty")
+	}
+	pkb, err := crypto.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	peerID, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := keystore{
+		Version: keystoreVersion,
+		PeerID:  peerID.String(),
+		KDF:     keystoreKDF,
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    make([]byte, saltLen),
+	}
+	if _, err = rand.Read(ks.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := ks.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	ks.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(ks.Nonce); err != nil {
+		return nil, err
+	}
+	ks.Ciphertext = aead.Seal(nil, ks.Nonce, pkb, []byte(ks.PeerID))
+	return json.MarshalIndent(&ks, "", "  ")
+}
+
+// DecryptKey decrypts the keystore data with the passphrase, and returns the
+// private key. ErrBadPassphrase is returned if the passphrase is wrong.
+func DecryptKey(data, passphrase []byte) (crypto.PrivKey, error) {
+	var ks keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("cannot decode keystore: %w", err)
+	}
+	if ks.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", ks.Version)
+	}
+	if ks.KDF != keystoreKDF {
+		return nil, fmt.Errorf("unsupported keystore kdf %q", ks.KDF)
+	}
+	// Never derive the key with costs above the ones written by EncryptKey, so
+	// that a crafted keystore cannot exhaust memory or CPU.
+	if ks.N <= 1 || ks.N > scryptN || ks.R <= 0 || ks.R > scryptR || ks.P <= 0 || ks.P > scryptP {
+		return nil, fmt.Errorf("unsupported keystore scrypt parameters n=%d r=%d p=%d", ks.N, ks.R, ks.P)
+	}
+	if len(ks.Salt) != saltLen {
+		return nil, fmt.Errorf("unsupported keystore salt length %d", len(ks.Salt))
+	}
+	aead, err := ks.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(ks.Nonce) != aead.NonceSize() {
+		return nil, ErrBadPassphrase
+	}
+	pkb, err := aead.Open(nil, ks.Nonce, ks.Ciphertext, []byte(ks.PeerID))
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	key, err := crypto.UnmarshalPrivateKey(pkb)
+	if err != nil {
+		return nil, err
+	}
+	peerID, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if peerID.String() != ks.PeerID {
+		return nil, fmt.Errorf("keystore peer ID %s does not match key %s", ks.PeerID, peerID)
+	}
+	return key, nil
+}
+
+// KeystorePeerID returns the peer ID recorded in the keystore data, without
+// decrypting the key.
+func KeystorePeerID(data []byte) (peer.ID, error) {
+	var ks keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return "", fmt.Errorf("cannot decode keystore: %w", err)
+	}
+	return peer.Decode(ks.PeerID)
+}
+
+func (ks *keystore) aead(passphrase []byte) (cipher.AEAD, error) {
+	dk, err := scrypt.Key(passphrase, ks.Salt, ks.N, ks.R, ks.P, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// WriteKeystore encrypts the private key with the passphrase and writes it to
+// the file at path, readable only by the owner. An existing file is replaced
+// atomically.
+func WriteKeystore(path string, key crypto.PrivKey, passphrase []byte) error {
+	data, err := EncryptKey(key, passphrase)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err = f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// ReadKeystore reads the keystore file at path and decrypts it with the
+// passphrase.
+func ReadKeystore(path string, passphrase []byte) (crypto.PrivKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptKey(data, passphrase)
+}
+
+// NewKeystoreSigner returns a Signer that signs with the private key stored in
+// the encrypted keystore file at path, unlocked with the passphrase.
+func NewKeystoreSigner(path string, passphrase []byte) (Signer, error) {
+	key, err := ReadKeystore(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySigner(key), nil
+}
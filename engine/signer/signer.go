@@ -0,0 +1,89 @@
+package signer
+
+import (
+	"errors"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	pb "github.com/libp2p/go-libp2p/core/crypto/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ErrNotExportable signals that the private key behind a Signer cannot be
+// read.
+var ErrNotExportable = errors.New("private key of signer is not exportable")
+
+// Signer signs advertisements and other records on behalf of a provider
+// identity, without necessarily exposing the private key of that identity.
+type Signer interface {
+	// PublicKey returns the public key of the identity that signs.
+	PublicKey() crypto.PubKey
+	// Sign returns the signature of data.
+	Sign(data []byte) ([]byte, error)
+}
+
+// keySigner signs using a private key held in memory.
+type keySigner struct {
+	key crypto.PrivKey
+}
+
+// NewKeySigner returns a Signer that signs with the given in-memory private
+// key.
+func NewKeySigner(key crypto.PrivKey) Signer {
+	return &keySigner{key: key}
+}
+
+func (s *keySigner) PublicKey() crypto.PubKey {
+	return s.key.GetPublic()
+}
+
+func (s *keySigner) Sign(data []byte) ([]byte, error) {
+	return s.key.Sign(data)
+}
+
+// ID returns the peer ID of the identity that signs.
+func ID(s Signer) (peer.ID, error) {
+	return peer.IDFromPublicKey(s.PublicKey())
+}
+
+// PrivKey adapts the given Signer to crypto.PrivKey, for use with APIs that
+// sign with a private key such as schema.Advertisement.Sign. If the signer
+// holds a key in memory then that key is returned. Otherwise, the returned
+// key delegates signing to the signer and its Raw method returns
+// ErrNotExportable.
+func PrivKey(s Signer) crypto.PrivKey {
+	if ks, ok := s.(*keySigner); ok {
+		return ks.key
+	}
+	return &signerKey{s}
+}
+
+// signerKey implements crypto.PrivKey by delegating to a Signer.
+type signerKey struct {
+	s Signer
+}
+
+var _ crypto.PrivKey = (*signerKey)(nil)
+
+func (k *signerKey) Sign(data []byte) ([]byte, error) {
+	return k.s.Sign(data)
+}
+
+func (k *signerKey) GetPublic() crypto.PubKey {
+	return k.s.PublicKey()
+}
+
+func (k *signerKey) Raw() ([]byte, error) {
+	return nil, ErrNotExportable
+}
+
+func (k *signerKey) Type() pb.KeyType {
+	return k.s.PublicKey().Type()
+}
+
+func (k *signerKey) Equals(other crypto.Key) bool {
+	o, ok := other.(crypto.PrivKey)
+	if !ok {
+		return false
+	}
+	return k.GetPublic().Equals(o.GetPublic())
+}
@@ -0,0 +1,112 @@
+package signer_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipni/index-provider/engine/signer"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystore(t *testing.T) {
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	wantID, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+
+	ksPath := filepath.Join(t.TempDir(), "keystore")
+	passphrase := []byte("fish")
+	require.NoError(t, signer.WriteKeystore(ksPath, key, passphrase))
+
+	fi, err := os.Stat(ksPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	data, err := os.ReadFile(ksPath)
+	require.NoError(t, err)
+	gotID, err := signer.KeystorePeerID(data)
+	require.NoError(t, err)
+	require.Equal(t, wantID, gotID)
+
+	_, err = signer.ReadKeystore(ksPath, []byte("lobster"))
+	require.ErrorIs(t, err, signer.ErrBadPassphrase)
+
+	s, err := signer.NewKeystoreSigner(ksPath, passphrase)
+	require.NoError(t, err)
+	gotID, err = signer.ID(s)
+	require.NoError(t, err)
+	require.Equal(t, wantID, gotID)
+	require.True(t, key.Equals(signer.PrivKey(s)))
+}
+
+func TestKeystoreRejectsCostlyScryptParameters(t *testing.T) {
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	passphrase := []byte("fish")
+	data, err := signer.EncryptKey(key, passphrase)
+	require.NoError(t, err)
+
+	for _, param := range []string{"n", "r", "p"} {
+		var ks map[string]any
+		require.NoError(t, json.Unmarshal(data, &ks))
+		ks[param] = 1 << 30
+		crafted, err := json.Marshal(ks)
+		require.NoError(t, err)
+		_, err = signer.DecryptKey(crafted, passphrase)
+		require.ErrorContains(t, err, "unsupported keystore scrypt parameters", param)
+	}
+
+	decrypted, err := signer.DecryptKey(data, passphrase)
+	require.NoError(t, err)
+	require.True(t, key.Equals(decrypted))
+}
+
+func TestExternalSigner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	// Use a short path, since Unix socket paths are limited in length.
+	sockDir, err := os.MkdirTemp("", "signer")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(sockDir) })
+	sockPath := filepath.Join(sockDir, "signer.sock")
+
+	l, err := net.Listen("unix", sockPath)
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- signer.Serve(l, signer.NewKeySigner(key)) }()
+
+	s, err := signer.NewExternalSigner(ctx, sockPath)
+	require.NoError(t, err)
+	require.True(t, key.GetPublic().Equals(s.PublicKey()))
+
+	data := []byte("fish")
+	sig, err := s.Sign(data)
+	require.NoError(t, err)
+	ok, err := key.GetPublic().Verify(data, sig)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// The adapted key signs via the external signer but cannot be exported.
+	priv := signer.PrivKey(s)
+	require.True(t, priv.Equals(key))
+	_, err = priv.Raw()
+	require.ErrorIs(t, err, signer.ErrNotExportable)
+	_, err = crypto.MarshalPrivateKey(priv)
+	require.Error(t, err)
+
+	require.NoError(t, l.Close())
+	require.NoError(t, <-served)
+
+	_, err = s.Sign(data)
+	require.Error(t, err)
+}
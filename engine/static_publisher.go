@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipni/go-libipni/dagsync"
+	"github.com/ipni/go-libipni/dagsync/ipnisync"
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// staticHeadFile is the name of the file holding the signed head, in the same
+// directory as the advertisement and entries blocks.
+const staticHeadFile = "head"
+
+var _ dagsync.Publisher = (*staticPublisher)(nil)
+
+// staticPublisher writes the advertisement chain into a local directory in the
+// layout served by the ipnisync HTTP publisher: the signed head at
+// ipni/v1/ad/head, and each advertisement and entries block at
+// ipni/v1/ad/<cid>. The directory can then be copied to any static file host,
+// from which indexers sync over plain HTTP.
+//
+// Blocks are written as stored by the engine, and are never rewritten since
+// their file names are their CIDs. The head is written last, so that it only
+// ever points to a complete chain.
+type staticPublisher struct {
+	dir    string
+	lsys   ipld.LinkSystem
+	key    crypto.PrivKey
+	peerID peer.ID
+	topic  string
+	addrs  []multiaddr.Multiaddr
+}
+
+func newStaticPublisher(dir string, lsys ipld.LinkSystem, key crypto.PrivKey, topic string, addrs []multiaddr.Multiaddr) (*staticPublisher, error) {
+	if dir == "" {
+		return nil, errors.New("static publisher directory must be set")
+	}
+	peerID, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not get peer id from private key: %w", err)
+	}
+	adDir := filepath.Join(dir, filepath.FromSlash(ipnisync.IPNIPath))
+	if err = os.MkdirAll(adDir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create static publisher directory: %w", err)
+	}
+	return &staticPublisher{
+		dir:    adDir,
+		lsys:   lsys,
+		key:    key,
+		peerID: peerID,
+		topic:  topic,
+		addrs:  addrs,
+	}, nil
+}
+
+// Addrs returns the addresses of the static file host, as configured by
+// WithHttpPublisherAnnounceAddr, since the publisher itself does not listen.
+func (p *staticPublisher) Addrs() []multiaddr.Multiaddr {
+	return p.addrs
+}
+
+func (p *staticPublisher) ID() peer.ID {
+	return p.peerID
+}
+
+func (p *staticPublisher) Protocol() int {
+	return multiaddr.P_HTTP
+}
+
+// SetRoot writes the advertisements up to c that are not yet in the directory,
+// along with their entries, then writes c as the signed head. Failures are
+// logged, and the head is left unchanged so that the chain is written again
+// the next time the root is set.
+func (p *staticPublisher) SetRoot(c cid.Cid) {
+	if err := p.write(context.Background(), c); err != nil {
+		log.Errorw("Failed to write advertisement chain to static publisher directory", "dir", p.dir, "head", c, "err", err)
+	}
+}
+
+func (p *staticPublisher) Close() error {
+	return nil
+}
+
+func (p *staticPublisher) write(ctx context.Context, head cid.Cid) error {
+	// Find the ads not yet written, newest first. Since ads are written oldest
+	// first, all ads before one that is written are written too.
+	var ads []*schema.Advertisement
+	var adCids []cid.Cid
+	for adCid := head; adCid != cid.Undef; {
+		written, err := p.exists(adCid)
+		if err != nil {
+			return err
+		}
+		if written {
+			break
+		}
+		data, err := p.load(ctx, adCid)
+		if err != nil {
+			return fmt.Errorf("cannot load advertisement %s: %w", adCid, err)
+		}
+		ad, err := decodeAd(adCid, data)
+		if err != nil {
+			return err
+		}
+		ads = append(ads, ad)
+		adCids = append(adCids, adCid)
+		if ad.PreviousID == nil {
+			break
+		}
+		adCid = ad.PreviousID.(cidlink.Link).Cid
+	}
+
+	for i := len(ads) - 1; i >= 0; i-- {
+		if ads[i].Entries != nil && ads[i].Entries != schema.NoEntries {
+			if err := p.writeEntries(ctx, ads[i].Entries); err != nil {
+				return fmt.Errorf("cannot write entries of advertisement %s: %w", adCids[i], err)
+			}
+		}
+		if err := p.writeBlock(ctx, adCids[i]); err != nil {
+			return err
+		}
+	}
+
+	signedHead, err := headschema.NewSignedHead(head, p.topic, p.key)
+	if err != nil {
+		return err
+	}
+	headData, err := signedHead.Encode()
+	if err != nil {
+		return err
+	}
+	return p.writeFile(staticHeadFile, headData)
+}
+
+// writeEntries writes the blocks of the entries DAG rooted at lnk that are not
+// yet in the directory. A block is written after the blocks it links to, so
+// that a block in the directory is always the root of a complete DAG. Blocks
+// that no longer exist are skipped.
+func (p *staticPublisher) writeEntries(ctx context.Context, lnk ipld.Link) error {
+	c := lnk.(cidlink.Link).Cid
+	// Entries DAGs may share blocks, and be shared by advertisements.
+	written, err := p.exists(c)
+	if err != nil || written {
+		return err
+	}
+	data, err := p.load(ctx, c)
+	if err != nil {
+		// The entries of removed contexts can no longer be loaded. Skip them,
+		// as the HTTP publisher does by responding with not found, so that
+		// they do not block writing the rest of the chain.
+		if errors.Is(err, ipld.ErrNotExists{}) || errors.Is(err, datastore.ErrNotFound) {
+			log.Warnw("Entries block not found; skipping", "cid", c, "err", err)
+			return nil
+		}
+		return fmt.Errorf("cannot load entries block %s: %w", c, err)
+	}
+	decoder, err := p.lsys.DecoderChooser(lnk)
+	if err != nil {
+		return err
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err = decoder(nb, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	for _, next := range appendLinks(nil, nb.Build()) {
+		if err = p.writeEntries(ctx, next); err != nil {
+			return err
+		}
+	}
+	return p.writeFile(c.String(), data)
+}
+
+func (p *staticPublisher) writeBlock(ctx context.Context, c cid.Cid) error {
+	data, err := p.load(ctx, c)
+	if err != nil {
+		return err
+	}
+	return p.writeFile(c.String(), data)
+}
+
+func (p *staticPublisher) load(ctx context.Context, c cid.Cid) ([]byte, error) {
+	// Writing the directory does not serve anything to indexers yet.
+	r, err := p.lsys.StorageReadOpener(ipld.LinkContext{Ctx: withInternalRead(ctx)}, cidlink.Link{Cid: c})
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func (p *staticPublisher) exists(c cid.Cid) (bool, error) {
+	_, err := os.Stat(filepath.Join(p.dir, c.String()))
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// writeFile writes a file atomically, so that a static host syncing the
+// directory never sees a partial file.
+func (p *staticPublisher) writeFile(name string, data []byte) error {
+	f, err := os.CreateTemp(p.dir, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filepath.Join(p.dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// VerifyReport is the result of Engine.Verify.
+type VerifyReport struct {
+	// Head is the CID of the latest advertisement.
+	Head cid.Cid
+	// Ads is the number of advertisements in the chain.
+	Ads int
+	// ContextIDs is the number of context IDs currently advertised.
+	ContextIDs int
+	// Problems lists the inconsistencies that were found.
+	Problems []VerifyProblem
+}
+
+// VerifyProblem describes an inconsistency found by Engine.Verify.
+type VerifyProblem struct {
+	// Ad is the CID of the advertisement that the problem relates to, if any.
+	Ad cid.Cid
+	// Key is the datastore key that the problem relates to, if any.
+	Key string
+	// Description describes the problem.
+	Description string
+	// Repaired is true if the problem was repaired.
+	Repaired bool
+}
+
+func (p VerifyProblem) String() string {
+	s := p.Description
+	if p.Ad != cid.Undef {
+		s += " (ad " + p.Ad.String() + ")"
+	}
+	if p.Key != "" {
+		s += " (key " + p.Key + ")"
+	}
+	if p.Repaired {
+		s += ": repaired"
+	}
+	return s
+}
+
+// Repaired returns the number of problems that were repaired.
+func (r *VerifyReport) Repaired() int {
+	var n int
+	for _, p := range r.Problems {
+		if p.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// verifyContext is the state of a context ID according to the latest
+// advertisement for it in the chain.
+type verifyContext struct {
+	adCid     cid.Cid
+	provider  peer.ID
+	contextID []byte
+	entries   cid.Cid
+	metadata  []byte
+}
+
+// Verify checks the consistency of the engine datastore with the
+// advertisement chain. The chain is walked from its head, checking that every
+// advertisement can be loaded and that its signature is valid and made by the
+// engine's key. The latest advertisement of each provider and context ID then
+// determines the expected content of the context ID to entries, entries to
+// context ID, and context ID to metadata mappings. Mappings that are missing
+// or disagree with the chain are reported, as are mappings for context IDs
+// that are not currently advertised.
+//
+// If repair is true, then the mappings are rewritten to match the chain and
+// orphaned mappings are deleted. Problems with the chain itself, such as
+// broken links or bad signatures, cannot be repaired.
+//
+// Verify does not require the engine to be started, and blocks changes to the
+// chain while it runs.
+func (e *Engine) Verify(ctx context.Context, repair bool) (*VerifyReport, error) {
+	e.chainLock.Lock()
+	defer e.chainLock.Unlock()
+	return e.verify(ctx, repair)
+}
+
+// verify implements Verify, and must be called with chainLock held.
+func (e *Engine) verify(ctx context.Context, repair bool) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	keyID, err := peer.IDFromPrivateKey(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Head, err = e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+
+	// Walk the chain and record the latest state of each context ID.
+	latest := make(map[string]*verifyContext)
+	var order []string
+	lsys := e.vanillaLinkSystem()
+	for adCid := report.Head; adCid != cid.Undef; {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: adCid}, schema.AdvertisementPrototype)
+		if err != nil {
+			report.Problems = append(report.Problems, VerifyProblem{
+				Ad:          adCid,
+				Description: fmt.Sprintf("cannot load advertisement, chain is broken: %s", err),
+			})
+			break
+		}
+		ad, err := schema.UnwrapAdvertisement(n)
+		if err != nil {
+			report.Problems = append(report.Problems, VerifyProblem{
+				Ad:          adCid,
+				Description: fmt.Sprintf("cannot decode advertisement, chain is broken: %s", err),
+			})
+			break
+		}
+		report.Ads++
+
+		signerID, err := ad.VerifySignature()
+		if err != nil {
+			report.Problems = append(report.Problems, VerifyProblem{
+				Ad:          adCid,
+				Description: fmt.Sprintf("invalid signature: %s", err),
+			})
+		} else if signerID != keyID {
+			report.Problems = append(report.Problems, VerifyProblem{
+				Ad:          adCid,
+				Description: fmt.Sprintf("signed by %s instead of engine key %s", signerID, keyID),
+			})
+		}
+
+		// Extended provider advertisements do not change the mappings.
+		if ad.ExtendedProvider == nil {
+			p, err := peer.Decode(ad.Provider)
+			if err != nil {
+				report.Problems = append(report.Problems, VerifyProblem{
+					Ad:          adCid,
+					Description: fmt.Sprintf("invalid provider id: %s", err),
+				})
+			} else {
+				k := e.keyToCidKey(p, ad.ContextID).String()
+				if _, ok := latest[k]; !ok {
+					var vc *verifyContext
+					if !ad.IsRm {
+						vc = &verifyContext{
+							adCid:     adCid,
+							provider:  p,
+							contextID: ad.ContextID,
+							entries:   ad.Entries.(cidlink.Link).Cid,
+							metadata:  ad.Metadata,
+						}
+					}
+					latest[k] = vc
+					order = append(order, k)
+				}
+			}
+		}
+
+		if ad.PreviousID == nil {
+			break
+		}
+		adCid = ad.PreviousID.(cidlink.Link).Cid
+	}
+
+	// Check that the mappings of each advertised context ID agree with the
+	// chain.
+	wantKeys := make(map[string]struct{})
+	entriesOwners := make(map[cid.Cid][]*verifyContext)
+	for _, k := range order {
+		vc := latest[k]
+		if vc == nil {
+			continue
+		}
+		report.ContextIDs++
+		entriesOwners[vc.entries] = append(entriesOwners[vc.entries], vc)
+
+		cidKey := e.keyToCidKey(vc.provider, vc.contextID)
+		wantKeys[cidKey.String()] = struct{}{}
+		b, err := e.ds.Get(ctx, cidKey)
+		switch {
+		case errors.Is(err, datastore.ErrNotFound):
+			e.verifyProblem(ctx, report, repair, vc.adCid, cidKey, "missing context id to entries mapping", vc.entries.Bytes())
+		case err != nil:
+			return nil, err
+		case !bytes.Equal(b, vc.entries.Bytes()):
+			e.verifyProblem(ctx, report, repair, vc.adCid, cidKey, "context id to entries mapping does not match advertisement", vc.entries.Bytes())
+		}
+
+		mdKey := e.keyToMetadataKey(vc.provider, vc.contextID)
+		wantKeys[mdKey.String()] = struct{}{}
+		b, err = e.ds.Get(ctx, mdKey)
+		switch {
+		case errors.Is(err, datastore.ErrNotFound):
+			e.verifyProblem(ctx, report, repair, vc.adCid, mdKey, "missing context id to metadata mapping", vc.metadata)
+		case err != nil:
+			return nil, err
+		case !bytes.Equal(b, vc.metadata):
+			e.verifyProblem(ctx, report, repair, vc.adCid, mdKey, "context id to metadata mapping does not match advertisement", vc.metadata)
+		}
+	}
+
+	// Check that each entries CID maps back to a context ID that advertises
+	// it. The same entries may be advertised by more than one context ID, in
+	// which case any of them is valid.
+	for entries, owners := range entriesOwners {
+		legacyKey := e.cidToKeyKey(entries)
+		provKey := e.cidToProviderAndKeyKey(entries)
+		wantKeys[legacyKey.String()] = struct{}{}
+		wantKeys[provKey.String()] = struct{}{}
+		// Context IDs without entries all share the same entries CID, so
+		// the mapping is not meaningful.
+		if entries == schema.NoEntries.Cid {
+			continue
+		}
+
+		pc, err := e.getCidKeyMap(ctx, entries)
+		if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+			return nil, err
+		}
+		if pc != nil && verifyOwns(owners, pc) {
+			continue
+		}
+		desc := "missing entries to context id mapping"
+		if pc != nil {
+			desc = "entries to context id mapping does not match advertisement"
+		}
+		vc := owners[0]
+		m, err := json.Marshal(&providerAndContext{Provider: []byte(vc.provider), ContextID: vc.contextID})
+		if err != nil {
+			return nil, err
+		}
+		e.verifyProblem(ctx, report, repair, vc.adCid, provKey, desc, m)
+		if repair {
+			// The legacy mapping takes precedence, so remove it.
+			if err = e.ds.Delete(ctx, legacyKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Find mappings for context IDs that are not advertised.
+	for _, prefix := range []string{keyToCidMapPrefix, keyToMetadataMapPrefix, cidToProviderAndKeyMapPrefix, cidToKeyMapPrefix} {
+		if err = e.verifyOrphans(ctx, report, repair, prefix, wantKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	if repair {
+		if err = e.ds.Sync(ctx, datastore.NewKey("map")); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// verifyOwns returns true if the provider and context ID is one of owners.
+func verifyOwns(owners []*verifyContext, pc *providerAndContext) bool {
+	for _, vc := range owners {
+		if peer.ID(pc.Provider) == vc.provider && bytes.Equal(pc.ContextID, vc.contextID) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyProblem records a problem with the mapping at key, and if repair is
+// true sets the mapping to value.
+func (e *Engine) verifyProblem(ctx context.Context, report *VerifyReport, repair bool, adCid cid.Cid, key datastore.Key, desc string, value []byte) {
+	problem := VerifyProblem{
+		Ad:          adCid,
+		Key:         key.String(),
+		Description: desc,
+	}
+	if repair {
+		if err := e.ds.Put(ctx, key, value); err != nil {
+			log.Errorw("Failed to repair mapping", "key", key, "err", err)
+		} else {
+			problem.Repaired = true
+		}
+	}
+	report.Problems = append(report.Problems, problem)
+}
+
+// verifyOrphans reports, and if repair is true deletes, the mappings under
+// prefix that are not in wantKeys.
+func (e *Engine) verifyOrphans(ctx context.Context, report *VerifyReport, repair bool, prefix string, wantKeys map[string]struct{}) error {
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix:   datastore.NewKey(prefix).String() + "/",
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	var orphans []string
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return r.Error
+		}
+		if _, ok := wantKeys[r.Key]; !ok {
+			orphans = append(orphans, r.Key)
+		}
+	}
+	results.Close()
+
+	for _, k := range orphans {
+		problem := VerifyProblem{
+			Key:         k,
+			Description: "orphaned mapping for context id that is not advertised",
+		}
+		if repair {
+			if err = e.ds.Delete(ctx, datastore.RawKey(k)); err != nil {
+				log.Errorw("Failed to delete orphaned mapping", "key", k, "err", err)
+			} else {
+				problem.Repaired = true
+			}
+		}
+		report.Problems = append(report.Problems, problem)
+	}
+	return nil
+}
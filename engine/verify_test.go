@@ -0,0 +1,70 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Verify(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(random.Multihashes(10)), nil
+	})
+	md := metadata.Default.New(metadata.Bitswap{})
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		_, err = subject.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+	}
+	_, err = subject.NotifyRemove(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+
+	report, err := subject.Verify(ctx, false)
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+	require.Equal(t, 5, report.Ads)
+	require.Equal(t, 2, report.ContextIDs)
+
+	// Corrupt the mappings.
+	require.NoError(t, ds.Delete(ctx, datastore.NewKey("map/keyCid/fish")))
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("map/keyMD/lobster"), []byte("bad")))
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("map/keyMD/crab"), []byte("orphan")))
+
+	report, err = subject.Verify(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 3)
+	require.Zero(t, report.Repaired())
+
+	report, err = subject.Verify(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 3)
+	require.Equal(t, 3, report.Repaired())
+
+	report, err = subject.Verify(ctx, false)
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+
+	// The repaired mappings are usable again.
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.IpfsGatewayHttp{}))
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+}
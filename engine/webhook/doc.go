@@ -0,0 +1,10 @@
+// Package webhook notifies HTTP endpoints of the advertisements published by
+// an engine.
+//
+// A Notifier subscribes to the events of an engine, see engine.Subscribe, and
+// for each published advertisement POSTs a JSON Payload to each configured
+// URL. Payloads are signed with HMAC-SHA256 using a shared secret, carried in
+// the SignatureHeader header, so that receivers can check that a notification
+// came from the provider. Failed deliveries are retried, and once all
+// attempts fail the notification is appended to a dead-letter log.
+package webhook
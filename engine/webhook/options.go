@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMaxAttempts   = 5
+	defaultRetryInterval = time.Second
+	defaultBufferSize    = 1024
+	defaultTimeout       = 10 * time.Second
+)
+
+type (
+	// Option sets a configuration parameter for the Notifier.
+	Option func(*options) error
+
+	options struct {
+		secret         []byte
+		maxAttempts    int
+		retryInterval  time.Duration
+		bufferSize     int
+		deadLetterPath string
+		client         *http.Client
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		maxAttempts:   defaultMaxAttempts,
+		retryInterval: defaultRetryInterval,
+		bufferSize:    defaultBufferSize,
+		client:        &http.Client{Timeout: defaultTimeout},
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithSecret sets the secret used to sign payloads with HMAC-SHA256. If not
+// set, payloads are not signed.
+func WithSecret(secret []byte) Option {
+	return func(o *options) error {
+		o.secret = secret
+		return nil
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts made to deliver a
+// notification to each URL. Defaults to 5 if not specified.
+func WithMaxAttempts(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return fmt.Errorf("max attempts must be at least 1, got %d", n)
+		}
+		o.maxAttempts = n
+		return nil
+	}
+}
+
+// WithRetryInterval sets the time to wait before the first retry of a failed
+// delivery. The wait doubles after each further failure. Defaults to 1s if not
+// specified.
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("retry interval must be positive")
+		}
+		o.retryInterval = d
+		return nil
+	}
+}
+
+// WithBufferSize sets the number of notifications that are queued for each
+// URL while earlier notifications are being delivered. Notifications that do
+// not fit in the queue are written to the dead-letter log. Defaults to 1024 if
+// not specified.
+func WithBufferSize(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return fmt.Errorf("buffer size must be at least 1, got %d", n)
+		}
+		o.bufferSize = n
+		return nil
+	}
+}
+
+// WithDeadLetterFile sets the path of the file that notifications are appended
+// to, as JSON lines, when they cannot be delivered. If not set, undeliverable
+// notifications are only logged.
+func WithDeadLetterFile(path string) Option {
+	return func(o *options) error {
+		o.deadLetterPath = path
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to deliver notifications. Defaults
+// to a client with a 10s timeout.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("nil http client")
+		}
+		o.client = c
+		return nil
+	}
+}
@@ -0,0 +1,271 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+)
+
+// SignatureHeader is the HTTP header that carries the signature of the
+// payload, as "sha256=" followed by the hex encoded HMAC-SHA256 of the request
+// body.
+const SignatureHeader = "X-Index-Provider-Signature"
+
+var log = logging.Logger("provider/webhook")
+
+// Payload is the JSON body of a notification.
+type Payload struct {
+	// AdCid is the CID of the published advertisement.
+	AdCid string `json:"adCid"`
+	// Provider is the provider ID of the advertisement.
+	Provider string `json:"provider"`
+	// ContextID is the context ID of the advertisement.
+	ContextID []byte `json:"contextID"`
+	// IsRm is true if the advertisement removes the context ID.
+	IsRm bool `json:"isRm"`
+	// Protocols are the names of the retrieval protocols in the advertisement
+	// metadata.
+	Protocols []string `json:"protocols,omitempty"`
+	// Timestamp is the time at which the advertisement was published.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// deadLetter is a notification that could not be delivered, as written to the
+// dead-letter log.
+type deadLetter struct {
+	URL     string          `json:"url"`
+	Error   string          `json:"error"`
+	Time    time.Time       `json:"time"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Notifier delivers notifications of the advertisements published by an
+// engine to a set of webhook URLs.
+type Notifier struct {
+	*options
+	sub     *engine.Subscription
+	targets []*target
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	deadLock   sync.Mutex
+	deadLetter *os.File
+}
+
+// target delivers notifications, in order, to a single URL.
+type target struct {
+	url   string
+	queue chan []byte
+}
+
+// New creates a Notifier that notifies each of the given URLs of every
+// advertisement published by the engine from now on. Notifications are
+// delivered to each URL in the order the advertisements were published.
+//
+// The Notifier must be closed with Close when no longer needed.
+func New(e *engine.Engine, urls []string, o ...Option) (*Notifier, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Notifier{
+		options: opts,
+	}
+	if opts.deadLetterPath != "" {
+		n.deadLetter, err = os.OpenFile(opts.deadLetterPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open dead-letter file: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	for _, u := range urls {
+		t := &target{
+			url:   u,
+			queue: make(chan []byte, opts.bufferSize),
+		}
+		n.targets = append(n.targets, t)
+		n.wg.Add(1)
+		go n.deliver(ctx, t)
+	}
+
+	n.sub = e.Subscribe(opts.bufferSize, engine.DropNewest)
+	n.wg.Add(1)
+	go n.run()
+	return n, nil
+}
+
+// Close stops the Notifier. Notifications that are still queued or being
+// retried are written to the dead-letter log.
+func (n *Notifier) Close() error {
+	n.sub.Close()
+	n.cancel()
+	n.wg.Wait()
+	if n.deadLetter != nil {
+		return n.deadLetter.Close()
+	}
+	return nil
+}
+
+// run reads engine events and queues a notification for each published
+// advertisement.
+func (n *Notifier) run() {
+	defer func() {
+		for _, t := range n.targets {
+			close(t.queue)
+		}
+		n.wg.Done()
+	}()
+
+	var dropped uint64
+	for event := range n.sub.Events() {
+		if d := n.sub.Dropped(); d != dropped {
+			log.Errorw("Engine events dropped; some advertisements were not notified", "count", d-dropped)
+			dropped = d
+		}
+		published, ok := event.(engine.AdPublished)
+		if !ok {
+			continue
+		}
+		body, err := json.Marshal(newPayload(published, time.Now()))
+		if err != nil {
+			log.Errorw("Cannot encode webhook payload", "adCid", published.Cid, "err", err)
+			continue
+		}
+		for _, t := range n.targets {
+			select {
+			case t.queue <- body:
+			default:
+				n.writeDeadLetter(t.url, body, "notification queue is full")
+			}
+		}
+	}
+}
+
+func newPayload(published engine.AdPublished, now time.Time) *Payload {
+	ad := published.Ad
+	p := &Payload{
+		AdCid:     published.Cid.String(),
+		Provider:  ad.Provider,
+		ContextID: ad.ContextID,
+		IsRm:      ad.IsRm,
+		Timestamp: now.UTC(),
+	}
+	if len(ad.Metadata) != 0 {
+		md := metadata.Default.New()
+		if err := md.UnmarshalBinary(ad.Metadata); err != nil {
+			log.Warnw("Cannot decode advertisement metadata", "adCid", published.Cid, "err", err)
+		} else {
+			for _, code := range md.Protocols() {
+				p.Protocols = append(p.Protocols, code.String())
+			}
+		}
+	}
+	return p
+}
+
+// deliver sends the queued notifications to the target URL.
+func (n *Notifier) deliver(ctx context.Context, t *target) {
+	defer n.wg.Done()
+	for body := range t.queue {
+		if err := n.send(ctx, t.url, body); err != nil {
+			log.Errorw("Failed to deliver webhook notification", "url", t.url, "err", err)
+			n.writeDeadLetter(t.url, body, err.Error())
+		}
+	}
+}
+
+// send posts the body to the URL, retrying with exponential backoff.
+func (n *Notifier) send(ctx context.Context, url string, body []byte) error {
+	wait := n.retryInterval
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = n.post(ctx, url, body); err == nil {
+			return nil
+		}
+		if attempt == n.maxAttempts {
+			return fmt.Errorf("failed after %d attempts: %w", attempt, err)
+		}
+		log.Debugw("Retrying webhook notification", "url", url, "attempt", attempt, "err", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("notifier closed before delivery: %w", err)
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) != 0 {
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+func (n *Notifier) writeDeadLetter(url string, body []byte, reason string) {
+	if n.deadLetter == nil {
+		log.Errorw("Dropped webhook notification", "url", url, "reason", reason, "payload", string(body))
+		return
+	}
+	line, err := json.Marshal(&deadLetter{
+		URL:     url,
+		Error:   reason,
+		Time:    time.Now().UTC(),
+		Payload: body,
+	})
+	if err != nil {
+		log.Errorw("Cannot encode dead letter", "err", err)
+		return
+	}
+	n.deadLock.Lock()
+	defer n.deadLock.Unlock()
+	if _, err = n.deadLetter.Write(append(line, '\n')); err != nil {
+		log.Errorw("Cannot write dead letter", "url", url, "err", err)
+	}
+}
+
+// Sign returns the value of SignatureHeader for the body signed with secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks that signature, the value of SignatureHeader, is the signature
+// of body made with secret.
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, body)))
+}
@@ -0,0 +1,126 @@
+package webhook_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/engine/webhook"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	eng, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+	eng.RegisterMultihashLister(func(_ context.Context, _ peer.ID, _ []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(random.Multihashes(5)), nil
+	})
+
+	secret := []byte("shared secret")
+	received := make(chan webhook.Payload, 10)
+	var failOnce sync.Once
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if !webhook.Verify(secret, body, r.Header.Get(webhook.SignatureHeader)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		// Fail the first delivery to check that it is retried.
+		failed := false
+		failOnce.Do(func() { failed = true })
+		if failed {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		var p webhook.Payload
+		require.NoError(t, json.Unmarshal(body, &p))
+		received <- p
+	}))
+	defer receiver.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	deadLetterPath := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	n, err := webhook.New(eng, []string{receiver.URL, broken.URL},
+		webhook.WithSecret(secret),
+		webhook.WithMaxAttempts(3),
+		webhook.WithRetryInterval(10*time.Millisecond),
+		webhook.WithDeadLetterFile(deadLetterPath))
+	require.NoError(t, err)
+
+	putCid, err := eng.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	rmCid, err := eng.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+
+	var p webhook.Payload
+	select {
+	case p = <-received:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for notification")
+	}
+	require.Equal(t, putCid.String(), p.AdCid)
+	ad, err := eng.GetAdv(ctx, putCid)
+	require.NoError(t, err)
+	require.Equal(t, ad.Provider, p.Provider)
+	require.Equal(t, "fish", string(p.ContextID))
+	require.False(t, p.IsRm)
+	require.Equal(t, []string{"transport-bitswap"}, p.Protocols)
+	require.WithinDuration(t, time.Now(), p.Timestamp, time.Minute)
+
+	select {
+	case p = <-received:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for notification")
+	}
+	require.Equal(t, rmCid.String(), p.AdCid)
+	require.True(t, p.IsRm)
+	require.Empty(t, p.Protocols)
+
+	// Both notifications to the broken receiver end up in the dead-letter log,
+	// once all attempts have failed.
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(deadLetterPath)
+		return err == nil && bytes.Count(data, []byte("\n")) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, n.Close())
+
+	f, err := os.Open(deadLetterPath)
+	require.NoError(t, err)
+	defer f.Close()
+	var adCids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var dl struct {
+			URL     string
+			Payload webhook.Payload
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &dl))
+		require.Equal(t, broken.URL, dl.URL)
+		adCids = append(adCids, dl.Payload.AdCid)
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, []string{putCid.String(), rmCid.String()}, adCids)
+}
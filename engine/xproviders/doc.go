@@ -1,5 +1,8 @@
 // Package xproviders provides convinience classes for building and signing advertisements with ExtendedProviders, that implement extended
 // providers specification. Built and signed advertisements can be published using Engine.
 //
+// To have the engine link the advertisement to the head of its chain and keep track of the advertised set of extended providers,
+// use Engine.NotifyExtendedProviders, Engine.AddExtendedProviders and Engine.RemoveExtendedProviders instead.
+//
 // See: https://github.com/ipni/storetheindex/blob/main/doc/ingest.md#extendedprovider
 package xproviders
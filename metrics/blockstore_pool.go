@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"go.opentelemetry.io/otel/metric"
+)
+
+var BlockstorePool struct {
+	Open    metric.Int64UpDownCounter
+	Acquire metric.Int64Counter
+	Evicted metric.Int64Counter
+}
+
+func init() {
+	var err error
+	if BlockstorePool.Open, err = meter.Int64UpDownCounter(
+		"index-provider/blockstore_pool/open",
+		metric.WithDescription("The number of CAR blockstores open for retrieval"),
+	); err != nil {
+		panic(err)
+	}
+	if BlockstorePool.Acquire, err = meter.Int64Counter(
+		"index-provider/blockstore_pool/acquire",
+		metric.WithDescription("The number of CAR blockstores acquired for retrieval, by result: hit if already open, miss if opened, or full if rejected because the pool is at capacity"),
+	); err != nil {
+		panic(err)
+	}
+	if BlockstorePool.Evicted, err = meter.Int64Counter(
+		"index-provider/blockstore_pool/evicted",
+		metric.WithDescription("The number of idle CAR blockstores closed to make room for others"),
+	); err != nil {
+		panic(err)
+	}
+}
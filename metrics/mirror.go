@@ -7,6 +7,11 @@ import (
 var Mirror struct {
 	SyncDuration    metric.Int64Histogram
 	ProcessDuration metric.Int64Histogram
+	FailedAds       metric.Int64Counter
+	FilteredAds     metric.Int64Counter
+	LagAds          metric.Int64Gauge
+	LagSeconds      metric.Int64Gauge
+	Forks           metric.Int64Counter
 }
 
 func init() {
@@ -25,4 +30,39 @@ func init() {
 	); err != nil {
 		panic(err)
 	}
+	if Mirror.FailedAds, err = meter.Int64Counter(
+		"index-provider/mirror/failed_ads",
+		metric.WithUnit("1"),
+		metric.WithDescription("The number of ads that failed to be mirrored after any retries, by error code and failure policy"),
+	); err != nil {
+		panic(err)
+	}
+	if Mirror.FilteredAds, err = meter.Int64Counter(
+		"index-provider/mirror/filtered_ads",
+		metric.WithUnit("1"),
+		metric.WithDescription("The number of ads excluded from mirroring by the filter, by reason"),
+	); err != nil {
+		panic(err)
+	}
+	if Mirror.LagAds, err = meter.Int64Gauge(
+		"index-provider/mirror/lag_ads",
+		metric.WithUnit("1"),
+		metric.WithDescription("The number of ads synced from the source that are yet to be mirrored"),
+	); err != nil {
+		panic(err)
+	}
+	if Mirror.LagSeconds, err = meter.Int64Gauge(
+		"index-provider/mirror/lag_seconds",
+		metric.WithUnit("s"),
+		metric.WithDescription("The time in seconds the mirror has been behind the source"),
+	); err != nil {
+		panic(err)
+	}
+	if Mirror.Forks, err = meter.Int64Counter(
+		"index-provider/mirror/forks",
+		metric.WithUnit("1"),
+		metric.WithDescription("The number of forks of the source chain detected, by fork policy"),
+	); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,85 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/ipni/go-libipni/announce/message"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// triggerSync requests an immediate sync with the source, unless one is
+// already pending.
+func (m *Mirror) triggerSync() {
+	select {
+	case m.syncNow <- struct{}{}:
+	default:
+	}
+}
+
+// watchAnnounces triggers a sync whenever the source announces a new
+// advertisement over gossipsub, until ctx is canceled or the receiver closed.
+func (m *Mirror) watchAnnounces(ctx context.Context) {
+	for {
+		amsg, err := m.announceRcvr.Next(ctx)
+		if err != nil {
+			return
+		}
+		if amsg.PeerID == m.source.ID {
+			log.Infow("Received announcement from source", "cid", amsg.Cid)
+			m.triggerSync()
+		}
+	}
+}
+
+// handleAnnounce receives announcements sent over HTTP, and triggers a sync
+// if an announcement is from the source.
+func (m *Mirror) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	msg, publishers, err := decodeAnnounce(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, publisher := range publishers {
+		if publisher == m.source.ID {
+			log.Infow("Received HTTP announcement from source", "cid", msg.Cid)
+			m.triggerSync()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	http.Error(w, "announcement is not from the mirrored source", http.StatusForbidden)
+}
+
+// decodeAnnounce decodes an announcement sent over HTTP, and returns it along
+// with the IDs of the publishers in its addresses.
+func decodeAnnounce(r *http.Request) (message.Message, []peer.ID, error) {
+	var msg message.Message
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		err = json.NewDecoder(r.Body).Decode(&msg)
+	} else {
+		err = msg.UnmarshalCBOR(r.Body)
+	}
+	if err != nil {
+		return msg, nil, fmt.Errorf("cannot decode announce message: %w", err)
+	}
+
+	addrs, err := msg.GetAddrs()
+	if err != nil {
+		return msg, nil, fmt.Errorf("invalid announce addresses: %w", err)
+	}
+	infos, err := peer.AddrInfosFromP2pAddrs(addrs...)
+	if err != nil {
+		return msg, nil, fmt.Errorf("announce addresses must include the publisher peer ID: %w", err)
+	}
+	publishers := make([]peer.ID, 0, len(infos))
+	for _, info := range infos {
+		publishers = append(publishers, info.ID)
+	}
+	return msg, publishers, nil
+}
@@ -18,7 +18,34 @@
 // original PreviousID link, even though the content corresponding to that link will not be hosted
 // by the mirror.
 //
+// An advertisement that fails to be mirrored for a transient reason is retried as configured. It
+// is then either skipped and recorded, or mirroring halts at it and resumes from it at the next
+// sync. See FailurePolicy.
+//
+// A Mirror syncs with the source at every sync interval. It can also sync as soon as the source
+// announces a new advertisement, either over gossipsub or to an HTTP endpoint exposed by the
+// mirror, in which case the sync interval serves as a fallback for missed announcements. See
+// WithSyncOnAnnounce and WithAnnounceHTTPListenAddr.
+//
+// A Filter can restrict the mirrored advertisements to part of the original chain, e.g. by
+// metadata protocol or context ID prefix. Excluded advertisements are left out of the mirrored
+// chain without syncing their entries. See WithFilter.
+//
+// The addresses and metadata of mirrored advertisements can be rewritten by declarative rules, for
+// example to direct retrievals to edge endpoints. See WithRewrite.
+//
+// If the source resets or rewrites its chain, so that its head no longer links back to the
+// advertisements already mirrored, the mirror records the fork and either mirrors the new chain
+// from scratch or halts, depending on the fork policy. See WithForkPolicy.
+//
+// The progress of a mirror, such as its backlog of advertisements and their last errors, is
+// reported by Mirror.Status, which can be served over HTTP with Mirror.StatusHandler. The lag of the
+// mirror behind its source is also exposed as metrics.
+//
+// A Manager runs the mirrors of multiple sources in one process, with a shared datastore and HTTP
+// listener. Sources can be added and removed while the manager runs.
+//
 // Note that mirroring advertisements is one-to-one: for each original advertisement there will be
-// a mirrored one. This is not affected by optional remapping of entries. Future work will provide
+// a mirrored one, except for any advertisements skipped due to failures or excluded by a filter. This is not affected by optional remapping of entries. Future work will provide
 // the ability to also remap advertisements in addition to entries.
 package mirror
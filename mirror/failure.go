@@ -0,0 +1,133 @@
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// FailurePolicy determines what the mirror does when an advertisement fails to
+// be mirrored, after any retries.
+//
+// See: WithFailurePolicy, WithFailureRetries.
+type FailurePolicy int
+
+const (
+	// FailureSkip skips the advertisement that failed to be mirrored, and
+	// carries on with the next one. Skipped advertisements are not part of the
+	// mirrored chain, and are recorded in the datastore.
+	//
+	// See: Mirror.SkippedAds.
+	FailureSkip FailurePolicy = iota
+	// FailureHalt stops mirroring at the advertisement that failed to be
+	// mirrored, and retries mirroring from it at the next sync.
+	FailureHalt
+)
+
+func (p FailurePolicy) String() string {
+	switch p {
+	case FailureSkip:
+		return "skip"
+	case FailureHalt:
+		return "halt"
+	default:
+		return fmt.Sprintf("FailurePolicy(%d)", int(p))
+	}
+}
+
+// ParseFailurePolicy parses the string representation of a FailurePolicy.
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	switch s {
+	case "skip":
+		return FailureSkip, nil
+	case "halt":
+		return FailureHalt, nil
+	default:
+		return 0, fmt.Errorf("unknown failure policy %q: must be skip or halt", s)
+	}
+}
+
+// ErrorCode categorizes the cause of a failure to mirror an advertisement.
+type ErrorCode string
+
+const (
+	// ErrorCodeLoadAd means the original advertisement could not be loaded.
+	ErrorCodeLoadAd ErrorCode = "load_ad"
+	// ErrorCodeInvalidAd means the original or mirrored advertisement is
+	// invalid.
+	ErrorCodeInvalidAd ErrorCode = "invalid_ad"
+	// ErrorCodeSignature means the signature of the original advertisement
+	// could not be verified, or the mirrored advertisement could not be
+	// signed.
+	ErrorCodeSignature ErrorCode = "signature"
+	// ErrorCodeSyncEntries means the entries could not be synced from the
+	// source.
+	ErrorCodeSyncEntries ErrorCode = "sync_entries"
+	// ErrorCodeRemapEntries means the entries could not be remapped.
+	ErrorCodeRemapEntries ErrorCode = "remap_entries"
+	// ErrorCodeRewrite means the addresses or metadata of the advertisement
+	// could not be rewritten.
+	ErrorCodeRewrite ErrorCode = "rewrite"
+	// ErrorCodeStore means the mirror could not read or write its datastore.
+	ErrorCodeStore ErrorCode = "store"
+	// ErrorCodeUnknown is the code of any other failure.
+	ErrorCodeUnknown ErrorCode = "unknown"
+)
+
+// Transient reports whether a failure with the code may succeed when retried.
+// Failures caused by the content of the advertisement itself, such as an
+// invalid advertisement or signature, or one that cannot be rewritten, are
+// permanent and are not retried.
+//
+// See: WithFailureRetries.
+func (c ErrorCode) Transient() bool {
+	switch c {
+	case ErrorCodeInvalidAd, ErrorCodeSignature, ErrorCodeRewrite:
+		return false
+	default:
+		return true
+	}
+}
+
+// mirrorError is an error to mirror an advertisement, with its code.
+type mirrorError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *mirrorError) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.err)
+}
+
+func (e *mirrorError) Unwrap() error {
+	return e.err
+}
+
+func newMirrorError(code ErrorCode, err error) error {
+	return &mirrorError{code: code, err: err}
+}
+
+// errorCode returns the code of an error returned by mirroring an
+// advertisement.
+func errorCode(err error) ErrorCode {
+	var merr *mirrorError
+	if errors.As(err, &merr) {
+		return merr.code
+	}
+	return ErrorCodeUnknown
+}
+
+// SkippedAd describes an advertisement skipped by the mirror because it
+// failed to be mirrored.
+type SkippedAd struct {
+	// AdCid is the CID of the original advertisement.
+	AdCid cid.Cid
+	// Code categorizes the cause of the failure.
+	Code ErrorCode
+	// Error is the message of the failure.
+	Error string
+	// Time is when the advertisement was skipped.
+	Time time.Time
+}
@@ -0,0 +1,27 @@
+package mirror_test
+
+import (
+	"testing"
+
+	"github.com/ipni/index-provider/mirror"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode_Transient(t *testing.T) {
+	for _, code := range []mirror.ErrorCode{
+		mirror.ErrorCodeLoadAd,
+		mirror.ErrorCodeSyncEntries,
+		mirror.ErrorCodeRemapEntries,
+		mirror.ErrorCodeStore,
+		mirror.ErrorCodeUnknown,
+	} {
+		require.True(t, code.Transient(), code)
+	}
+	for _, code := range []mirror.ErrorCode{
+		mirror.ErrorCodeInvalidAd,
+		mirror.ErrorCodeSignature,
+		mirror.ErrorCodeRewrite,
+	} {
+		require.False(t, code.Transient(), code)
+	}
+}
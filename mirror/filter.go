@@ -0,0 +1,99 @@
+package mirror
+
+import (
+	"bytes"
+
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multicodec"
+)
+
+// Filter selects the advertisements of the source chain that are mirrored.
+// An advertisement is mirrored only if it passes every rule that is set.
+//
+// Advertisements excluded by the filter are not part of the mirrored chain:
+// the next mirrored advertisement links to the last one mirrored before them,
+// and their entries are neither synced nor remapped. An excluded advertisement
+// that updates a context ID already advertised by the mirrored chain is
+// mirrored as a removal of that context ID instead, so that the mirrored chain
+// does not keep advertising content the source has changed.
+//
+// See: WithFilter.
+type Filter struct {
+	// Protocols, if set, only passes advertisements whose metadata includes at
+	// least one of the given transport protocols, e.g.
+	// multicodec.TransportBitswap. Advertisements without metadata, such as
+	// removals, pass.
+	Protocols []multicodec.Code
+	// ContextIDPrefixes, if set, only passes advertisements whose context ID
+	// starts with one of the given prefixes. Advertisements without context
+	// ID pass.
+	ContextIDPrefixes [][]byte
+	// ExcludedExtendedProviders excludes advertisements that list any of the
+	// given peers as an extended provider.
+	ExcludedExtendedProviders []peer.ID
+}
+
+// FilterReason is the rule of a Filter that excluded an advertisement.
+type FilterReason string
+
+const (
+	// FilterReasonProtocol means the advertisement metadata has none of the
+	// filtered protocols.
+	FilterReasonProtocol FilterReason = "protocol"
+	// FilterReasonContextID means the advertisement context ID has none of
+	// the filtered prefixes.
+	FilterReasonContextID FilterReason = "context_id"
+	// FilterReasonExtendedProvider means the advertisement lists an excluded
+	// extended provider.
+	FilterReasonExtendedProvider FilterReason = "extended_provider"
+)
+
+// Excludes returns the reason the filter excludes the advertisement, or false
+// if the advertisement passes the filter.
+func (f *Filter) Excludes(ad *schema.Advertisement) (FilterReason, bool) {
+	if len(f.Protocols) != 0 && len(ad.Metadata) != 0 && !f.hasProtocol(ad.Metadata) {
+		return FilterReasonProtocol, true
+	}
+	if len(f.ContextIDPrefixes) != 0 && len(ad.ContextID) != 0 && !f.hasContextIDPrefix(ad.ContextID) {
+		return FilterReasonContextID, true
+	}
+	if len(f.ExcludedExtendedProviders) != 0 && ad.ExtendedProvider != nil {
+		for _, p := range ad.ExtendedProvider.Providers {
+			for _, excluded := range f.ExcludedExtendedProviders {
+				if p.ID == excluded.String() {
+					return FilterReasonExtendedProvider, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func (f *Filter) hasProtocol(mdBytes []byte) bool {
+	md := metadata.Default.New()
+	if err := md.UnmarshalBinary(mdBytes); err != nil {
+		// Metadata that cannot be decoded cannot be shown to have any of the
+		// protocols.
+		log.Debugw("Cannot decode ad metadata to filter by protocol", "err", err)
+		return false
+	}
+	for _, have := range md.Protocols() {
+		for _, want := range f.Protocols {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *Filter) hasContextIDPrefix(contextID []byte) bool {
+	for _, prefix := range f.ContextIDPrefixes {
+		if bytes.HasPrefix(contextID, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package mirror_test
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/mirror"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Excludes(t *testing.T) {
+	bitswapMd := metadata.Default.New(metadata.Bitswap{})
+	bitswap, err := bitswapMd.MarshalBinary()
+	require.NoError(t, err)
+	httpMd := metadata.Default.New(metadata.IpfsGatewayHttp{})
+	http, err := httpMd.MarshalBinary()
+	require.NoError(t, err)
+	peers := random.Peers(2)
+
+	subject := mirror.Filter{
+		Protocols:                 []multicodec.Code{multicodec.TransportBitswap},
+		ContextIDPrefixes:         [][]byte{[]byte("fish/"), []byte("lobster/")},
+		ExcludedExtendedProviders: []peer.ID{peers[0]},
+	}
+	tests := []struct {
+		name       string
+		ad         schema.Advertisement
+		wantReason mirror.FilterReason
+	}{
+		{
+			name: "passes",
+			ad:   schema.Advertisement{ContextID: []byte("fish/1"), Metadata: bitswap},
+		},
+		{
+			name:       "other protocol",
+			ad:         schema.Advertisement{ContextID: []byte("fish/1"), Metadata: http},
+			wantReason: mirror.FilterReasonProtocol,
+		},
+		{
+			name:       "undecodable metadata",
+			ad:         schema.Advertisement{ContextID: []byte("fish/1"), Metadata: []byte{0xff}},
+			wantReason: mirror.FilterReasonProtocol,
+		},
+		{
+			name: "removal without metadata",
+			ad:   schema.Advertisement{ContextID: []byte("lobster/1"), IsRm: true},
+		},
+		{
+			name:       "other context id",
+			ad:         schema.Advertisement{ContextID: []byte("crab/1"), Metadata: bitswap},
+			wantReason: mirror.FilterReasonContextID,
+		},
+		{
+			name: "allowed extended provider",
+			ad: schema.Advertisement{ContextID: []byte("fish/1"), Metadata: bitswap,
+				ExtendedProvider: &schema.ExtendedProvider{Providers: []schema.Provider{{ID: peers[1].String()}}}},
+		},
+		{
+			name: "excluded extended provider",
+			ad: schema.Advertisement{ContextID: []byte("fish/1"), Metadata: bitswap,
+				ExtendedProvider: &schema.ExtendedProvider{Providers: []schema.Provider{{ID: peers[1].String()}, {ID: peers[0].String()}}}},
+			wantReason: mirror.FilterReasonExtendedProvider,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, excluded := subject.Excludes(&tt.ad)
+			require.Equal(t, tt.wantReason != "", excluded)
+			require.Equal(t, tt.wantReason, reason)
+		})
+	}
+
+	// The zero value passes everything.
+	_, excluded := (&mirror.Filter{}).Excludes(&schema.Advertisement{ContextID: []byte("crab/1"), Metadata: http})
+	require.False(t, excluded)
+}
@@ -0,0 +1,200 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/index-provider/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ForkPolicy determines what the mirror does when the source chain forks,
+// i.e. when the head of the source no longer links back to the latest
+// original advertisement processed by the mirror. This happens when the
+// source resets or rewrites its chain.
+//
+// See: WithForkPolicy, WithForkDetectionDepth.
+type ForkPolicy int
+
+const (
+	// ForkRemirror forgets the mirrored chain, and mirrors the new source
+	// chain from scratch, as if the mirror had never synced with the source,
+	// subject to the initial recursion limit. The first advertisement mirrored
+	// from the new chain does not link to the previously mirrored ones.
+	//
+	// See: WithInitialAdRecursionLimit.
+	ForkRemirror ForkPolicy = iota
+	// ForkHalt stops mirroring, and keeps serving the previously mirrored
+	// chain. At every sync, the mirror only syncs the ads published on top of
+	// the forked chain, and resumes if the source head links back to the
+	// mirrored chain again.
+	ForkHalt
+)
+
+func (p ForkPolicy) String() string {
+	switch p {
+	case ForkRemirror:
+		return "remirror"
+	case ForkHalt:
+		return "halt"
+	default:
+		return fmt.Sprintf("ForkPolicy(%d)", int(p))
+	}
+}
+
+// ParseForkPolicy parses the string representation of a ForkPolicy.
+func ParseForkPolicy(s string) (ForkPolicy, error) {
+	switch s {
+	case "remirror":
+		return ForkRemirror, nil
+	case "halt":
+		return ForkHalt, nil
+	default:
+		return 0, fmt.Errorf("unknown fork policy %q: must be remirror or halt", s)
+	}
+}
+
+// ForkEvent describes a fork of the source chain detected by the mirror.
+type ForkEvent struct {
+	// SourceHead is the head of the source chain that does not link back to
+	// LatestOriginalAd.
+	SourceHead cid.Cid
+	// LatestOriginalAd is the CID of the latest original advertisement
+	// processed by the mirror when the fork was detected.
+	LatestOriginalAd cid.Cid
+	// LatestMirroredAd is the CID of the head of the mirrored chain when the
+	// fork was detected.
+	LatestMirroredAd cid.Cid
+	// Policy is the policy applied to the fork.
+	Policy string
+	// Time is when the fork was detected.
+	Time time.Time
+}
+
+// haltedChain is the forked source chain at which mirroring halted.
+type haltedChain struct {
+	fork *ForkEvent
+	// head is the latest head of the source chain known to extend the fork.
+	head cid.Cid
+	// backlog is the number of ads synced on the forked chain.
+	backlog int
+}
+
+// syncSince syncs the ads published by the source since the given ad, in
+// batches bounded by the initial recursion limit, and returns the head of the
+// source and the CIDs of the ads synced, oldest first.
+//
+// It reports a fork if the source chain reaches its first ad without linking
+// to the given ad, or if more ads than the fork detection depth are synced
+// without linking to it.
+//
+// See: WithForkDetectionDepth.
+func (m *Mirror) syncSince(ctx context.Context, since cid.Cid) (cid.Cid, []cid.Cid, bool, error) {
+	var head, from cid.Cid
+	var synced []cid.Cid
+	for {
+		limit := m.initAdRecurLimit
+		if m.forkDetectionDepth > 0 {
+			if left := m.forkDetectionDepth - int64(len(synced)); limit <= 0 || left < limit {
+				limit = left
+			}
+		}
+		batchHead, batch, err := m.syncAds(ctx, from, since, limit)
+		if err != nil {
+			return cid.Undef, nil, false, err
+		}
+		if cid.Undef.Equals(head) {
+			head = batchHead
+		}
+		if len(batch) == 0 {
+			return head, synced, false, nil
+		}
+		synced = append(batch, synced...)
+
+		oldest, err := m.loadAd(ctx, batch[0])
+		if err != nil {
+			return cid.Undef, nil, false, err
+		}
+		if oldest.PreviousID == nil {
+			return head, synced, true, nil
+		}
+		from = oldest.PreviousID.(cidlink.Link).Cid
+		if from == since {
+			return head, synced, false, nil
+		}
+		if limit <= 0 {
+			// An unbounded walk that stops short of the given ad cannot
+			// find it.
+			return head, synced, true, nil
+		}
+		if m.forkDetectionDepth > 0 && int64(len(synced)) >= m.forkDetectionDepth {
+			return head, synced, true, nil
+		}
+	}
+}
+
+// syncHaltedFork syncs the ads published by the source on top of the forked
+// chain at which mirroring halted, so that a halted mirror does not walk the
+// forked chain again at every sync. It returns false if the source chain no
+// longer extends the forked chain, in which case it must be checked against
+// the latest original ad again.
+func (m *Mirror) syncHaltedFork(ctx context.Context, syncStart time.Time) (bool, error) {
+	head, synced, forked, err := m.syncSince(ctx, m.halted.head)
+	if err != nil {
+		return false, err
+	}
+	if forked {
+		m.halted = nil
+		return false, nil
+	}
+	if len(synced) != 0 {
+		m.halted.head = head
+		m.halted.backlog += len(synced)
+	}
+	m.setSynced(ctx, head, syncStart, m.halted.backlog, m.halted.fork)
+	return true, nil
+}
+
+// handleFork records a fork of the source chain to the given head, and applies
+// the fork policy to it. It returns the fork, and the ads synced from the
+// source that are left to mirror.
+func (m *Mirror) handleFork(ctx context.Context, head, latestOriginal cid.Cid, synced []cid.Cid) (*ForkEvent, []cid.Cid, error) {
+	latestMirrored, err := m.getLatestMirroredAdCid(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	fork := &ForkEvent{
+		SourceHead:       head,
+		LatestOriginalAd: latestOriginal,
+		LatestMirroredAd: latestMirrored,
+		Policy:           m.forkPolicy.String(),
+		Time:             time.Now(),
+	}
+	// Only alert once per fork, since a halted mirror detects the same fork at
+	// every sync, even as the new source chain grows.
+	recorded, err := m.addFork(ctx, *fork)
+	if err != nil {
+		return nil, nil, err
+	}
+	if recorded {
+		log.Errorw("Source chain forked", "sourceHead", head, "latestOriginalAd", latestOriginal, "policy", m.forkPolicy)
+		metrics.Mirror.Forks.Add(ctx, 1, metric.WithAttributes(
+			m.sourceAttr(),
+			attribute.String("policy", m.forkPolicy.String())))
+	}
+
+	if m.forkPolicy == ForkHalt {
+		return fork, nil, nil
+	}
+	if err = m.resetMirroredChain(ctx); err != nil {
+		return nil, nil, err
+	}
+	if m.initAdRecurLimit > 0 && int64(len(synced)) > m.initAdRecurLimit {
+		synced = synced[int64(len(synced))-m.initAdRecurLimit:]
+	}
+	return fork, synced, nil
+}
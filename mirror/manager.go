@@ -0,0 +1,304 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var (
+	// ErrSourceExists signals that a source is already mirrored by the manager.
+	ErrSourceExists = errors.New("source is already mirrored")
+	// ErrSourceNotFound signals that a source is not mirrored by the manager.
+	ErrSourceNotFound = errors.New("source is not mirrored")
+)
+
+// sourcesKeyPrefix is the prefix of the datastore namespace of each source.
+var sourcesKeyPrefix = datastore.NewKey("sources")
+
+// Manager runs the mirrors of multiple source providers in one process.
+//
+// The mirrors share one datastore, in which the data of each mirror is
+// namespaced by the peer ID of its source. They also share one HTTP listener,
+// on which the advertisements mirrored from each source are served under the
+// path of the source peer ID, e.g. /<source-peer-id>/ipni/v1/ad/head, and on
+// which announcements from any source are received at the path /announce.
+//
+// Sources can be added and removed while the manager is running. Removing a
+// source stops mirroring it but keeps its data, so that mirroring resumes
+// where it left off if the source is added again.
+type Manager struct {
+	*managerOptions
+	ds datastore.Batching
+
+	lock    sync.RWMutex
+	mirrors map[peer.ID]*Mirror
+	// adding holds the sources being added, whose mirrors are built without
+	// holding the lock.
+	adding  map[peer.ID]struct{}
+	started bool
+	closed  bool
+
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewManager instantiates a new Manager that stores the data of all mirrors in
+// the given datastore.
+//
+// See: Manager.Start, Manager.Add, Manager.Shutdown.
+func NewManager(ds datastore.Batching, o ...ManagerOption) (*Manager, error) {
+	opts, err := newManagerOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		managerOptions: opts,
+		ds:             ds,
+		mirrors:        make(map[peer.ID]*Mirror),
+		adding:         make(map[peer.ID]struct{}),
+	}
+	m.listener, err = net.Listen("tcp", m.httpListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen for http: %w", err)
+	}
+	m.server = &http.Server{
+		Handler:     m,
+		ReadTimeout: 30 * time.Second,
+	}
+	return m, nil
+}
+
+// Start starts serving HTTP and mirroring the sources added so far. If a
+// mirror fails to start, then the mirrors already started are shut down and
+// removed, and the error is returned.
+func (m *Manager) Start() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.started {
+		return errors.New("manager already started")
+	}
+	if m.closed {
+		return errors.New("manager is shut down")
+	}
+	started := make([]peer.ID, 0, len(m.mirrors))
+	for id, mirror := range m.mirrors {
+		if err := mirror.Start(); err != nil {
+			for _, startedID := range started {
+				if sErr := m.mirrors[startedID].Shutdown(); sErr != nil {
+					log.Errorw("Failed to shut down mirror", "source", startedID, "err", sErr)
+				}
+				delete(m.mirrors, startedID)
+			}
+			return fmt.Errorf("cannot start mirror of %s: %w", id, err)
+		}
+		started = append(started, id)
+	}
+	m.started = true
+
+	go func() {
+		log.Infow("Serving mirrors over http", "addr", m.listener.Addr())
+		if err := m.server.Serve(m.listener); err != http.ErrServerClosed {
+			log.Errorw("Failed to serve mirrors over http", "err", err)
+		}
+	}()
+	return nil
+}
+
+// Add starts mirroring the given source, with the options of the manager
+// followed by the given options. Returns ErrSourceExists if the source is
+// already mirrored, or being added.
+//
+// The mirror is built and started without blocking the other operations of
+// the manager.
+//
+// See: WithMirrorOptions.
+func (m *Manager) Add(ctx context.Context, source peer.AddrInfo, o ...Option) error {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return errors.New("manager is shut down")
+	}
+	if _, ok := m.mirrors[source.ID]; ok {
+		m.lock.Unlock()
+		return ErrSourceExists
+	}
+	if _, ok := m.adding[source.ID]; ok {
+		m.lock.Unlock()
+		return ErrSourceExists
+	}
+	m.adding[source.ID] = struct{}{}
+	m.lock.Unlock()
+
+	mirror, started, err := m.newMirror(ctx, source, o)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.adding, source.ID)
+	if err != nil {
+		return err
+	}
+	if m.closed {
+		_ = mirror.Shutdown()
+		return errors.New("manager is shut down")
+	}
+	if _, ok := m.mirrors[source.ID]; ok {
+		_ = mirror.Shutdown()
+		return ErrSourceExists
+	}
+	if m.started && !started {
+		// The manager was started while the mirror was being built.
+		if err = mirror.Start(); err != nil {
+			_ = mirror.Shutdown()
+			return err
+		}
+	}
+	m.mirrors[source.ID] = mirror
+	log.Infow("Added mirror source", "source", source.ID)
+	return nil
+}
+
+// newMirror builds the mirror of a source being added, and starts it if the
+// manager is started. Returns whether the mirror was started. Must be called
+// without holding the lock.
+func (m *Manager) newMirror(ctx context.Context, source peer.AddrInfo, o []Option) (*Mirror, bool, error) {
+	opts := make([]Option, 0, len(m.mirrorOpts)+len(o)+4)
+	opts = append(opts, m.mirrorOpts...)
+	opts = append(opts, o...)
+	opts = append(opts,
+		WithDatastore(namespace.Wrap(m.ds, sourcesKeyPrefix.ChildString(source.ID.String()))),
+		WithHTTPListenAddr(m.listener.Addr().String()),
+		WithHTTPPublisherWithoutServer(),
+		WithHTTPPublisherHandlerPath(source.ID.String()))
+	mirror, err := New(ctx, source, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	m.lock.RLock()
+	started := m.started
+	m.lock.RUnlock()
+	if started {
+		if err = mirror.Start(); err != nil {
+			_ = mirror.Shutdown()
+			return nil, false, err
+		}
+	}
+	return mirror, started, nil
+}
+
+// Remove stops mirroring the source with the given peer ID. The data mirrored
+// from the source is kept. Returns ErrSourceNotFound if the source is not
+// mirrored.
+func (m *Manager) Remove(sourceID peer.ID) error {
+	m.lock.Lock()
+	mirror, ok := m.mirrors[sourceID]
+	delete(m.mirrors, sourceID)
+	m.lock.Unlock()
+	if !ok {
+		return ErrSourceNotFound
+	}
+	log.Infow("Removed mirror source", "source", sourceID)
+	return mirror.Shutdown()
+}
+
+// Sources returns the sources mirrored by the manager, ordered by peer ID.
+func (m *Manager) Sources() []peer.AddrInfo {
+	m.lock.RLock()
+	sources := make([]peer.AddrInfo, 0, len(m.mirrors))
+	for _, mirror := range m.mirrors {
+		sources = append(sources, mirror.Source())
+	}
+	m.lock.RUnlock()
+	sort.Slice(sources, func(i, j int) bool { return sources[i].ID < sources[j].ID })
+	return sources
+}
+
+// Mirror returns the mirror of the source with the given peer ID, or false if
+// the source is not mirrored.
+func (m *Manager) Mirror(sourceID peer.ID) (*Mirror, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	mirror, ok := m.mirrors[sourceID]
+	return mirror, ok
+}
+
+// Addr returns the address on which the manager serves HTTP.
+func (m *Manager) Addr() net.Addr {
+	return m.listener.Addr()
+}
+
+// Shutdown stops serving HTTP and shuts down all mirrors. Sources can no
+// longer be added once shut down.
+func (m *Manager) Shutdown() error {
+	errs := m.server.Close()
+	m.lock.Lock()
+	m.closed = true
+	mirrors := m.mirrors
+	m.mirrors = make(map[peer.ID]*Mirror)
+	m.lock.Unlock()
+	for _, mirror := range mirrors {
+		errs = errors.Join(errs, mirror.Shutdown())
+	}
+	return errs
+}
+
+// ServeHTTP routes requests for advertisements to the mirror of the source
+// identified by the first path segment, and announcements to the mirror of
+// the announcing source.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.TrimPrefix(r.URL.Path, "/")
+	if urlPath == "announce" {
+		if r.Method != http.MethodPut && r.Method != http.MethodPost {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		m.handleAnnounce(w, r)
+		return
+	}
+
+	first, _, _ := strings.Cut(urlPath, "/")
+	sourceID, err := peer.Decode(first)
+	if err != nil {
+		http.Error(w, "invalid source peer id in path", http.StatusBadRequest)
+		return
+	}
+	mirror, ok := m.Mirror(sourceID)
+	if !ok {
+		http.Error(w, ErrSourceNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	mirror.pub.ServeHTTP(w, r)
+}
+
+// handleAnnounce triggers a sync of the mirrors of the sources that sent an
+// announcement over HTTP.
+func (m *Manager) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	msg, publishers, err := decodeAnnounce(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var found bool
+	for _, publisher := range publishers {
+		if mirror, ok := m.Mirror(publisher); ok {
+			log.Infow("Received HTTP announcement from source", "source", publisher, "cid", msg.Cid)
+			mirror.triggerSync()
+			found = true
+		}
+	}
+	if !found {
+		http.Error(w, "announcement is not from a mirrored source", http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
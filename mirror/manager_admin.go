@@ -0,0 +1,113 @@
+package mirror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RemoveSourceRequest is the request to stop mirroring a source, sent to the
+// admin API of a Manager.
+type RemoveSourceRequest struct {
+	ID peer.ID
+}
+
+// AdminHandler returns the http.Handler of the admin API of the manager, which
+// lists, adds and removes sources:
+//
+//   - GET /admin/sources responds with the JSON list of sources, as
+//     peer.AddrInfo.
+//   - POST /admin/sources/add starts mirroring the source given as a JSON
+//     peer.AddrInfo, with the options of the manager.
+//   - POST /admin/sources/remove stops mirroring the source given as a JSON
+//     RemoveSourceRequest.
+//   - GET /admin/sources/{id}/status responds with the JSON Status of the
+//     mirror of the source with the given peer ID.
+//
+// Changes made over the admin API are not persisted.
+func (m *Manager) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/sources", m.handleListSources)
+	mux.HandleFunc("POST /admin/sources/add", m.handleAddSource)
+	mux.HandleFunc("POST /admin/sources/remove", m.handleRemoveSource)
+	mux.HandleFunc("GET /admin/sources/{id}/status", m.handleSourceStatus)
+	return mux
+}
+
+func (m *Manager) handleListSources(w http.ResponseWriter, _ *http.Request) {
+	writeJson(w, http.StatusOK, m.Sources())
+}
+
+func (m *Manager) handleAddSource(w http.ResponseWriter, r *http.Request) {
+	var source peer.AddrInfo
+	if err := json.NewDecoder(r.Body).Decode(&source); err != nil {
+		http.Error(w, "cannot decode source: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := source.ID.Validate(); err != nil {
+		http.Error(w, "invalid source peer id: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(source.Addrs) == 0 {
+		http.Error(w, "source must have at least one address", http.StatusBadRequest)
+		return
+	}
+	if err := m.Add(r.Context(), source); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrSourceExists) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJson(w, http.StatusOK, source)
+}
+
+func (m *Manager) handleRemoveSource(w http.ResponseWriter, r *http.Request) {
+	var req RemoveSourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "cannot decode request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := m.Remove(req.ID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrSourceNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *Manager) handleSourceStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := peer.Decode(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid source peer id: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	mirror, ok := m.Mirror(id)
+	if !ok {
+		http.Error(w, ErrSourceNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	status, err := mirror.Status(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, http.StatusOK, status)
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
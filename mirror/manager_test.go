@@ -0,0 +1,213 @@
+package mirror_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/go-libipni/announce/httpsender"
+	"github.com/ipni/go-libipni/announce/message"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/mirror"
+	"github.com/libp2p/go-libp2p"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+// addManagedSource starts a source and adds it to the manager, with a mirror
+// host known to the test environment. The mirror is used by the test
+// environment once the manager is started.
+func (te *testEnv) addManagedSource(t *testing.T, ctx context.Context, mgr *mirror.Manager) {
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher), engine.WithPubsubAnnounce(false))
+	privKey, _, err := p2pcrypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	te.mirrorHost, err = libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"), libp2p.Identity(privKey))
+	require.NoError(t, err)
+	t.Cleanup(func() { te.mirrorHost.Close() })
+
+	source := te.sourceAddrInfo(t)
+	require.NoError(t, mgr.Add(ctx, source, mirror.WithHost(te.mirrorHost, privKey)))
+}
+
+func (te *testEnv) useManagedMirror(t *testing.T, mgr *mirror.Manager) {
+	m, ok := mgr.Mirror(te.sourceHost.ID())
+	require.True(t, ok)
+	te.useMirror(t, m)
+}
+
+func (te *testEnv) requireMirroredHead(t *testing.T, ctx context.Context, wantOriginalAdCid cid.Cid) {
+	var gotMirroredHeadCid cid.Cid
+	var err error
+	require.Eventually(t, func() bool {
+		gotMirroredHeadCid, err = te.mirrorSyncer.GetHead(ctx)
+		return err == nil && !cid.Undef.Equals(gotMirroredHeadCid)
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	te.requireAdChainMirroredRecursively(t, ctx, wantOriginalAdCid, gotMirroredHeadCid)
+}
+
+func TestManager_MirrorsMultipleSources(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	mgr, err := mirror.NewManager(ds, mirror.WithMirrorOptions(mirror.WithSyncInterval(time.Hour)))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, mgr.Shutdown()) })
+
+	te1, te2 := &testEnv{}, &testEnv{}
+	te1.addManagedSource(t, ctx, mgr)
+	require.NoError(t, mgr.Start())
+	// Sources added after start are mirrored too.
+	te2.addManagedSource(t, ctx, mgr)
+	te1.useManagedMirror(t, mgr)
+	te2.useManagedMirror(t, mgr)
+	require.ElementsMatch(t, []peer.AddrInfo{te1.sourceAddrInfo(t), te2.sourceAddrInfo(t)}, mgr.Sources())
+
+	announceURL, err := url.Parse("http://" + mgr.Addr().String() + "/announce")
+	require.NoError(t, err)
+	for _, te := range []*testEnv{te1, te2} {
+		// Each mirror is served under the path of its source.
+		require.Contains(t, te.mirror.PublisherAddrs()[0].String(), te.sourceHost.ID().String())
+
+		adCid := te.putAdOnSource(t, ctx, []byte("fish"), random.Multihashes(3), md)
+		source := te.sourceAddrInfo(t)
+		msg := message.Message{Cid: adCid}
+		msg.SetAddrs(source.Addrs)
+		sender, err := httpsender.New([]*url.URL{announceURL}, source.ID)
+		require.NoError(t, err)
+		require.NoError(t, sender.Send(ctx, msg))
+
+		te.requireMirroredHead(t, ctx, adCid)
+	}
+
+	// Removed sources are no longer served.
+	require.NoError(t, mgr.Remove(te1.sourceHost.ID()))
+	require.ErrorIs(t, mgr.Remove(te1.sourceHost.ID()), mirror.ErrSourceNotFound)
+	require.Equal(t, []peer.AddrInfo{te2.sourceAddrInfo(t)}, mgr.Sources())
+	resp, err := http.Get("http://" + mgr.Addr().String() + "/" + te1.sourceHost.ID().String() + "/ipni/v1/ad/head")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestManager_ResumesRemovedSource(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	mgr, err := mirror.NewManager(ds, mirror.WithMirrorOptions(mirror.WithSyncInterval(testCheckInterval)))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, mgr.Shutdown()) })
+	require.NoError(t, mgr.Start())
+
+	te := &testEnv{}
+	te.addManagedSource(t, ctx, mgr)
+	te.useManagedMirror(t, mgr)
+	adCid := te.putAdOnSource(t, ctx, []byte("fish"), random.Multihashes(3), md)
+	te.requireMirroredHead(t, ctx, adCid)
+	wantHead, err := te.mirrorSyncer.GetHead(ctx)
+	require.NoError(t, err)
+
+	source := te.sourceAddrInfo(t)
+	require.NoError(t, mgr.Remove(source.ID))
+	require.NoError(t, mgr.Add(ctx, source, mirror.WithHost(te.mirrorHost, te.mirrorHost.Peerstore().PrivKey(te.mirrorHost.ID()))))
+	require.ErrorIs(t, mgr.Add(ctx, source), mirror.ErrSourceExists)
+
+	// The mirrored chain is served from the shared datastore once re-added,
+	// at the same address.
+	gotHead, err := te.mirrorSyncer.GetHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, wantHead, gotHead)
+}
+
+func TestManager_AdminHandler(t *testing.T) {
+	ctx := newTestContext(t)
+	mgr, err := mirror.NewManager(dssync.MutexWrap(datastore.NewMapDatastore()),
+		mirror.WithMirrorOptions(mirror.WithSyncInterval(time.Hour)))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, mgr.Shutdown()) })
+	require.NoError(t, mgr.Start())
+	admin := httptest.NewServer(mgr.AdminHandler())
+	t.Cleanup(admin.Close)
+
+	te := &testEnv{}
+	te.startSource(t, ctx)
+	source := te.sourceAddrInfo(t)
+
+	post := func(path string, v any) int {
+		body, err := json.Marshal(v)
+		require.NoError(t, err)
+		resp, err := http.Post(admin.URL+path, "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, post("/admin/sources/add", source))
+	require.Equal(t, http.StatusConflict, post("/admin/sources/add", source))
+	require.Equal(t, http.StatusBadRequest, post("/admin/sources/add", peer.AddrInfo{ID: source.ID}))
+
+	resp, err := http.Get(admin.URL + "/admin/sources")
+	require.NoError(t, err)
+	var got []peer.AddrInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	resp.Body.Close()
+	require.Equal(t, []peer.AddrInfo{source}, got)
+
+	resp, err = http.Get(admin.URL + "/admin/sources/" + source.ID.String() + "/status")
+	require.NoError(t, err)
+	var status mirror.Status
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	resp.Body.Close()
+	require.Equal(t, source.ID, status.Source)
+
+	require.Equal(t, http.StatusOK, post("/admin/sources/remove", mirror.RemoveSourceRequest{ID: source.ID}))
+	require.Equal(t, http.StatusNotFound, post("/admin/sources/remove", mirror.RemoveSourceRequest{ID: source.ID}))
+	require.Empty(t, mgr.Sources())
+	resp, err = http.Get(admin.URL + "/admin/sources/" + source.ID.String() + "/status")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestManager_AddsSourceOnce(t *testing.T) {
+	ctx := newTestContext(t)
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	mgr, err := mirror.NewManager(ds, mirror.WithMirrorOptions(mirror.WithSyncInterval(time.Hour)))
+	require.NoError(t, err)
+	require.NoError(t, mgr.Start())
+
+	// Concurrent additions of the same source add it once.
+	source := peer.AddrInfo{ID: random.Peers(1)[0]}
+	const adds = 4
+	errs := make(chan error, adds)
+	for i := 0; i < adds; i++ {
+		go func() { errs <- mgr.Add(ctx, source) }()
+	}
+	var added int
+	for i := 0; i < adds; i++ {
+		if err := <-errs; err != nil {
+			require.ErrorIs(t, err, mirror.ErrSourceExists)
+		} else {
+			added++
+		}
+	}
+	require.Equal(t, 1, added)
+	require.Equal(t, []peer.AddrInfo{source}, mgr.Sources())
+
+	require.NoError(t, mgr.Shutdown())
+	require.Error(t, mgr.Add(ctx, peer.AddrInfo{ID: random.Peers(1)[0]}))
+	require.Empty(t, mgr.Sources())
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"time"
 
 	"github.com/ipfs/go-cid"
@@ -16,10 +18,12 @@ import (
 	"github.com/ipld/go-ipld-prime/linking"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/ipni/go-libipni/announce"
+	"github.com/ipni/go-libipni/announce/gossiptopic"
 	"github.com/ipni/go-libipni/announce/p2psender"
 	"github.com/ipni/go-libipni/dagsync"
 	"github.com/ipni/go-libipni/dagsync/ipnisync"
 	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
 	"github.com/ipni/index-provider/engine/chunker"
 	"github.com/ipni/index-provider/metrics"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -39,11 +43,23 @@ type Mirror struct {
 	*options
 	source  peer.AddrInfo
 	sub     *dagsync.Subscriber
-	pub     dagsync.Publisher
+	pub     *ipnisync.Publisher
 	ls      ipld.LinkSystem
 	chunker *chunker.CachedEntriesChunker
 	cancel  context.CancelFunc
 	senders []announce.Sender
+	// syncNow triggers a sync with the source before the next sync interval.
+	syncNow  chan struct{}
+	progress progress
+	// halted is the fork at which mirroring halted, if any. It is only
+	// accessed by the sync loop.
+	halted *haltedChain
+
+	announceServer   *http.Server
+	announceListener net.Listener
+	// announceRcvr receives the announcements of the source over gossipsub.
+	announceRcvr *announce.Receiver
+	cancelTopic  context.CancelFunc
 }
 
 // New instantiates a new Mirror that mirrors ad chain from the given source provider.
@@ -59,6 +75,7 @@ func New(ctx context.Context, source peer.AddrInfo, o ...Option) (*Mirror, error
 		options: opts,
 		source:  source,
 		ls:      cidlink.DefaultLinkSystem(),
+		syncNow: make(chan struct{}, 1),
 	}
 	m.ls.StorageReadOpener = m.storageReadOpener
 	m.ls.StorageWriteOpener = m.storageWriteOpener
@@ -77,28 +94,77 @@ func New(ctx context.Context, source peer.AddrInfo, o ...Option) (*Mirror, error
 	// Create ipnisync publisher. If m.httpListenAddr has a value, then mirror
 	// will serve over HTTP on that address. If there is a libp2p Host, then
 	// the mirror will serve HTTP over libp2p using that Host.
-	m.pub, err = ipnisync.NewPublisher(m.ls, m.privKey,
+	pubOpts := []ipnisync.Option{
 		ipnisync.WithHTTPListenAddrs(m.httpListenAddr),
-		ipnisync.WithStreamHost(m.h),
-		ipnisync.WithHeadTopic(m.topic))
+		ipnisync.WithHandlerPath(m.httpHandlerPath),
+		ipnisync.WithHeadTopic(m.topic),
+	}
+	if m.httpWithoutServer {
+		pubOpts = append(pubOpts, ipnisync.WithStartServer(false))
+	} else {
+		pubOpts = append(pubOpts, ipnisync.WithStreamHost(m.h))
+	}
+	m.pub, err = ipnisync.NewPublisher(m.ls, m.privKey, pubOpts...)
+	if err != nil {
+		return nil, err
+	}
+	// Serve the latest mirrored ad, if any, as the head until the next ad is
+	// mirrored.
+	latestMirroredAdCid, err := m.getLatestMirroredAdCid(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if !cid.Undef.Equals(latestMirroredAdCid) {
+		m.pub.SetRoot(latestMirroredAdCid)
+	}
 
 	// TODO: If a mirror should send its own announcements, then pubsub senders
 	// will need a storage provider ID, set as the sender's extra data, in
 	// order to relayed through gateways. HTTP senders will new destination
 	// URLs.
-	p2pSender, err := p2psender.New(m.h, m.topic)
+	topic, cancelTopic, err := gossiptopic.MakeTopic(m.h, m.topic)
+	if err != nil {
+		return nil, fmt.Errorf("cannot join pubsub topic for mirror: %w", err)
+	}
+	m.cancelTopic = cancelTopic
+	p2pSender, err := p2psender.New(nil, "", p2psender.WithTopic(topic))
 	if err != nil {
+		cancelTopic()
 		return nil, fmt.Errorf("cannot create p2p pubsub announce sender for mirror: %w", err)
 	}
 	m.senders = append(m.senders, p2pSender)
 
-	m.sub, err = dagsync.NewSubscriber(m.h, m.ls, dagsync.RecvAnnounce(m.topic))
+	// The subscriber does not receive announcements, so that every sync with
+	// the source is bounded as configured and done by the mirror itself.
+	m.sub, err = dagsync.NewSubscriber(m.h, m.ls)
 	if err != nil {
+		cancelTopic()
 		return nil, err
 	}
+	if m.syncOnAnnounce {
+		// Only accept announcements from the source.
+		allowSource := announce.WithAllowPeer(func(p peer.ID) bool { return p == source.ID })
+		m.announceRcvr, err = announce.NewReceiver(m.h, m.topic, announce.WithTopic(topic), allowSource)
+		if err != nil {
+			m.sub.Close()
+			cancelTopic()
+			return nil, fmt.Errorf("cannot receive announcements for mirror: %w", err)
+		}
+	}
+
+	if m.announceHTTPListenAddr != "" {
+		m.announceListener, err = net.Listen("tcp", m.announceHTTPListenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot listen for http announcements: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("PUT /announce", m.handleAnnounce)
+		mux.HandleFunc("POST /announce", m.handleAnnounce)
+		m.announceServer = &http.Server{
+			Handler:     mux,
+			ReadTimeout: 30 * time.Second,
+		}
+	}
 	return m, nil
 }
 
@@ -106,6 +172,18 @@ func (m *Mirror) Start() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	m.cancel = cancel
 
+	if m.announceRcvr != nil {
+		go m.watchAnnounces(ctx)
+	}
+	if m.announceServer != nil {
+		go func() {
+			log.Infow("Receiving http announcements", "addr", m.announceListener.Addr())
+			if err := m.announceServer.Serve(m.announceListener); err != http.ErrServerClosed {
+				log.Errorw("Failed to serve http announcements", "err", err)
+			}
+		}()
+	}
+
 	go func() {
 		ticker := time.NewTicker(m.syncInterval)
 		defer ticker.Stop()
@@ -113,6 +191,10 @@ func (m *Mirror) Start() error {
 			var t time.Time
 			select {
 			case t = <-ticker.C:
+			case <-m.syncNow:
+				t = time.Now()
+				// Postpone the next check, since this one is about to happen.
+				ticker.Reset(m.syncInterval)
 			case <-ctx.Done():
 				return
 			}
@@ -126,41 +208,94 @@ func (m *Mirror) Start() error {
 			}
 			log = log.With("latestMirroredCid", mc)
 
-			var depthLimit int64
-			var stopAtCid cid.Cid
+			syncStart := time.Now()
+			if m.halted != nil {
+				stillHalted, err := m.syncHaltedFork(ctx, syncStart)
+				if err != nil {
+					log.Errorw("Failed to sync source", "err", err)
+					continue
+				}
+				if stillHalted {
+					log.Errorw("Mirroring halted at fork of source chain", "sourceHead", m.halted.head)
+					continue
+				}
+			}
+
+			var sourceHead cid.Cid
+			var syncedAdCids []cid.Cid
+			var haltedFork *ForkEvent
 			if cid.Undef.Equals(mc) {
-				depthLimit = m.initAdRecurLimit
+				sourceHead, syncedAdCids, err = m.syncAds(ctx, cid.Undef, cid.Undef, m.initAdRecurLimit)
+				if err != nil {
+					log.Errorw("Failed to sync source", "err", err)
+					continue
+				}
 			} else {
-				stopAtCid = mc
+				// If the ads synced do not link to the latest original ad
+				// processed, then the source chain was reset or rewritten.
+				var forked bool
+				sourceHead, syncedAdCids, forked, err = m.syncSince(ctx, mc)
+				if err != nil {
+					log.Errorw("Failed to sync source", "err", err)
+					continue
+				}
+				if forked {
+					fork, toMirror, err := m.handleFork(ctx, sourceHead, mc, syncedAdCids)
+					if err != nil {
+						log.Errorw("Failed to handle fork of source chain", "err", err)
+						continue
+					}
+					if m.forkPolicy == ForkHalt {
+						haltedFork = fork
+						m.halted = &haltedChain{
+							fork:    fork,
+							head:    sourceHead,
+							backlog: len(syncedAdCids),
+						}
+					} else {
+						syncedAdCids = toMirror
+					}
+				}
 			}
-
-			syncedAdCids, err := m.syncAds(ctx, stopAtCid, depthLimit)
-			if err != nil {
-				log.Errorw("Failed to sync source", "err", err)
+			m.setSynced(ctx, sourceHead, syncStart, len(syncedAdCids), haltedFork)
+			if haltedFork != nil {
+				log.Errorw("Mirroring halted at fork of source chain", "sourceHead", sourceHead)
 				continue
 			}
 
 			for _, adCid := range syncedAdCids {
-				start := time.Now()
-				err := m.mirror(ctx, adCid)
-				elapsed := time.Since(start)
-				attr := metrics.Attributes.StatusSuccess
+				err := m.mirrorWithRetries(ctx, adCid)
 				if err != nil {
-					attr = metrics.Attributes.StatusFailure
-					log.Errorw("Failed to mirror ad", "cid", adCid, "err", err)
-					// TODO add an option on what to do if the mirroring of an ad failed?
-					// TODO codify the errors and use the error code as an additional attribute in metrics.
+					if ctx.Err() != nil {
+						return
+					}
+					code := errorCode(err)
+					metrics.Mirror.FailedAds.Add(ctx, 1, metric.WithAttributes(
+						m.sourceAttr(),
+						attribute.String("error", string(code)),
+						attribute.String("policy", m.failurePolicy.String())))
+					if m.failurePolicy == FailureHalt {
+						log.Errorw("Failed to mirror ad; halting until next sync", "cid", adCid, "err", err)
+						break
+					}
+					log.Errorw("Failed to mirror ad; skipping", "cid", adCid, "err", err)
+					skipped := SkippedAd{
+						AdCid: adCid,
+						Code:  code,
+						Error: err.Error(),
+						Time:  time.Now(),
+					}
+					if err = m.addSkippedAd(ctx, skipped); err != nil {
+						log.Errorw("Failed to record skipped ad", "cid", adCid, "err", err)
+					}
 				}
-				metrics.Mirror.ProcessDuration.Record(ctx, elapsed.Milliseconds(), metric.WithAttributeSet(attribute.NewSet(attr)))
-			}
-
-			syncedCount := len(syncedAdCids)
-			if syncedCount > 0 {
-				latestOriginal := syncedAdCids[syncedCount-1]
-				err = m.setLatestOriginalAdCid(ctx, latestOriginal)
-				if err != nil {
-					log.Errorw("Failed to store latest original ad cid", "cid", latestOriginal, "err", err)
+				// Record progress after each ad, so that a restart does not
+				// mirror the same ads again.
+				if err = m.setLatestOriginalAdCid(ctx, adCid); err != nil {
+					log.Errorw("Failed to store latest original ad cid", "cid", adCid, "err", err)
+					break
 				}
+				m.setProcessed(ctx)
 			}
 		}
 	}()
@@ -172,38 +307,148 @@ func (m *Mirror) Shutdown() error {
 	if m.cancel != nil {
 		m.cancel()
 	}
-	return nil
+	var errs error
+	if m.announceServer != nil {
+		errs = errors.Join(errs, m.announceServer.Close())
+	}
+	if m.announceRcvr != nil {
+		errs = errors.Join(errs, m.announceRcvr.Close())
+	}
+	errs = errors.Join(errs, m.sub.Close(), m.pub.Close())
+	m.cancelTopic()
+	if m.closeHost {
+		errs = errors.Join(errs, m.h.Close())
+	}
+	return errs
 }
 
 func (m *Mirror) PublisherAddrs() []multiaddr.Multiaddr {
 	return m.pub.Addrs()
 }
 
+// PublisherHTTPHandler returns the http.Handler that serves the mirrored
+// advertisements over HTTP. The handler is only valid if the
+// WithHTTPPublisherWithoutServer option is set.
+func (m *Mirror) PublisherHTTPHandler() (http.Handler, error) {
+	if !m.httpWithoutServer {
+		return nil, errors.New("HTTPPublisherWithoutServer option not set")
+	}
+	return m.pub, nil
+}
+
+// Source returns the provider mirrored by the mirror.
+func (m *Mirror) Source() peer.AddrInfo {
+	return m.source
+}
+
+// AnnounceAddr returns the address on which HTTP announcements are received,
+// or nil if they are not.
+//
+// See: WithAnnounceHTTPListenAddr.
+func (m *Mirror) AnnounceAddr() net.Addr {
+	if m.announceListener == nil {
+		return nil
+	}
+	return m.announceListener.Addr()
+}
+
+// mirrorWithRetries mirrors an ad, retrying on transient failure as many times
+// as configured, with exponential backoff.
+func (m *Mirror) mirrorWithRetries(ctx context.Context, adCid cid.Cid) error {
+	backoff := m.failureRetryBackoff
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := m.mirror(ctx, adCid)
+		elapsed := time.Since(start)
+		attrs := []attribute.KeyValue{m.sourceAttr(), metrics.Attributes.StatusSuccess}
+		if err != nil {
+			attrs = []attribute.KeyValue{m.sourceAttr(), metrics.Attributes.StatusFailure, attribute.String("error", string(errorCode(err)))}
+		}
+		metrics.Mirror.ProcessDuration.Record(ctx, elapsed.Milliseconds(), metric.WithAttributeSet(attribute.NewSet(attrs...)))
+		if err == nil {
+			m.clearAdError(adCid)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		m.setAdError(adCid, err)
+		if attempt >= m.failureRetries || !errorCode(err).Transient() {
+			return err
+		}
+
+		log.Warnw("Failed to mirror ad; retrying", "cid", adCid, "attempt", attempt+1, "backoff", backoff, "err", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
 func (m *Mirror) mirror(ctx context.Context, adCid cid.Cid) error {
 	log := log.With("originalAd", adCid)
 	ad, err := m.loadAd(ctx, adCid)
 	if err != nil {
-		return err
+		return newMirrorError(ErrorCodeLoadAd, err)
 	}
 	if err := ad.Validate(); err != nil {
 		log.Errorw("Original ad is invalid", "err", err)
-		return err
+		return newMirrorError(ErrorCodeInvalidAd, err)
 	}
 
 	origSigner, err := ad.VerifySignature()
 	if err != nil {
 		log.Errorw("Original ad signature verification failed", "err", err)
-		return err
+		return newMirrorError(ErrorCodeSignature, err)
 	}
 	log = log.With("originalSigner", origSigner)
 
 	var adChanged bool
+	if m.filter != nil {
+		if reason, excluded := m.filter.Excludes(ad); excluded {
+			metrics.Mirror.FilteredAds.Add(ctx, 1, metric.WithAttributes(
+				m.sourceAttr(),
+				attribute.String("reason", string(reason))))
+			mirrored, err := m.isContextMirrored(ctx, ad)
+			if err != nil {
+				return newMirrorError(ErrorCodeStore, err)
+			}
+			if !mirrored {
+				// Leave the latest mirrored ad unchanged, so that the next
+				// mirrored ad links to it.
+				log.Infow("Ad excluded by filter; not mirroring", "reason", reason)
+				return nil
+			}
+			// The context ID was mirrored before; remove it, so that the
+			// mirrored chain does not keep advertising content that no
+			// longer passes the filter.
+			log.Infow("Ad excluded by filter; mirroring removal of its context ID", "reason", reason)
+			if ad, err = removalOf(ad); err != nil {
+				return newMirrorError(ErrorCodeRewrite, err)
+			}
+			adChanged = true
+		}
+	}
+
+	if m.rewrite != nil {
+		rewritten, err := m.rewrite.apply(ad)
+		if err != nil {
+			log.Errorw("Failed to rewrite ad", "err", err)
+			return newMirrorError(ErrorCodeRewrite, err)
+		}
+		adChanged = adChanged || rewritten
+	}
+
 	// Mirror link to previous ad.
 	wasPreviousID := ad.PreviousID
 	prevMirroredAdCid, err := m.getLatestMirroredAdCid(ctx)
 	if err != nil {
 		log.Errorw("Failed to get latest mirrored ad", "err", err)
-		return err
+		return newMirrorError(ErrorCodeStore, err)
 	} else if !cid.Undef.Equals(prevMirroredAdCid) {
 		// Only override the original previousID link if there is a previously mirrored ad.
 		// This means that if mirroring starts from a partial original ad chain, the original link
@@ -211,7 +456,7 @@ func (m *Mirror) mirror(ctx context.Context, adCid cid.Cid) error {
 		// by the mirror.
 		ad.PreviousID = cidlink.Link{Cid: prevMirroredAdCid}
 	}
-	adChanged = wasPreviousID != ad.PreviousID
+	adChanged = adChanged || wasPreviousID != ad.PreviousID
 
 	// Mirror link to entries.
 	wasEntries := ad.Entries
@@ -220,21 +465,27 @@ func (m *Mirror) mirror(ctx context.Context, adCid cid.Cid) error {
 		switch entriesCid {
 		case cid.Undef:
 			// advertisement is invalid? entries CID should never be cid.Undef for non-removal ads.
-			return errors.New("entries link is cid.Undef")
+			return newMirrorError(ErrorCodeInvalidAd, errors.New("entries link is cid.Undef"))
 		case schema.NoEntries.Cid:
 			// Nothing to do.
 		default:
 			if len(m.source.Addrs) == 0 {
-				return errors.New("no address for source")
+				return newMirrorError(ErrorCodeSyncEntries, errors.New("no address for source"))
+			}
+			syncCtx, entriesBytes := withEntriesBytesCounter(ctx)
+			err = m.sub.SyncEntries(syncCtx, m.source, entriesCid, dagsync.ScopedDepthLimit(m.entriesRecurLimit))
+			// Count the entries synced before any failure, since they are
+			// stored regardless.
+			if bErr := m.addEntriesBytes(ctx, entriesBytes.n); bErr != nil {
+				log.Warnw("Failed to store size of synced entries", "err", bErr)
 			}
-			err = m.sub.SyncEntries(ctx, m.source, entriesCid, dagsync.ScopedDepthLimit(m.entriesRecurLimit))
 			if err != nil {
 				log.Errorw("Failed to sync entries", "cid", entriesCid, "err", err)
-				return err
+				return newMirrorError(ErrorCodeSyncEntries, err)
 			}
 			ad.Entries, err = m.remapEntries(ctx, ad.Entries)
 			if err != nil {
-				return err
+				return newMirrorError(ErrorCodeRemapEntries, err)
 			}
 		}
 	}
@@ -243,7 +494,7 @@ func (m *Mirror) mirror(ctx context.Context, adCid cid.Cid) error {
 	// Only re-sign ad if the option is set or some content in the ad has changed.
 	if m.alwaysReSignAds || adChanged {
 		if err := ad.Sign(m.h.Peerstore().PrivKey(m.h.ID())); err != nil {
-			return err
+			return newMirrorError(ErrorCodeSignature, err)
 		}
 	}
 
@@ -254,31 +505,59 @@ func (m *Mirror) mirror(ctx context.Context, adCid cid.Cid) error {
 	// become more selective to check the fields that may be modified by mirroring like the
 	// entries link.
 	if err := ad.Validate(); err != nil {
-		return err
+		return newMirrorError(ErrorCodeInvalidAd, err)
 	}
 
 	node, err := ad.ToNode()
 	if err != nil {
-		return err
+		return newMirrorError(ErrorCodeInvalidAd, err)
 	}
 	mirroredAdLink, err := m.ls.Store(ipld.LinkContext{Ctx: ctx}, schema.Linkproto, node)
 	if err != nil {
-		return err
+		return newMirrorError(ErrorCodeStore, err)
 	}
 
 	mirroredAdCid := mirroredAdLink.(cidlink.Link).Cid
 	if err = m.setLatestMirroredAdCid(ctx, mirroredAdCid); err != nil {
-		return err
+		return newMirrorError(ErrorCodeStore, err)
+	}
+	if m.filter != nil {
+		if err = m.trackMirroredContext(ctx, ad); err != nil {
+			return newMirrorError(ErrorCodeStore, err)
+		}
 	}
 
 	m.pub.SetRoot(mirroredAdCid)
+	// The ad is mirrored at this point, so failing to announce it is not a
+	// failure to mirror it; the next announcement covers it.
 	if err = announce.Send(ctx, mirroredAdCid, m.pub.Addrs(), m.senders...); err != nil {
-		return err
+		log.Warnw("Failed to announce mirrored ad", "mirroredAdCid", mirroredAdCid, "err", err)
 	}
 	log.Infow("Mirrored successfully", "originalAdCid", adCid, "mirroredAdCid", mirroredAdCid)
 	return nil
 }
 
+// removalOf returns an ad that removes the context ID of the given ad from its
+// provider.
+func removalOf(ad *schema.Advertisement) (*schema.Advertisement, error) {
+	// The ad still requires a valid metadata even though metadata is not
+	// used for removal.
+	md := metadata.Default.New()
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &schema.Advertisement{
+		PreviousID: ad.PreviousID,
+		Provider:   ad.Provider,
+		Addresses:  ad.Addresses,
+		Entries:    schema.NoEntries,
+		ContextID:  ad.ContextID,
+		Metadata:   mdBytes,
+		IsRm:       true,
+	}, nil
+}
+
 func (m *Mirror) storageReadOpener(lctx linking.LinkContext, lnk datamodel.Link) (io.Reader, error) {
 	if lnk == schema.NoEntries {
 		return nil, errors.New("no-entries CID is not retrievable")
@@ -345,6 +624,7 @@ func (m *Mirror) storageReadOpener(lctx linking.LinkContext, lnk datamodel.Link)
 func (m *Mirror) storageWriteOpener(lctx linking.LinkContext) (io.Writer, linking.BlockWriteCommitter, error) {
 	buf := bytes.NewBuffer(nil)
 	return buf, func(lnk ipld.Link) error {
+		countEntriesBytes(lctx.Ctx, buf.Len())
 		return m.ds.Put(lctx.Ctx, datastore.NewKey(lnk.Binary()), buf.Bytes())
 	}, nil
 }
@@ -384,13 +664,19 @@ func (m *Mirror) remapEntries(ctx context.Context, original ipld.Link) (ipld.Lin
 	return mirroredEntriesLink, nil
 }
 
-func (m *Mirror) syncAds(ctx context.Context, stopAtCid cid.Cid, depthLimit int64) ([]cid.Cid, error) {
+// syncAds syncs the ad chain of the source from the given head, or from the
+// head of the source if undefined, and returns the head and the CIDs of the
+// ads synced, oldest first.
+func (m *Mirror) syncAds(ctx context.Context, headCid, stopAtCid cid.Cid, depthLimit int64) (cid.Cid, []cid.Cid, error) {
 	if len(m.source.Addrs) == 0 {
-		return nil, errors.New("no address for source")
+		return cid.Undef, nil, errors.New("no address for source")
 	}
 	startSync := time.Now()
 	var syncedAdCids []cid.Cid
-	_, err := m.sub.SyncAdChain(ctx, m.source, dagsync.WithHeadAdCid(stopAtCid), dagsync.ScopedDepthLimit(depthLimit),
+	// Stop at the latest original ad processed by the mirror, rather than
+	// the latest synced by the subscriber, so that ads left unprocessed when
+	// mirroring halts are synced again.
+	head, err := m.sub.SyncAdChain(ctx, m.source, dagsync.WithHeadAdCid(headCid), dagsync.WithStopAdCid(stopAtCid), dagsync.WithAdsResync(true), dagsync.ScopedDepthLimit(depthLimit),
 		dagsync.ScopedBlockHook(func(id peer.ID, c cid.Cid, actions dagsync.SegmentSyncActions) {
 			// TODO: set actions next segment link to ad previous id if it is present. For
 			//      now segmentation is disabled.
@@ -409,6 +695,12 @@ func (m *Mirror) syncAds(ctx context.Context, stopAtCid cid.Cid, depthLimit int6
 	if err != nil {
 		attr = metrics.Attributes.StatusFailure
 	}
-	metrics.Mirror.SyncDuration.Record(ctx, elapsedSync.Milliseconds(), metric.WithAttributeSet(attribute.NewSet(attr)))
-	return syncedAdCids, err
+	metrics.Mirror.SyncDuration.Record(ctx, elapsedSync.Milliseconds(), metric.WithAttributeSet(attribute.NewSet(m.sourceAttr(), attr)))
+	return head, syncedAdCids, err
+}
+
+// sourceAttr returns the metrics attribute that identifies the source, so
+// that the metrics of mirrors running in the same process can be told apart.
+func (m *Mirror) sourceAttr() attribute.KeyValue {
+	return attribute.String("source", m.source.ID.String())
 }
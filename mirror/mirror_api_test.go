@@ -1,6 +1,8 @@
 package mirror
 
 import (
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/ipld/go-ipld-prime/schema"
 )
 
@@ -24,3 +26,17 @@ func (m *Mirror) EntriesRemapPrototype() schema.TypedPrototype {
 func (m *Mirror) AlwaysReSignAds() bool {
 	return m.alwaysReSignAds
 }
+
+// LatestSync is exposed for testing purposes only.
+func (m *Mirror) LatestSync() cid.Cid {
+	lnk := m.sub.GetLatestSync(m.source.ID)
+	if lnk == nil {
+		return cid.Undef
+	}
+	return lnk.(cidlink.Link).Cid
+}
+
+// SyncNow is exposed for testing purposes only.
+func (m *Mirror) SyncNow() {
+	m.triggerSync()
+}
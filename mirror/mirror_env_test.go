@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"testing"
 
 	"github.com/ipfs/go-cid"
@@ -31,9 +32,10 @@ import (
 )
 
 type testEnv struct {
-	sourceHost host.Host
-	source     *engine.Engine
-	sourceMhs  map[string][]multihash.Multihash
+	sourceHost    host.Host
+	source        *engine.Engine
+	sourceMhs     map[string][]multihash.Multihash
+	sourceMhsLock sync.Mutex
 
 	mirror            *mirror.Mirror
 	mirrorHost        host.Host
@@ -50,11 +52,18 @@ func (te *testEnv) startMirror(t *testing.T, ctx context.Context, opts ...mirror
 	require.NoError(t, err)
 	// Override the host, since test environment needs explicit access to it.
 	opts = append(opts, mirror.WithHost(te.mirrorHost, privKey))
-	te.mirror, err = mirror.New(ctx, te.sourceAddrInfo(t), opts...)
+	m, err := mirror.New(ctx, te.sourceAddrInfo(t), opts...)
 	require.NoError(t, err)
-	require.NoError(t, te.mirror.Start())
-	t.Cleanup(func() { require.NoError(t, te.mirror.Shutdown()) })
+	require.NoError(t, m.Start())
+	t.Cleanup(func() { require.NoError(t, m.Shutdown()) })
+	te.useMirror(t, m)
+}
 
+// useMirror sets the mirror of the test environment, running on te.mirrorHost,
+// and syncs from it over HTTP.
+func (te *testEnv) useMirror(t *testing.T, m *mirror.Mirror) {
+	var err error
+	te.mirror = m
 	te.mirrorSyncLsStore = &memstore.Store{}
 	te.mirrorSyncLs = cidlink.DefaultLinkSystem()
 	te.mirrorSyncLs.SetReadStorage(te.mirrorSyncLsStore)
@@ -91,7 +100,7 @@ func (te *testEnv) startSource(t *testing.T, ctx context.Context, opts ...engine
 }
 
 func (te *testEnv) putAdOnSource(t *testing.T, ctx context.Context, ctxID []byte, mhs []multihash.Multihash, md metadata.Metadata) cid.Cid {
-	te.sourceMhs[string(ctxID)] = mhs
+	te.setSourceMhs(ctxID, mhs)
 	adCid, err := te.source.NotifyPut(ctx, nil, ctxID, md)
 	require.NoError(t, err)
 	return adCid
@@ -103,7 +112,21 @@ func (te *testEnv) removeAdOnSource(t *testing.T, ctx context.Context, ctxID []b
 	return adCid
 }
 
+// setSourceMhs sets the multihashes listed by the source for a context ID, or
+// makes listing them fail if mhs is nil.
+func (te *testEnv) setSourceMhs(ctxID []byte, mhs []multihash.Multihash) {
+	te.sourceMhsLock.Lock()
+	defer te.sourceMhsLock.Unlock()
+	if mhs == nil {
+		delete(te.sourceMhs, string(ctxID))
+		return
+	}
+	te.sourceMhs[string(ctxID)] = mhs
+}
+
 func (te *testEnv) listMultihashes(_ context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+	te.sourceMhsLock.Lock()
+	defer te.sourceMhsLock.Unlock()
 	mhs, ok := te.sourceMhs[string(contextID)]
 	if !ok {
 		return nil, fmt.Errorf("no multihashes found for context ID: %s", string(contextID))
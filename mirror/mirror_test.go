@@ -2,6 +2,12 @@ package mirror_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,10 +15,14 @@ import (
 	"github.com/ipfs/go-test/random"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/ipni/go-libipni/announce/httpsender"
+	"github.com/ipni/go-libipni/announce/message"
 	"github.com/ipni/go-libipni/ingest/schema"
 	"github.com/ipni/go-libipni/metadata"
 	"github.com/ipni/index-provider/engine"
 	"github.com/ipni/index-provider/mirror"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
 	"github.com/multiformats/go-multihash"
 	"github.com/stretchr/testify/require"
 )
@@ -310,3 +320,540 @@ func TestMirror_MirrorsAdsIdenticallyWhenConfiguredTo(t *testing.T) {
 	// verified against the content.
 	te.requireAdChainMirroredRecursively(t, ctx, originalHeadCid, gotMirroredHeadAdCid)
 }
+
+// putAdsWithUnservableEntries publishes an ad whose entries the source cannot
+// serve, followed by one whose entries it can. Returns their CIDs and the
+// multihashes of the bad ad.
+func (te *testEnv) putAdsWithUnservableEntries(t *testing.T, ctx context.Context) (cid.Cid, cid.Cid, []multihash.Multihash) {
+	md := metadata.Default.New(metadata.Bitswap{})
+	// The source only caches the entries of the latest ad, so the entries of
+	// the bad ad are regenerated when requested, which fails once the source
+	// no longer lists its multihashes.
+	badMhs := random.Multihashes(3)
+	badAd := te.putAdOnSource(t, ctx, []byte("bad"), badMhs, md)
+	goodAd := te.putAdOnSource(t, ctx, []byte("good"), random.Multihashes(4), md)
+	te.setSourceMhs([]byte("bad"), nil)
+	return badAd, goodAd, badMhs
+}
+
+func TestMirror_SkipsAndRecordsFailedAds(t *testing.T) {
+	ctx := newTestContext(t)
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher), engine.WithEntriesCacheCapacity(1))
+	badAd, _, _ := te.putAdsWithUnservableEntries(t, ctx)
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second), mirror.WithFailureRetries(1, 10*time.Millisecond))
+
+	var gotMirroredHeadCid cid.Cid
+	var err error
+	require.Eventually(t, func() bool {
+		gotMirroredHeadCid, err = te.mirrorSyncer.GetHead(ctx)
+		return err == nil && !cid.Undef.Equals(gotMirroredHeadCid)
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+
+	ad, err := te.syncMirrorAd(ctx, gotMirroredHeadCid)
+	require.NoError(t, err)
+	require.Equal(t, []byte("good"), ad.ContextID)
+
+	skipped, err := te.mirror.SkippedAds(ctx)
+	require.NoError(t, err)
+	require.Len(t, skipped, 1)
+	require.Equal(t, badAd, skipped[0].AdCid)
+	require.Equal(t, mirror.ErrorCodeSyncEntries, skipped[0].Code)
+	require.NotEmpty(t, skipped[0].Error)
+}
+
+func TestMirror_HaltsAndRetriesFromFailedAd(t *testing.T) {
+	ctx := newTestContext(t)
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher), engine.WithEntriesCacheCapacity(1))
+	_, goodAd, badMhs := te.putAdsWithUnservableEntries(t, ctx)
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second), mirror.WithFailurePolicy(mirror.FailureHalt))
+
+	// Nothing is mirrored past the failed ad, which is the first one.
+	time.Sleep(2 * time.Second)
+	head, err := te.mirrorSyncer.GetHead(ctx)
+	require.True(t, err != nil || cid.Undef.Equals(head), "unexpected mirrored head %s", head)
+
+	// Once the source serves the entries again, mirroring resumes from the
+	// failed ad.
+	te.setSourceMhs([]byte("bad"), badMhs)
+	require.Eventually(t, func() bool {
+		head, err = te.mirrorSyncer.GetHead(ctx)
+		if err != nil || cid.Undef.Equals(head) {
+			return false
+		}
+		ad, err := te.syncMirrorAd(ctx, head)
+		return err == nil && string(ad.ContextID) == "good"
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+
+	skipped, err := te.mirror.SkippedAds(ctx)
+	require.NoError(t, err)
+	require.Empty(t, skipped)
+	te.requireAdChainMirroredRecursively(t, ctx, goodAd, head)
+}
+
+func TestMirror_StatusReportsProgress(t *testing.T) {
+	ctx := newTestContext(t)
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher), engine.WithEntriesCacheCapacity(1))
+	badAd, goodAd, badMhs := te.putAdsWithUnservableEntries(t, ctx)
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second), mirror.WithFailurePolicy(mirror.FailureHalt))
+
+	// Both ads are behind while mirroring halts at the failed one.
+	var status *mirror.Status
+	var err error
+	require.Eventually(t, func() bool {
+		status, err = te.mirror.Status(ctx)
+		return err == nil && len(status.AdErrors) != 0
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	require.Equal(t, te.sourceHost.ID(), status.Source)
+	require.Equal(t, goodAd, status.SourceHead)
+	require.Equal(t, cid.Undef, status.LatestOriginalAd)
+	require.Equal(t, cid.Undef, status.LatestMirroredAd)
+	require.Equal(t, 2, status.Backlog)
+	require.Equal(t, badAd, status.AdErrors[0].AdCid)
+	require.Equal(t, mirror.ErrorCodeSyncEntries, status.AdErrors[0].Code)
+	require.NotEmpty(t, status.AdErrors[0].Error)
+	require.Nil(t, status.RemapCache)
+
+	// Once the source serves the entries again, the mirror catches up.
+	te.setSourceMhs([]byte("bad"), badMhs)
+	require.Eventually(t, func() bool {
+		status, err = te.mirror.Status(ctx)
+		return err == nil && status.LatestOriginalAd == goodAd
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	head, err := te.mirrorSyncer.GetHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, status.LatestMirroredAd)
+	require.Zero(t, status.Backlog)
+	require.Zero(t, status.LagSeconds)
+	require.Empty(t, status.AdErrors)
+	require.NotZero(t, status.EntriesBytes)
+
+	// The status is also served over HTTP.
+	statusServer := httptest.NewServer(te.mirror.StatusHandler())
+	t.Cleanup(statusServer.Close)
+	resp, err := http.Get(statusServer.URL + "/status")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var got mirror.Status
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, status.LatestMirroredAd, got.LatestMirroredAd)
+	require.Equal(t, status.EntriesBytes, got.EntriesBytes)
+}
+
+// forkSource resets the chain of the source to a new ad that does not link to
+// any previous ad, and returns its CID.
+func (te *testEnv) forkSource(t *testing.T, ctx context.Context) cid.Cid {
+	md := metadata.Default.New(metadata.Bitswap{})
+	mdBytes, err := md.MarshalBinary()
+	require.NoError(t, err)
+	source := te.sourceAddrInfo(t)
+	ad := schema.Advertisement{
+		Provider:  source.ID.String(),
+		Addresses: multiaddrsToStrings(source.Addrs),
+		Entries:   schema.NoEntries,
+		ContextID: []byte("fork"),
+		Metadata:  mdBytes,
+	}
+	require.NoError(t, ad.Sign(te.sourceHost.Peerstore().PrivKey(source.ID)))
+	adCid, err := te.source.Publish(ctx, ad)
+	require.NoError(t, err)
+	return adCid
+}
+
+func multiaddrsToStrings(addrs []multiaddr.Multiaddr) []string {
+	s := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		s = append(s, a.String())
+	}
+	return s
+}
+
+// requireLatestOriginalAd waits until the mirror has processed the given
+// original ad.
+func (te *testEnv) requireLatestOriginalAd(t *testing.T, ctx context.Context, want cid.Cid) *mirror.Status {
+	var status *mirror.Status
+	var err error
+	require.Eventually(t, func() bool {
+		status, err = te.mirror.Status(ctx)
+		return err == nil && status.LatestOriginalAd == want
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	return status
+}
+
+func TestMirror_RemirrorsForkedSourceChain(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	te.putAdOnSource(t, ctx, []byte("first"), random.Multihashes(3), md)
+	secondAd := te.putAdOnSource(t, ctx, []byte("second"), random.Multihashes(3), md)
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second))
+	te.requireLatestOriginalAd(t, ctx, secondAd)
+
+	te.forkSource(t, ctx)
+	afterForkAd := te.putAdOnSource(t, ctx, []byte("after-fork"), random.Multihashes(3), md)
+	status := te.requireLatestOriginalAd(t, ctx, afterForkAd)
+	require.Nil(t, status.Fork)
+
+	forks, err := te.mirror.Forks(ctx)
+	require.NoError(t, err)
+	require.Len(t, forks, 1)
+	require.Equal(t, secondAd, forks[0].LatestOriginalAd)
+	require.Equal(t, "remirror", forks[0].Policy)
+
+	// The new chain is mirrored from scratch, without links to the ads
+	// mirrored before the fork.
+	head, err := te.mirrorSyncer.GetHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, status.LatestMirroredAd, head)
+	te.requireAdChainMirroredRecursively(t, ctx, afterForkAd, head)
+	mirrored, err := te.syncMirrorAd(ctx, head)
+	require.NoError(t, err)
+	mirroredFork, err := te.syncMirrorAd(ctx, mirrored.PreviousID.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	require.Equal(t, []byte("fork"), mirroredFork.ContextID)
+	require.Nil(t, mirroredFork.PreviousID)
+}
+
+func TestMirror_HaltsAtForkedSourceChain(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	firstAd := te.putAdOnSource(t, ctx, []byte("first"), random.Multihashes(3), md)
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second), mirror.WithForkPolicy(mirror.ForkHalt))
+	status := te.requireLatestOriginalAd(t, ctx, firstAd)
+	wantHead := status.LatestMirroredAd
+
+	forkAd := te.forkSource(t, ctx)
+	var err error
+	require.Eventually(t, func() bool {
+		status, err = te.mirror.Status(ctx)
+		return err == nil && status.Fork != nil
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	require.Equal(t, forkAd, status.Fork.SourceHead)
+	require.Equal(t, firstAd, status.Fork.LatestOriginalAd)
+	require.Equal(t, wantHead, status.Fork.LatestMirroredAd)
+	require.Equal(t, firstAd, status.LatestOriginalAd)
+	require.Equal(t, 1, status.Backlog)
+
+	// The ads mirrored before the fork are still served, and the fork is
+	// recorded once however many times it is detected.
+	time.Sleep(2 * time.Second)
+	head, err := te.mirrorSyncer.GetHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, wantHead, head)
+	forks, err := te.mirror.Forks(ctx)
+	require.NoError(t, err)
+	require.Len(t, forks, 1)
+	require.Equal(t, "halt", forks[0].Policy)
+}
+
+func TestMirror_HaltedForkIsNotWalkedAgain(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	firstAd := te.putAdOnSource(t, ctx, []byte("first"), random.Multihashes(3), md)
+
+	// Only sync when triggered, by setting an interval longer than the test.
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Hour),
+		mirror.WithForkPolicy(mirror.ForkHalt),
+		mirror.WithInitialAdRecursionLimit(1),
+		mirror.WithForkDetectionDepth(2))
+	te.mirror.SyncNow()
+	te.requireLatestOriginalAd(t, ctx, firstAd)
+
+	te.forkSource(t, ctx)
+	te.putAdOnSource(t, ctx, []byte("fork-1"), random.Multihashes(3), md)
+	forkHead := te.putAdOnSource(t, ctx, []byte("fork-2"), random.Multihashes(3), md)
+	te.mirror.SyncNow()
+
+	// The fork is detected from a walk bounded by the fork detection depth.
+	var status *mirror.Status
+	var err error
+	require.Eventually(t, func() bool {
+		status, err = te.mirror.Status(ctx)
+		return err == nil && status.Fork != nil
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	require.Equal(t, forkHead, status.Fork.SourceHead)
+	require.Equal(t, 2, status.Backlog)
+
+	// Later syncs only walk the ads published on top of the forked chain.
+	nextAd := te.putAdOnSource(t, ctx, []byte("fork-3"), random.Multihashes(3), md)
+	te.mirror.SyncNow()
+	require.Eventually(t, func() bool {
+		status, err = te.mirror.Status(ctx)
+		return err == nil && status.SourceHead == nextAd
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	require.Equal(t, 3, status.Backlog)
+	require.NotNil(t, status.Fork)
+	require.Equal(t, forkHead, status.Fork.SourceHead)
+	require.Equal(t, firstAd, status.LatestOriginalAd)
+}
+
+func TestMirror_SyncsMoreAdsThanRecursionLimitWithoutFork(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	firstAd := te.putAdOnSource(t, ctx, []byte("first"), random.Multihashes(3), md)
+
+	// Only sync when triggered, by setting an interval longer than the test.
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Hour), mirror.WithInitialAdRecursionLimit(2))
+	te.mirror.SyncNow()
+	status := te.requireLatestOriginalAd(t, ctx, firstAd)
+	firstMirrored := status.LatestMirroredAd
+
+	// The source publishes more ads than the recursion limit between syncs.
+	var headAd cid.Cid
+	for _, ctxID := range []string{"second", "third", "fourth"} {
+		headAd = te.putAdOnSource(t, ctx, []byte(ctxID), random.Multihashes(3), md)
+	}
+	te.mirror.SyncNow()
+	status = te.requireLatestOriginalAd(t, ctx, headAd)
+	require.Nil(t, status.Fork)
+	forks, err := te.mirror.Forks(ctx)
+	require.NoError(t, err)
+	require.Empty(t, forks)
+
+	// The mirrored chain still links back to the ads mirrored before.
+	head, err := te.syncMirrorAd(ctx, status.LatestMirroredAd)
+	require.NoError(t, err)
+	require.Equal(t, []byte("fourth"), head.ContextID)
+	for _, want := range []string{"third", "second"} {
+		head, err = te.syncMirrorAd(ctx, head.PreviousID.(cidlink.Link).Cid)
+		require.NoError(t, err)
+		require.Equal(t, []byte(want), head.ContextID)
+	}
+	require.Equal(t, firstMirrored, head.PreviousID.(cidlink.Link).Cid)
+}
+
+func TestMirror_SyncsOnHttpAnnounce(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher), engine.WithPubsubAnnounce(false))
+	// Only sync on announcements, by setting an interval longer than the test.
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Hour), mirror.WithAnnounceHTTPListenAddr("127.0.0.1:0"))
+	announceURL, err := url.Parse("http://" + te.mirror.AnnounceAddr().String() + "/announce")
+	require.NoError(t, err)
+
+	adCid := te.putAdOnSource(t, ctx, []byte("fish"), random.Multihashes(3), md)
+	source := te.sourceAddrInfo(t)
+	msg := message.Message{Cid: adCid}
+	msg.SetAddrs(source.Addrs)
+
+	// Announcements from other peers are rejected.
+	sender, err := httpsender.New([]*url.URL{announceURL}, random.Peers(1)[0])
+	require.NoError(t, err)
+	require.ErrorContains(t, sender.Send(ctx, msg), "403")
+
+	sender, err = httpsender.New([]*url.URL{announceURL}, source.ID)
+	require.NoError(t, err)
+	require.NoError(t, sender.Send(ctx, msg))
+
+	var gotMirroredHeadCid cid.Cid
+	require.Eventually(t, func() bool {
+		gotMirroredHeadCid, err = te.mirrorSyncer.GetHead(ctx)
+		return err == nil && !cid.Undef.Equals(gotMirroredHeadCid)
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	te.requireAdChainMirroredRecursively(t, ctx, adCid, gotMirroredHeadCid)
+}
+
+func TestMirror_SyncsOnPubsubAnnounce(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	// Only sync on announcements, by setting an interval longer than the test.
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Hour), mirror.WithSyncOnAnnounce(true))
+	require.NoError(t, te.mirrorHost.Connect(ctx, te.sourceAddrInfo(t)))
+
+	// Announce until the gossipsub mesh forms and the announcement arrives.
+	var adCid cid.Cid
+	var gotMirroredHeadCid cid.Cid
+	var err error
+	var i int
+	require.Eventually(t, func() bool {
+		i++
+		adCid = te.putAdOnSource(t, ctx, []byte(fmt.Sprint("fish", i)), random.Multihashes(3), md)
+		gotMirroredHeadCid, err = te.mirrorSyncer.GetHead(ctx)
+		return err == nil && !cid.Undef.Equals(gotMirroredHeadCid)
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+
+	// Later ads are mirrored as soon as they are announced.
+	adCid = te.putAdOnSource(t, ctx, []byte("lobster"), random.Multihashes(3), md)
+	require.Eventually(t, func() bool {
+		gotMirroredHeadCid, err = te.mirrorSyncer.GetHead(ctx)
+		if err != nil {
+			return false
+		}
+		ad, err := te.syncMirrorAd(ctx, gotMirroredHeadCid)
+		return err == nil && string(ad.ContextID) == "lobster"
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	te.requireAdChainMirroredRecursively(t, ctx, adCid, gotMirroredHeadCid)
+}
+
+func TestMirror_DoesNotSyncOnPubsubAnnounceUnlessEnabled(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Hour))
+	require.NoError(t, te.mirrorHost.Connect(ctx, te.sourceAddrInfo(t)))
+
+	// Announcements received over gossipsub do not sync the source chain.
+	var i int
+	require.Never(t, func() bool {
+		i++
+		te.putAdOnSource(t, ctx, []byte(fmt.Sprint("fish", i)), random.Multihashes(3), md)
+		return !cid.Undef.Equals(te.mirror.LatestSync())
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func TestMirror_FilteredAdsAreNotMirrored(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher), engine.WithEntriesCacheCapacity(1))
+	te.putAdOnSource(t, ctx, []byte("keep-1"), random.Multihashes(3), md)
+	te.putAdOnSource(t, ctx, []byte("drop"), random.Multihashes(3), md)
+	keepAd := te.putAdOnSource(t, ctx, []byte("keep-2"), random.Multihashes(3), md)
+	// Make the entries of the filtered ad unservable, so that syncing them
+	// would fail the ad.
+	te.setSourceMhs([]byte("drop"), nil)
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second),
+		mirror.WithFilter(mirror.Filter{ContextIDPrefixes: [][]byte{[]byte("keep")}}))
+
+	var gotMirroredHeadCid cid.Cid
+	var err error
+	require.Eventually(t, func() bool {
+		gotMirroredHeadCid, err = te.mirrorSyncer.GetHead(ctx)
+		return err == nil && !cid.Undef.Equals(gotMirroredHeadCid)
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+
+	// The mirrored chain links the ads that pass the filter.
+	head, err := te.syncMirrorAd(ctx, gotMirroredHeadCid)
+	require.NoError(t, err)
+	require.Equal(t, []byte("keep-2"), head.ContextID)
+	original, err := te.source.GetAdv(ctx, keepAd)
+	require.NoError(t, err)
+	te.requireAdMirrored(t, ctx, original, head)
+
+	prev, err := te.syncMirrorAd(ctx, head.PreviousID.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	require.Equal(t, []byte("keep-1"), prev.ContextID)
+
+	skipped, err := te.mirror.SkippedAds(ctx)
+	require.NoError(t, err)
+	require.Empty(t, skipped)
+}
+
+func TestMirror_FilteredUpdateRemovesMirroredContext(t *testing.T) {
+	ctx := newTestContext(t)
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	te.putAdOnSource(t, ctx, []byte("fish"), random.Multihashes(3), metadata.Default.New(metadata.Bitswap{}))
+	// The update of the mirrored context no longer passes the filter.
+	te.putAdOnSource(t, ctx, []byte("fish"), random.Multihashes(3), metadata.Default.New(metadata.IpfsGatewayHttp{}))
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second),
+		mirror.WithFilter(mirror.Filter{Protocols: []multicodec.Code{multicodec.TransportBitswap}}))
+
+	var head *schema.Advertisement
+	require.Eventually(t, func() bool {
+		headCid, err := te.mirrorSyncer.GetHead(ctx)
+		if err != nil || cid.Undef.Equals(headCid) {
+			return false
+		}
+		head, err = te.syncMirrorAd(ctx, headCid)
+		return err == nil && head.PreviousID != nil
+	}, testEventualTimeout, testCheckInterval)
+
+	// The mirrored chain removes the context instead of advertising its
+	// stale version.
+	require.True(t, head.IsRm)
+	require.Equal(t, []byte("fish"), head.ContextID)
+	require.Equal(t, schema.NoEntries, head.Entries)
+	_, err := head.VerifySignature()
+	require.NoError(t, err)
+
+	prev, err := te.syncMirrorAd(ctx, head.PreviousID.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	require.False(t, prev.IsRm)
+	require.Equal(t, []byte("fish"), prev.ContextID)
+}
+
+func TestMirror_RewritesAddressesAndMetadata(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+	gatewayMd := metadata.Default.New(metadata.IpfsGatewayHttp{})
+	gatewayMdBytes, err := gatewayMd.MarshalBinary()
+	require.NoError(t, err)
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	adCid := te.putAdOnSource(t, ctx, []byte("fish"), random.Multihashes(3), md)
+
+	// Replacements must be valid.
+	_, err = mirror.New(ctx, te.sourceAddrInfo(t), mirror.WithRewrite(mirror.Rewrite{
+		Metadata: []mirror.MetadataRewrite{{Protocol: multicodec.TransportBitswap, Replacement: []byte{0xff}}},
+	}))
+	require.Error(t, err)
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second), mirror.WithRewrite(mirror.Rewrite{
+		Addresses: []mirror.AddrRewrite{
+			{Match: multiaddr.StringCast("/ip4/127.0.0.1"), Replace: multiaddr.StringCast("/dns4/edge.example.com")},
+		},
+		Metadata: []mirror.MetadataRewrite{
+			{Protocol: multicodec.TransportBitswap, Replacement: gatewayMdBytes},
+		},
+	}))
+
+	var gotMirroredHeadCid cid.Cid
+	require.Eventually(t, func() bool {
+		gotMirroredHeadCid, err = te.mirrorSyncer.GetHead(ctx)
+		return err == nil && !cid.Undef.Equals(gotMirroredHeadCid)
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+
+	original, err := te.source.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	mirrored, err := te.syncMirrorAd(ctx, gotMirroredHeadCid)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, original.Addresses)
+	require.Len(t, mirrored.Addresses, len(original.Addresses))
+	for i, addr := range original.Addresses {
+		require.True(t, strings.HasPrefix(addr, "/ip4/127.0.0.1/"))
+		require.Equal(t, "/dns4/edge.example.com/"+strings.TrimPrefix(addr, "/ip4/127.0.0.1/"), mirrored.Addresses[i])
+	}
+	require.Equal(t, gatewayMdBytes, mirrored.Metadata)
+	require.Equal(t, original.ContextID, mirrored.ContextID)
+
+	// Rewritten ads are re-signed by the mirror.
+	signer, err := mirrored.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, te.mirrorHost.ID(), signer)
+	te.requireEntriesMirrored(t, ctx, original.ContextID, original.Entries, mirrored.Entries)
+}
@@ -23,9 +23,12 @@ type (
 	Option  func(*options) error
 	options struct {
 		h                           host.Host
+		closeHost                   bool
 		ds                          datastore.Batching
 		syncInterval                time.Duration
 		httpListenAddr              string
+		httpWithoutServer           bool
+		httpHandlerPath             string
 		initAdRecurLimit            int64
 		entriesRecurLimit           int64
 		chunkerFunc                 chunker.NewChunkerFunc
@@ -36,6 +39,15 @@ type (
 		skipRemapOnEntriesTypeMatch bool
 		entriesRemapPrototype       schema.TypedPrototype
 		alwaysReSignAds             bool
+		failurePolicy               FailurePolicy
+		failureRetries              int
+		failureRetryBackoff         time.Duration
+		forkPolicy                  ForkPolicy
+		forkDetectionDepth          int64
+		syncOnAnnounce              bool
+		announceHTTPListenAddr      string
+		filter                      *Filter
+		rewrite                     *Rewrite
 	}
 )
 
@@ -50,6 +62,10 @@ func newOptions(o ...Option) (*options, error) {
 		chunkCachePurge: false,
 		topic:           "/indexer/ingest/mainnet",
 		syncInterval:    10 * time.Minute,
+
+		failurePolicy:       FailureSkip,
+		failureRetryBackoff: time.Second,
+		forkPolicy:          ForkRemirror,
 	}
 	for _, apply := range o {
 		if err := apply(&opts); err != nil {
@@ -67,6 +83,7 @@ func newOptions(o ...Option) (*options, error) {
 		if opts.h, err = libp2p.New(libp2p.Identity(opts.privKey)); err != nil {
 			return nil, err
 		}
+		opts.closeHost = true
 	} else {
 		peerIDFromPrivKey, err := peer.IDFromPrivateKey(opts.privKey)
 		if err != nil {
@@ -148,6 +165,28 @@ func WithHTTPListenAddr(addr string) Option {
 	}
 }
 
+// WithHTTPPublisherWithoutServer sets the HTTP publisher to not start a
+// server, nor serve over libp2p. Serving the handler returned by
+// Mirror.PublisherHTTPHandler is left to the user, in which case the address
+// set by WithHTTPListenAddr is the address the handler is served at.
+//
+// See: WithHTTPPublisherHandlerPath.
+func WithHTTPPublisherWithoutServer() Option {
+	return func(o *options) error {
+		o.httpWithoutServer = true
+		return nil
+	}
+}
+
+// WithHTTPPublisherHandlerPath sets the path under which the HTTP publisher
+// serves advertisements, before the implicit /ipni/v1/ad/ part of the path.
+func WithHTTPPublisherHandlerPath(handlerPath string) Option {
+	return func(o *options) error {
+		o.httpHandlerPath = handlerPath
+		return nil
+	}
+}
+
 // WithSkipRemapOnEntriesTypeMatch specifies weather to skip remapping entries if the original
 // structure prototype matches the configured remap option.
 // Note that setting this option without setting a remap option has no effect.
@@ -233,3 +272,173 @@ func WithAlwaysReSignAds(r bool) Option {
 		return nil
 	}
 }
+
+// WithFailurePolicy specifies what to do when an advertisement fails to be
+// mirrored after any retries.
+// If unset, the advertisement is skipped and recorded; see FailureSkip.
+//
+// See: WithFailureRetries.
+func WithFailurePolicy(p FailurePolicy) Option {
+	return func(o *options) error {
+		switch p {
+		case FailureSkip, FailureHalt:
+			o.failurePolicy = p
+			return nil
+		default:
+			return fmt.Errorf("unknown failure policy: %s", p)
+		}
+	}
+}
+
+// WithFailureRetries specifies the number of times mirroring an advertisement
+// is retried before the failure policy applies. The wait before each retry
+// starts at backoff and doubles after each retry. Only transient failures are
+// retried; see ErrorCode.Transient.
+// If unset, failures are not retried.
+//
+// See: WithFailurePolicy.
+func WithFailureRetries(retries int, backoff time.Duration) Option {
+	return func(o *options) error {
+		if retries < 0 {
+			return errors.New("failure retries must not be negative")
+		}
+		if backoff <= 0 {
+			return errors.New("failure retry backoff must be positive")
+		}
+		o.failureRetries = retries
+		o.failureRetryBackoff = backoff
+		return nil
+	}
+}
+
+// WithForkPolicy specifies what to do when the source chain forks, i.e. when
+// the head of the source no longer links back to the latest original
+// advertisement processed by the mirror.
+// If unset, the new source chain is mirrored from scratch; see ForkRemirror.
+//
+// See: Mirror.Forks.
+func WithForkPolicy(p ForkPolicy) Option {
+	return func(o *options) error {
+		switch p {
+		case ForkRemirror, ForkHalt:
+			o.forkPolicy = p
+			return nil
+		default:
+			return fmt.Errorf("unknown fork policy: %s", p)
+		}
+	}
+}
+
+// WithForkDetectionDepth specifies the number of advertisements synced from
+// the source without linking back to the latest original advertisement
+// processed by the mirror, after which the source chain is handled as forked.
+// If unset, the source chain is only handled as forked once the mirror syncs
+// its first advertisement without finding the latest original one.
+//
+// See: ForkPolicy, WithInitialAdRecursionLimit.
+func WithForkDetectionDepth(depth int64) Option {
+	return func(o *options) error {
+		if depth < 0 {
+			return errors.New("fork detection depth must not be negative")
+		}
+		o.forkDetectionDepth = depth
+		return nil
+	}
+}
+
+// WithSyncOnAnnounce specifies whether to sync with the source as soon as it
+// announces a new advertisement over gossipsub on the topic, in addition to
+// checking at the sync interval.
+//
+// See: WithTopicName, WithSyncInterval.
+func WithSyncOnAnnounce(s bool) Option {
+	return func(o *options) error {
+		o.syncOnAnnounce = s
+		return nil
+	}
+}
+
+// WithAnnounceHTTPListenAddr sets the HTTP address:port on which the mirror
+// receives announcements at the path /announce. An announcement from the
+// source triggers a sync with the source, in addition to checking at the sync
+// interval.
+// If unset, announcements are not received over HTTP.
+//
+// See: WithSyncInterval.
+func WithAnnounceHTTPListenAddr(addr string) Option {
+	return func(o *options) error {
+		o.announceHTTPListenAddr = addr
+		return nil
+	}
+}
+
+// WithFilter specifies which advertisements of the source chain are mirrored.
+// If unset, all advertisements are mirrored.
+//
+// See: Filter.
+func WithFilter(f Filter) Option {
+	return func(o *options) error {
+		o.filter = &f
+		return nil
+	}
+}
+
+// WithRewrite specifies how the addresses and metadata of mirrored
+// advertisements are rewritten. Rewritten advertisements are re-signed with
+// the mirror identity.
+// If unset, addresses and metadata are mirrored without change.
+//
+// See: Rewrite.
+func WithRewrite(r Rewrite) Option {
+	return func(o *options) error {
+		if err := r.validate(); err != nil {
+			return err
+		}
+		o.rewrite = &r
+		return nil
+	}
+}
+
+type (
+	// ManagerOption configures a Manager.
+	ManagerOption  func(*managerOptions) error
+	managerOptions struct {
+		httpListenAddr string
+		mirrorOpts     []Option
+	}
+)
+
+func newManagerOptions(o ...ManagerOption) (*managerOptions, error) {
+	opts := managerOptions{
+		httpListenAddr: "127.0.0.1:0",
+	}
+	for _, apply := range o {
+		if err := apply(&opts); err != nil {
+			return nil, err
+		}
+	}
+	return &opts, nil
+}
+
+// WithManagerHTTPListenAddr sets the HTTP address:port on which the manager
+// serves the advertisements of all mirrors, under the path of their source
+// peer ID, and receives announcements from all sources at the path /announce.
+// If unset, a random port on localhost is used.
+func WithManagerHTTPListenAddr(addr string) ManagerOption {
+	return func(o *managerOptions) error {
+		o.httpListenAddr = addr
+		return nil
+	}
+}
+
+// WithMirrorOptions sets the options applied to every mirror run by the
+// manager, before the options given when adding a source. Options that set
+// the datastore or the HTTP publisher are overridden by the manager.
+//
+// See: Manager.Add.
+func WithMirrorOptions(o ...Option) ManagerOption {
+	return func(mo *managerOptions) error {
+		mo.mirrorOpts = append(mo.mirrorOpts, o...)
+		return nil
+	}
+}
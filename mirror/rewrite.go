@@ -0,0 +1,199 @@
+package mirror
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+)
+
+// Rewrite declares how the mirror rewrites the addresses and metadata of the
+// advertisements it mirrors, for example to point retrievals at edge
+// endpoints. Rewritten advertisements are re-signed with the mirror identity.
+//
+// See: WithRewrite.
+type Rewrite struct {
+	// Addresses are the rules that rewrite each advertised address. The first
+	// rule that matches an address applies, and addresses that no rule
+	// matches are left unchanged.
+	Addresses []AddrRewrite
+	// Metadata are the rules that rewrite the metadata of transport
+	// protocols.
+	Metadata []MetadataRewrite
+}
+
+// AddrRewrite rewrites advertised addresses that start with a multiaddr
+// prefix.
+type AddrRewrite struct {
+	// Match is the prefix of the addresses to rewrite. If nil, all addresses
+	// match.
+	Match multiaddr.Multiaddr
+	// Replace replaces the matched prefix; the rest of the address is kept.
+	// For example, matching "/ip4/10.0.0.1/tcp/8080" and replacing it with
+	// "/dns4/edge.example.com/tcp/443/https" rewrites
+	// "/ip4/10.0.0.1/tcp/8080/http" to
+	// "/dns4/edge.example.com/tcp/443/https/http".
+	Replace multiaddr.Multiaddr
+}
+
+// MetadataRewrite replaces the metadata of a transport protocol.
+type MetadataRewrite struct {
+	// Protocol is the transport protocol whose metadata is replaced.
+	Protocol multicodec.Code
+	// Replacement is the encoded metadata of the protocols that replace
+	// Protocol, for example that of metadata.IpfsGatewayHttp. If empty, the
+	// protocol is removed. Protocols already present are not added again.
+	Replacement []byte
+}
+
+func (r *Rewrite) validate() error {
+	for _, rule := range r.Addresses {
+		if rule.Replace == nil {
+			return errors.New("address rewrite must have a replacement")
+		}
+	}
+	for _, rule := range r.Metadata {
+		if len(rule.Replacement) == 0 {
+			continue
+		}
+		md := metadata.Default.New()
+		if err := md.UnmarshalBinary(rule.Replacement); err != nil {
+			return fmt.Errorf("bad replacement metadata for protocol %s: %w", rule.Protocol, err)
+		}
+	}
+	return nil
+}
+
+// apply rewrites the addresses and metadata of the ad, and returns whether
+// the ad is changed.
+func (r *Rewrite) apply(ad *schema.Advertisement) (bool, error) {
+	addrsChanged, err := r.rewriteAddrs(ad)
+	if err != nil {
+		return false, err
+	}
+	mdChanged, err := r.rewriteMetadata(ad)
+	if err != nil {
+		return false, err
+	}
+	return addrsChanged || mdChanged, nil
+}
+
+func (r *Rewrite) rewriteAddrs(ad *schema.Advertisement) (bool, error) {
+	if len(r.Addresses) == 0 || len(ad.Addresses) == 0 {
+		return false, nil
+	}
+	var changed bool
+	rewritten := make([]string, 0, len(ad.Addresses))
+	seen := make(map[string]struct{}, len(ad.Addresses))
+	for _, s := range ad.Addresses {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return false, fmt.Errorf("bad advertised address %q: %w", s, err)
+		}
+		for _, rule := range r.Addresses {
+			var match []byte
+			if rule.Match != nil {
+				match = rule.Match.Bytes()
+			}
+			// Multiaddr components are self-delimiting, so a byte prefix is
+			// a component prefix.
+			if !bytes.HasPrefix(addr.Bytes(), match) {
+				continue
+			}
+			rest := addr.Bytes()[len(match):]
+			addr, err = multiaddr.NewMultiaddrBytes(append(append([]byte{}, rule.Replace.Bytes()...), rest...))
+			if err != nil {
+				return false, fmt.Errorf("cannot rewrite address %q: %w", s, err)
+			}
+			break
+		}
+		if addr.String() != s {
+			changed = true
+		}
+		// Rewriting may map different addresses to the same one.
+		if _, ok := seen[addr.String()]; ok {
+			changed = true
+			continue
+		}
+		seen[addr.String()] = struct{}{}
+		rewritten = append(rewritten, addr.String())
+	}
+	if changed {
+		ad.Addresses = rewritten
+	}
+	return changed, nil
+}
+
+func (r *Rewrite) rewriteMetadata(ad *schema.Advertisement) (bool, error) {
+	if len(r.Metadata) == 0 || len(ad.Metadata) == 0 {
+		return false, nil
+	}
+	md := metadata.Default.New()
+	if err := md.UnmarshalBinary(ad.Metadata); err != nil {
+		return false, fmt.Errorf("cannot decode metadata to rewrite: %w", err)
+	}
+
+	var changed bool
+	var protocols []metadata.Protocol
+	have := make(map[multicodec.Code]struct{})
+	seen := make(map[multicodec.Code]struct{})
+	var replacements []metadata.Protocol
+	for _, code := range md.Protocols() {
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		rule, ok := r.metadataRule(code)
+		if !ok {
+			have[code] = struct{}{}
+			protocols = append(protocols, md.Get(code))
+			continue
+		}
+		changed = true
+		if len(rule.Replacement) == 0 {
+			continue
+		}
+		replacement := metadata.Default.New()
+		if err := replacement.UnmarshalBinary(rule.Replacement); err != nil {
+			return false, err
+		}
+		for _, c := range replacement.Protocols() {
+			replacements = append(replacements, replacement.Get(c))
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	for _, p := range replacements {
+		if _, ok := have[p.ID()]; !ok {
+			have[p.ID()] = struct{}{}
+			protocols = append(protocols, p)
+		}
+	}
+	if len(protocols) == 0 {
+		return false, errors.New("rewriting metadata removes all protocols")
+	}
+	rewritten := metadata.Default.New(protocols...)
+	mdBytes, err := rewritten.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(mdBytes, ad.Metadata) {
+		return false, nil
+	}
+	ad.Metadata = mdBytes
+	return true, nil
+}
+
+func (r *Rewrite) metadataRule(code multicodec.Code) (MetadataRewrite, bool) {
+	for _, rule := range r.Metadata {
+		if rule.Protocol == code {
+			return rule, true
+		}
+	}
+	return MetadataRewrite{}, false
+}
@@ -0,0 +1,94 @@
+package mirror
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite_Addresses(t *testing.T) {
+	subject := Rewrite{
+		Addresses: []AddrRewrite{
+			{Match: multiaddr.StringCast("/ip4/10.0.0.1/tcp/8080"), Replace: multiaddr.StringCast("/dns4/edge.example.com/tcp/443/https")},
+			{Match: multiaddr.StringCast("/ip4/10.0.0.2"), Replace: multiaddr.StringCast("/dns4/edge.example.com")},
+		},
+	}
+	ad := &schema.Advertisement{Addresses: []string{
+		"/ip4/10.0.0.1/tcp/8080/http",
+		// Not a component prefix of the first rule.
+		"/ip4/10.0.0.1/tcp/80",
+		"/ip4/10.0.0.2/tcp/443/https",
+		// Rewritten to the same address as the previous one.
+		"/dns4/edge.example.com/tcp/443/https",
+	}}
+	changed, err := subject.apply(ad)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{
+		"/dns4/edge.example.com/tcp/443/https/http",
+		"/ip4/10.0.0.1/tcp/80",
+		"/dns4/edge.example.com/tcp/443/https",
+	}, ad.Addresses)
+
+	// Addresses that match no rule are unchanged.
+	ad = &schema.Advertisement{Addresses: []string{"/ip4/10.0.0.3/tcp/80"}}
+	changed, err = subject.apply(ad)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// A rule without match rewrites all addresses.
+	subject = Rewrite{Addresses: []AddrRewrite{{Replace: multiaddr.StringCast("/dns4/edge.example.com/tcp/443/https")}}}
+	ad = &schema.Advertisement{Addresses: []string{"/ip4/10.0.0.3/tcp/80"}}
+	changed, err = subject.apply(ad)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"/dns4/edge.example.com/tcp/443/https/ip4/10.0.0.3/tcp/80"}, ad.Addresses)
+}
+
+func TestRewrite_Metadata(t *testing.T) {
+	encode := func(p ...metadata.Protocol) []byte {
+		md := metadata.Default.New(p...)
+		b, err := md.MarshalBinary()
+		require.NoError(t, err)
+		return b
+	}
+	gsMd := &metadata.GraphsyncFilecoinV1{PieceCID: random.Cids(1)[0]}
+
+	subject := Rewrite{Metadata: []MetadataRewrite{
+		{Protocol: multicodec.TransportBitswap, Replacement: encode(&metadata.IpfsGatewayHttp{})},
+		{Protocol: multicodec.TransportGraphsyncFilecoinv1},
+	}}
+	require.NoError(t, subject.validate())
+
+	ad := &schema.Advertisement{Metadata: encode(&metadata.Bitswap{}, gsMd)}
+	changed, err := subject.apply(ad)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, encode(&metadata.IpfsGatewayHttp{}), ad.Metadata)
+
+	// Replacements already present are not added again.
+	ad = &schema.Advertisement{Metadata: encode(&metadata.Bitswap{}, &metadata.IpfsGatewayHttp{})}
+	changed, err = subject.apply(ad)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, encode(&metadata.IpfsGatewayHttp{}), ad.Metadata)
+
+	// Protocols without rules are unchanged.
+	ad = &schema.Advertisement{Metadata: encode(&metadata.IpfsGatewayHttp{})}
+	changed, err = subject.apply(ad)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// Removing all protocols is an error.
+	ad = &schema.Advertisement{Metadata: encode(gsMd)}
+	_, err = subject.apply(ad)
+	require.Error(t, err)
+
+	subject.Metadata[0].Replacement = []byte{0xff}
+	require.Error(t, subject.validate())
+}
@@ -0,0 +1,261 @@
+package mirror
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// maxAdErrors is the maximum number of ads whose last error is kept in memory
+// to report in the status of the mirror.
+const maxAdErrors = 100
+
+// Status is the progress of a mirror.
+type Status struct {
+	// Source is the ID of the mirrored provider.
+	Source peer.ID
+	// SourceHead is the head of the source chain as of the last successful
+	// sync with the source, or cid.Undef if the mirror has not synced with
+	// it yet.
+	SourceHead cid.Cid
+	// LastSync is the time of the last successful sync with the source.
+	LastSync time.Time `json:",omitempty"`
+	// LatestOriginalAd is the CID of the latest original ad processed by the
+	// mirror, whether mirrored, filtered out or skipped.
+	LatestOriginalAd cid.Cid
+	// LatestMirroredAd is the CID of the head of the mirrored chain.
+	LatestMirroredAd cid.Cid
+	// Backlog is the number of ads synced from the source that are yet to be
+	// processed, i.e. the lag of the mirror in ads.
+	Backlog int
+	// LagSeconds is the number of seconds the mirror has been behind the
+	// source, or zero if the backlog is empty.
+	LagSeconds int64
+	// Fork is the fork of the source chain at which mirroring is halted, or
+	// nil if mirroring is not halted.
+	//
+	// See: ForkHalt.
+	Fork *ForkEvent `json:",omitempty"`
+	// AdErrors are the last errors of the ads that failed to be mirrored and
+	// were not mirrored since, most recent first.
+	AdErrors []AdError `json:",omitempty"`
+	// RemapCache is the usage of the remapped entries cache, or nil if
+	// entries are not remapped.
+	RemapCache *RemapCacheStatus `json:",omitempty"`
+	// EntriesBytes is the number of bytes of entries synced from the source
+	// and stored by the mirror.
+	EntriesBytes uint64
+}
+
+// AdError is the last error to mirror an advertisement.
+type AdError struct {
+	// AdCid is the CID of the original advertisement.
+	AdCid cid.Cid
+	// Code categorizes the cause of the failure.
+	Code ErrorCode
+	// Error is the message of the failure.
+	Error string
+	// Attempts is the number of times mirroring the ad failed.
+	Attempts int
+	// Time is when the last attempt failed.
+	Time time.Time
+}
+
+// RemapCacheStatus is the usage of the remapped entries cache.
+//
+// See: WithRemappedEntriesCacheCapacity.
+type RemapCacheStatus struct {
+	// Len is the number of entries DAGs cached.
+	Len int
+	// Cap is the maximum number of entries DAGs cached.
+	Cap int
+}
+
+// progress tracks the progress of the mirror that is not persisted.
+type progress struct {
+	lock        sync.Mutex
+	sourceHead  cid.Cid
+	lastSync    time.Time
+	backlog     int
+	behindSince time.Time
+	haltedFork  *ForkEvent
+	adErrors    map[cid.Cid]*AdError
+}
+
+// Status returns the progress of the mirror.
+func (m *Mirror) Status(ctx context.Context) (*Status, error) {
+	latestOriginal, err := m.getLatestOriginalAdCid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latestMirrored, err := m.getLatestMirroredAdCid(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entriesBytes, err := m.getEntriesBytes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &Status{
+		Source:           m.source.ID,
+		LatestOriginalAd: latestOriginal,
+		LatestMirroredAd: latestMirrored,
+		EntriesBytes:     entriesBytes,
+	}
+	if m.remapEntriesEnabled() {
+		s.RemapCache = &RemapCacheStatus{
+			Len: m.chunker.Len(),
+			Cap: m.chunker.Cap(),
+		}
+	}
+
+	m.progress.lock.Lock()
+	defer m.progress.lock.Unlock()
+	s.SourceHead = m.progress.sourceHead
+	s.LastSync = m.progress.lastSync
+	s.Backlog = m.progress.backlog
+	s.Fork = m.progress.haltedFork
+	s.LagSeconds = int64(m.lagLocked().Seconds())
+	for _, adErr := range m.progress.adErrors {
+		s.AdErrors = append(s.AdErrors, *adErr)
+	}
+	sort.Slice(s.AdErrors, func(i, j int) bool {
+		return s.AdErrors[i].Time.After(s.AdErrors[j].Time)
+	})
+	return s, nil
+}
+
+// StatusHandler returns the http.Handler that serves the status of the mirror
+// as JSON at GET /status.
+//
+// See: Mirror.Status.
+func (m *Mirror) StatusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		s, err := m.Status(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, s)
+	})
+	return mux
+}
+
+// setSynced records the head of the source, the number of ads synced from it
+// that are yet to be processed, and the fork at which mirroring is halted, if
+// any.
+func (m *Mirror) setSynced(ctx context.Context, head cid.Cid, syncStart time.Time, backlog int, haltedFork *ForkEvent) {
+	m.progress.lock.Lock()
+	defer m.progress.lock.Unlock()
+	if head != cid.Undef {
+		m.progress.sourceHead = head
+	}
+	m.progress.lastSync = syncStart
+	m.progress.backlog = backlog
+	m.progress.haltedFork = haltedFork
+	if backlog == 0 {
+		m.progress.behindSince = time.Time{}
+	} else if m.progress.behindSince.IsZero() {
+		m.progress.behindSince = syncStart
+	}
+	m.recordLagLocked(ctx)
+}
+
+// setProcessed records that an ad synced from the source is processed.
+func (m *Mirror) setProcessed(ctx context.Context) {
+	m.progress.lock.Lock()
+	defer m.progress.lock.Unlock()
+	if m.progress.backlog > 0 {
+		m.progress.backlog--
+	}
+	if m.progress.backlog == 0 {
+		m.progress.behindSince = time.Time{}
+	}
+	m.recordLagLocked(ctx)
+}
+
+func (m *Mirror) lagLocked() time.Duration {
+	if m.progress.behindSince.IsZero() {
+		return 0
+	}
+	return time.Since(m.progress.behindSince)
+}
+
+func (m *Mirror) recordLagLocked(ctx context.Context) {
+	attrs := metric.WithAttributes(m.sourceAttr())
+	metrics.Mirror.LagAds.Record(ctx, int64(m.progress.backlog), attrs)
+	metrics.Mirror.LagSeconds.Record(ctx, int64(m.lagLocked().Seconds()), attrs)
+}
+
+// setAdError records the error of an attempt to mirror an ad.
+func (m *Mirror) setAdError(adCid cid.Cid, err error) {
+	m.progress.lock.Lock()
+	defer m.progress.lock.Unlock()
+	if m.progress.adErrors == nil {
+		m.progress.adErrors = make(map[cid.Cid]*AdError)
+	}
+	adErr, ok := m.progress.adErrors[adCid]
+	if !ok {
+		if len(m.progress.adErrors) >= maxAdErrors {
+			m.evictOldestAdErrorLocked()
+		}
+		adErr = &AdError{AdCid: adCid}
+		m.progress.adErrors[adCid] = adErr
+	}
+	adErr.Code = errorCode(err)
+	adErr.Error = err.Error()
+	adErr.Attempts++
+	adErr.Time = time.Now()
+}
+
+// clearAdError forgets the errors of an ad that is mirrored.
+func (m *Mirror) clearAdError(adCid cid.Cid) {
+	m.progress.lock.Lock()
+	defer m.progress.lock.Unlock()
+	delete(m.progress.adErrors, adCid)
+}
+
+func (m *Mirror) evictOldestAdErrorLocked() {
+	var oldest *AdError
+	for _, adErr := range m.progress.adErrors {
+		if oldest == nil || adErr.Time.Before(oldest.Time) {
+			oldest = adErr
+		}
+	}
+	if oldest != nil {
+		delete(m.progress.adErrors, oldest.AdCid)
+	}
+}
+
+// entriesBytesCounter counts the bytes of entries blocks written by the link
+// system, when set in the context of the write.
+type entriesBytesCounter struct {
+	lock sync.Mutex
+	n    uint64
+}
+
+type entriesBytesCounterKey struct{}
+
+func withEntriesBytesCounter(ctx context.Context) (context.Context, *entriesBytesCounter) {
+	c := &entriesBytesCounter{}
+	return context.WithValue(ctx, entriesBytesCounterKey{}, c), c
+}
+
+func countEntriesBytes(ctx context.Context, n int) {
+	if ctx == nil {
+		return
+	}
+	if c, ok := ctx.Value(entriesBytesCounterKey{}).(*entriesBytesCounter); ok {
+		c.lock.Lock()
+		c.n += uint64(n)
+		c.lock.Unlock()
+	}
+}
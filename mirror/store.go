@@ -2,10 +2,16 @@ package mirror
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	hamt "github.com/ipld/go-ipld-adl-hamt"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
@@ -19,6 +25,12 @@ import (
 var (
 	latestMirroredAdCidKey = datastore.NewKey("latest-mirrored-ad-cid")
 	latestOriginalAdCidKey = datastore.NewKey("latest-original-ad-cid")
+	skippedAdsKeyPrefix    = datastore.NewKey("skipped-ads")
+	entriesBytesKey        = datastore.NewKey("entries-bytes")
+	forksKeyPrefix         = datastore.NewKey("forks")
+	// mirroredContextsKeyPrefix holds the context IDs of each provider that
+	// are advertised by the mirrored chain.
+	mirroredContextsKeyPrefix = datastore.NewKey("mirrored-contexts")
 )
 
 func (m *Mirror) getLatestOriginalAdCid(ctx context.Context) (cid.Cid, error) {
@@ -59,6 +71,155 @@ func (m *Mirror) setLatestMirroredAdCid(ctx context.Context, c cid.Cid) error {
 	return m.ds.Put(ctx, latestMirroredAdCidKey, c.Bytes())
 }
 
+func (m *Mirror) addSkippedAd(ctx context.Context, skipped SkippedAd) error {
+	v, err := json.Marshal(&skipped)
+	if err != nil {
+		return err
+	}
+	return m.ds.Put(ctx, skippedAdsKeyPrefix.ChildString(skipped.AdCid.String()), v)
+}
+
+// SkippedAds lists the advertisements skipped by the mirror because they failed
+// to be mirrored, with the cause of each failure.
+//
+// See: FailureSkip.
+func (m *Mirror) SkippedAds(ctx context.Context) ([]SkippedAd, error) {
+	results, err := m.ds.Query(ctx, query.Query{Prefix: skippedAdsKeyPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var skipped []SkippedAd
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var s SkippedAd
+		if err := json.Unmarshal(r.Value, &s); err != nil {
+			return nil, fmt.Errorf("cannot decode skipped ad %s: %w", r.Key, err)
+		}
+		skipped = append(skipped, s)
+	}
+	return skipped, nil
+}
+
+// resetMirroredChain forgets the latest original and mirrored ads, so that the
+// next ads are mirrored as if the mirror had never synced with the source.
+func (m *Mirror) resetMirroredChain(ctx context.Context) error {
+	if err := m.ds.Delete(ctx, latestOriginalAdCidKey); err != nil {
+		return err
+	}
+	if err := m.ds.Delete(ctx, latestMirroredAdCidKey); err != nil {
+		return err
+	}
+	results, err := m.ds.Query(ctx, query.Query{Prefix: mirroredContextsKeyPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err = m.ds.Delete(ctx, datastore.NewKey(e.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isContextMirrored returns whether the context ID of the ad is advertised by
+// the mirrored chain.
+func (m *Mirror) isContextMirrored(ctx context.Context, ad *stischema.Advertisement) (bool, error) {
+	if len(ad.ContextID) == 0 {
+		return false, nil
+	}
+	return m.ds.Has(ctx, mirroredContextKey(ad))
+}
+
+// trackMirroredContext records whether the context ID of a mirrored ad is
+// advertised by the mirrored chain from now on.
+func (m *Mirror) trackMirroredContext(ctx context.Context, ad *stischema.Advertisement) error {
+	if len(ad.ContextID) == 0 {
+		return nil
+	}
+	if ad.IsRm {
+		return m.ds.Delete(ctx, mirroredContextKey(ad))
+	}
+	return m.ds.Put(ctx, mirroredContextKey(ad), []byte{})
+}
+
+func mirroredContextKey(ad *stischema.Advertisement) datastore.Key {
+	return mirroredContextsKeyPrefix.ChildString(ad.Provider).ChildString(base64.RawURLEncoding.EncodeToString(ad.ContextID))
+}
+
+// addFork records a fork of the source chain, unless a fork from the same
+// latest original ad is already recorded, and returns whether it is recorded.
+func (m *Mirror) addFork(ctx context.Context, fork ForkEvent) (bool, error) {
+	key := forksKeyPrefix.ChildString(fork.LatestOriginalAd.String())
+	exists, err := m.ds.Has(ctx, key)
+	if err != nil || exists {
+		return false, err
+	}
+	v, err := json.Marshal(&fork)
+	if err != nil {
+		return false, err
+	}
+	return true, m.ds.Put(ctx, key, v)
+}
+
+// Forks lists the forks of the source chain detected by the mirror, oldest
+// first.
+//
+// See: ForkPolicy.
+func (m *Mirror) Forks(ctx context.Context) ([]ForkEvent, error) {
+	results, err := m.ds.Query(ctx, query.Query{Prefix: forksKeyPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var forks []ForkEvent
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var f ForkEvent
+		if err := json.Unmarshal(r.Value, &f); err != nil {
+			return nil, fmt.Errorf("cannot decode fork %s: %w", r.Key, err)
+		}
+		forks = append(forks, f)
+	}
+	sort.Slice(forks, func(i, j int) bool { return forks[i].Time.Before(forks[j].Time) })
+	return forks, nil
+}
+
+func (m *Mirror) getEntriesBytes(ctx context.Context) (uint64, error) {
+	v, err := m.ds.Get(ctx, entriesBytesKey)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, errors.New("bad stored entries bytes")
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func (m *Mirror) addEntriesBytes(ctx context.Context, n uint64) error {
+	if n == 0 {
+		return nil
+	}
+	total, err := m.getEntriesBytes(ctx)
+	if err != nil {
+		return err
+	}
+	return m.ds.Put(ctx, entriesBytesKey, binary.BigEndian.AppendUint64(nil, total+n))
+}
+
 func (m *Mirror) loadAd(ctx context.Context, c cid.Cid) (*stischema.Advertisement, error) {
 	an, err := m.ls.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, stischema.AdvertisementPrototype)
 	if err != nil {
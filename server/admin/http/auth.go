@@ -0,0 +1,69 @@
+package adminserver
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// bearerToken is a token accepted by the admin server.
+type bearerToken struct {
+	value    []byte
+	readOnly bool
+}
+
+// authorizer checks the bearer token of requests against the tokens
+// configured with WithBearerToken.
+type authorizer struct {
+	tokens []bearerToken
+}
+
+// readOnly wraps a handler of an endpoint that does not change the state of
+// the provider, which any configured token may access.
+func (a *authorizer) readOnly(h http.HandlerFunc) http.HandlerFunc {
+	return a.wrap(h, false)
+}
+
+// mutating wraps a handler of an endpoint that changes the state of the
+// provider, which only tokens that are not read-only may access.
+func (a *authorizer) mutating(h http.HandlerFunc) http.HandlerFunc {
+	return a.wrap(h, true)
+}
+
+func (a *authorizer) wrap(h http.HandlerFunc, mutating bool) http.HandlerFunc {
+	if len(a.tokens) == 0 {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := a.find(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+		if mutating && token.readOnly {
+			http.Error(w, "token is read-only", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// find returns the configured token sent in the Authorization header of r.
+func (a *authorizer) find(r *http.Request) (bearerToken, bool) {
+	value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || value == "" {
+		return bearerToken{}, false
+	}
+	// Compare with every token in constant time, so that the time to respond
+	// reveals nothing about the tokens.
+	var found bearerToken
+	var matched bool
+	for _, token := range a.tokens {
+		if subtle.ConstantTimeCompare(token.value, []byte(value)) == 1 {
+			found = token
+			matched = true
+		}
+	}
+	return found, matched
+}
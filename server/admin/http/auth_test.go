@@ -0,0 +1,52 @@
+package adminserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authorizer(t *testing.T) {
+	opts, err := newOptions(
+		WithBearerToken("fish", false),
+		WithBearerToken("lobster", true),
+	)
+	require.NoError(t, err)
+	subject := &authorizer{opts.tokens}
+
+	ok := func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	serve := func(h http.HandlerFunc, authz string) int {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/admin/list/car", nil)
+		require.NoError(t, err)
+		if authz != "" {
+			req.Header.Set("Authorization", authz)
+		}
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	readOnly := subject.readOnly(ok)
+	require.Equal(t, http.StatusOK, serve(readOnly, "Bearer fish"))
+	require.Equal(t, http.StatusOK, serve(readOnly, "Bearer lobster"))
+	require.Equal(t, http.StatusUnauthorized, serve(readOnly, ""))
+	require.Equal(t, http.StatusUnauthorized, serve(readOnly, "Bearer "))
+	require.Equal(t, http.StatusUnauthorized, serve(readOnly, "Bearer crab"))
+	require.Equal(t, http.StatusUnauthorized, serve(readOnly, "fish"))
+
+	mutating := subject.mutating(ok)
+	require.Equal(t, http.StatusOK, serve(mutating, "Bearer fish"))
+	require.Equal(t, http.StatusForbidden, serve(mutating, "Bearer lobster"))
+	require.Equal(t, http.StatusUnauthorized, serve(mutating, "Bearer crab"))
+
+	// Without tokens, requests are not authenticated.
+	noAuth := &authorizer{}
+	require.Equal(t, http.StatusOK, serve(noAuth.mutating(ok), ""))
+
+	_, err = newOptions(WithBearerToken("", false))
+	require.Error(t, err)
+}
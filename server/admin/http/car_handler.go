@@ -14,7 +14,8 @@ import (
 )
 
 type carHandler struct {
-	cs *supplier.CarSupplier
+	cs                 *supplier.CarSupplier
+	retrievalProtocols []metadata.Protocol
 }
 
 func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +49,8 @@ func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	md = withProtocols(md, h.retrievalProtocols)
+
 	log.Info("importing CAR")
 	advID, err = h.cs.Put(ctx, req.Key, req.Path, md)
 
@@ -138,3 +141,21 @@ func (h *carHandler) handleList(w http.ResponseWriter, r *http.Request) {
 	}
 	respond(w, http.StatusOK, resp)
 }
+
+// withProtocols returns metadata with the protocols of md, plus each of
+// protocols not already in md.
+func withProtocols(md metadata.Metadata, protocols []metadata.Protocol) metadata.Metadata {
+	if len(protocols) == 0 {
+		return md
+	}
+	all := make([]metadata.Protocol, 0, md.Len()+len(protocols))
+	for _, code := range md.Protocols() {
+		all = append(all, md.Get(code))
+	}
+	for _, p := range protocols {
+		if md.Get(p.ID()) == nil {
+			all = append(all, p)
+		}
+	}
+	return metadata.Default.New(all...)
+}
@@ -54,7 +54,7 @@ func Test_importCarHandler(t *testing.T) {
 
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -81,6 +81,18 @@ func Test_importCarHandler(t *testing.T) {
 	require.Equal(t, wantCid, resp.AdvId)
 }
 
+func Test_withProtocols(t *testing.T) {
+	tp, err := cardatatransfer.TransportFromContextID([]byte("lobster"))
+	require.NoError(t, err)
+	md := metadata.Default.New(tp)
+
+	require.Equal(t, md, withProtocols(md, nil))
+
+	got := withProtocols(md, []metadata.Protocol{&metadata.IpfsGatewayHttp{}, tp})
+	require.True(t, got.Equal(metadata.Default.New(tp, &metadata.IpfsGatewayHttp{})))
+	require.Equal(t, 1, md.Len(), "metadata must not be modified")
+}
+
 func Test_importCarHandlerFail(t *testing.T) {
 	wantKey := []byte("lobster")
 	wantTp, err := cardatatransfer.TransportFromContextID(wantKey)
@@ -106,7 +118,7 @@ func Test_importCarHandlerFail(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -150,7 +162,7 @@ func Test_importCarAlreadyAdvertised(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -179,7 +191,7 @@ func Test_removeCarHandler(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -214,7 +226,7 @@ func Test_removeCarHandlerFail(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -244,7 +256,7 @@ func Test_removeCarHandler_NonExistingCarIsNotFound(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -262,7 +274,7 @@ func Test_removeCarHandler_UnspecifiedKeyIsBadRequest(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -280,7 +292,7 @@ func Test_removeCarHandler_InvalidJsonIsBadRequest(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -332,7 +344,7 @@ func Test_ListCarHandler(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	req, err := http.NewRequest(http.MethodGet, "/admin/list/car", nil)
 	require.NoError(t, err)
@@ -0,0 +1,75 @@
+package adminserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/multiformats/go-multihash"
+)
+
+type findHandler struct {
+	e  *engine.Engine
+	cs *supplier.CarSupplier
+}
+
+// handleFind lists the context IDs that advertise the multihash given by the
+// "multihash" query parameter, or the multihash of the "cid" query parameter.
+func (h *findHandler) handleFind(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	var mh multihash.Multihash
+	var err error
+	query := r.URL.Query()
+	switch {
+	case query.Get("cid") != "":
+		var c cid.Cid
+		c, err = cid.Decode(query.Get("cid"))
+		mh = c.Hash()
+	case query.Get("multihash") != "":
+		mh, err = multihash.FromB58String(query.Get("multihash"))
+	default:
+		http.Error(w, "cid or multihash must be specified", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad cid or multihash: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	refs, err := h.e.FindContexts(r.Context(), mh)
+	if err != nil {
+		if errors.Is(err, engine.ErrNoReverseIndex) {
+			http.Error(w, "reverse index is not enabled; set Ingest.ReverseIndex in the config", http.StatusNotImplemented)
+			return
+		}
+		err = fmt.Errorf("failed to find context ids: %w", err)
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := &FindRes{
+		Contexts: []FoundContext{},
+	}
+	for _, ref := range refs {
+		found := FoundContext{
+			Provider:  ref.Provider.String(),
+			ContextID: ref.ContextID,
+		}
+		if h.cs != nil {
+			path, err := h.cs.Path(r.Context(), ref.ContextID)
+			if err != nil && !errors.Is(err, supplier.ErrNotFound) {
+				log.Errorw("Failed to get CAR path", "err", err)
+			}
+			found.Path = path
+		}
+		resp.Contexts = append(resp.Contexts, found)
+	}
+	respond(w, http.StatusOK, resp)
+}
@@ -0,0 +1,77 @@
+package adminserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipld/go-car/v2/blockstore"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eng, err := engine.New(engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	carPath, err := filepath.Abs("../../../testdata/sample-v1.car")
+	require.NoError(t, err)
+	contextID := []byte("fish")
+	_, err = cs.Put(ctx, contextID, carPath, metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	bs, err := blockstore.OpenReadOnly(carPath)
+	require.NoError(t, err)
+	keys, err := bs.AllKeysChan(ctx)
+	require.NoError(t, err)
+	var cids []cid.Cid
+	for c := range keys {
+		cids = append(cids, c)
+	}
+	require.NoError(t, bs.Close())
+	c := cids[len(cids)-1]
+
+	subject := &findHandler{eng, cs}
+	find := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/admin/find?"+query, nil)
+		require.NoError(t, err)
+		http.HandlerFunc(subject.handleFind).ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, query := range []string{"cid=" + c.String(), "multihash=" + c.Hash().B58String()} {
+		rr := find(query)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var res FindRes
+		_, err = res.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		require.Len(t, res.Contexts, 1)
+		require.Equal(t, contextID, res.Contexts[0].ContextID)
+		require.Equal(t, carPath, res.Contexts[0].Path)
+	}
+
+	rr := find("cid=" + random.Cids(1)[0].String())
+	require.Equal(t, http.StatusOK, rr.Code)
+	var res FindRes
+	_, err = res.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Empty(t, res.Contexts)
+
+	require.Equal(t, http.StatusBadRequest, find("").Code)
+	require.Equal(t, http.StatusBadRequest, find("cid=fish").Code)
+}
@@ -13,6 +13,11 @@ var (
 	_ io.ReaderFrom = (*RemoveCarRes)(nil)
 	_ io.ReaderFrom = (*ConnectReq)(nil)
 	_ io.ReaderFrom = (*ConnectRes)(nil)
+	_ io.ReaderFrom = (*ListXProvidersRes)(nil)
+	_ io.ReaderFrom = (*AddXProvidersReq)(nil)
+	_ io.ReaderFrom = (*RemoveXProvidersReq)(nil)
+	_ io.ReaderFrom = (*XProvidersRes)(nil)
+	_ io.ReaderFrom = (*FindRes)(nil)
 
 	_ io.WriterTo = (*ImportCarReq)(nil)
 	_ io.WriterTo = (*ImportCarRes)(nil)
@@ -20,6 +25,11 @@ var (
 	_ io.WriterTo = (*RemoveCarRes)(nil)
 	_ io.WriterTo = (*ConnectReq)(nil)
 	_ io.WriterTo = (*ConnectRes)(nil)
+	_ io.WriterTo = (*ListXProvidersRes)(nil)
+	_ io.WriterTo = (*AddXProvidersReq)(nil)
+	_ io.WriterTo = (*RemoveXProvidersReq)(nil)
+	_ io.WriterTo = (*XProvidersRes)(nil)
+	_ io.WriterTo = (*FindRes)(nil)
 )
 
 func (er *ImportCarReq) WriteTo(w io.Writer) (int64, error) {
@@ -86,6 +96,54 @@ func (er *AnnounceRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (er *ListXProvidersRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListXProvidersRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AddXProvidersReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AddXProvidersReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *RemoveXProvidersReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RemoveXProvidersReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *XProvidersRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *XProvidersRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *FindRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *FindRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *RetrievalPolicy) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RetrievalPolicy) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func respond(w http.ResponseWriter, statusCode int, body io.WriterTo) {
 	w.WriteHeader(statusCode)
 	// Attempt to serialize body as JSON
@@ -60,3 +60,94 @@ type (
 		AdvId cid.Cid `json:"adv_id"`
 	}
 )
+
+type (
+	// ExtendedProvider describes an extended provider in admin requests and
+	// responses.
+	ExtendedProvider struct {
+		// The peer ID of the extended provider.
+		PeerID string `json:"peer_id"`
+		// The multiaddrs of the extended provider.
+		Addrs []string `json:"addrs"`
+		// The optional metadata of the extended provider.
+		Metadata []byte `json:"metadata,omitempty"`
+		// The absolute path, on the daemon host, to the file containing the
+		// private key of the extended provider, marshaled with
+		// crypto.MarshalPrivateKey. The file must be in the extended provider
+		// keys directory of the daemon. Only used in requests, where it is
+		// required unless the daemon already holds or can load the key. The
+		// key itself is never sent over the admin API.
+		PrivKeyPath string `json:"priv_key_path,omitempty"`
+	}
+
+	// ListXProvidersRes represents the response to list extended providers.
+	ListXProvidersRes struct {
+		// Whether the extended providers override the ones that apply to all
+		// content.
+		Override bool `json:"override"`
+		// The metadata of the main provider.
+		Metadata []byte `json:"metadata,omitempty"`
+		// The extended providers.
+		Providers []ExtendedProvider `json:"providers"`
+	}
+
+	// AddXProvidersReq represents a request to add extended providers.
+	AddXProvidersReq struct {
+		// The optional context ID the extended providers apply to. If not
+		// provided, they apply to all content.
+		ContextID []byte `json:"context_id,omitempty"`
+		// The extended providers to add.
+		Providers []ExtendedProvider `json:"providers"`
+	}
+
+	// RemoveXProvidersReq represents a request to remove extended providers.
+	RemoveXProvidersReq struct {
+		// The optional context ID the extended providers apply to.
+		ContextID []byte `json:"context_id,omitempty"`
+		// The peer IDs of the extended providers to remove.
+		PeerIDs []string `json:"peer_ids"`
+	}
+
+	// XProvidersRes represents the response to AddXProvidersReq and
+	// RemoveXProvidersReq.
+	XProvidersRes struct {
+		// The CID of the advertisement generated as a result of the change.
+		AdvId cid.Cid `json:"adv_id"`
+	}
+)
+
+type (
+	// FoundContext describes a context ID that advertises a multihash.
+	FoundContext struct {
+		// The provider ID that the context ID is advertised for.
+		Provider string `json:"provider"`
+		// The context ID.
+		ContextID []byte `json:"context_id"`
+		// The path of the CAR file imported with the context ID, if any.
+		Path string `json:"path,omitempty"`
+	}
+
+	// FindRes represents the response to find the context IDs that advertise
+	// a multihash.
+	FindRes struct {
+		// The context IDs that advertise the multihash.
+		Contexts []FoundContext `json:"contexts"`
+	}
+)
+
+type (
+	// RetrievalPolicy describes the retrieval policy in admin requests and
+	// responses.
+	RetrievalPolicy struct {
+		// Whether peers are allowed to retrieve by default.
+		Allow bool `json:"allow"`
+		// The peer IDs that are exceptions to Allow.
+		Except []string `json:"except,omitempty"`
+		// The maximum number of concurrent transfers to a peer, or zero for
+		// no limit.
+		MaxTransfersPerPeer int `json:"max_transfers_per_peer"`
+		// The maximum number of bytes per second sent to a peer, or zero for
+		// no limit.
+		MaxBytesPerSecondPerPeer int `json:"max_bytes_per_second_per_peer"`
+	}
+)
@@ -1,6 +1,16 @@
 package adminserver
 
-import "time"
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
 
 type (
 	// Option captures a configurable parameter in admin HTTP server.
@@ -10,6 +20,23 @@ type (
 		listenAddr   string
 		readTimeout  time.Duration
 		writeTimeout time.Duration
+		// retrievalProtocols are added to the metadata of imported CARs.
+		retrievalProtocols []metadata.Protocol
+		// retrievalPolicy is the policy adjusted by the retrieval policy
+		// handlers.
+		retrievalPolicy *cardatatransfer.RetrievalPolicy
+		// tokens are the bearer tokens accepted by the server.
+		tokens []bearerToken
+		// tlsConfig, if set, serves the admin API over HTTPS.
+		tlsConfig *tls.Config
+		// unixSocketPath, if set, is the Unix domain socket listened on
+		// instead of listenAddr.
+		unixSocketPath string
+		unixSocketMode fs.FileMode
+		// xpKeysDir is the only directory from which the private keys of
+		// extended providers are loaded, with xpKeyLoader.
+		xpKeysDir   string
+		xpKeyLoader func(path string) (crypto.PrivKey, error)
 	}
 )
 
@@ -37,6 +64,24 @@ func WithListenAddr(addr string) Option {
 	}
 }
 
+// WithUnixSocket listens on a Unix domain socket at the given path, instead of
+// the TCP listen address, so that the admin API is only reachable by local
+// users allowed by the file mode of the socket, such as 0600 for the owner
+// only. A stale socket left at the path by a previous server is replaced.
+func WithUnixSocket(path string, mode fs.FileMode) Option {
+	return func(o *options) error {
+		if path == "" {
+			return errors.New("unix socket path must not be empty")
+		}
+		if mode&^fs.ModePerm != 0 {
+			return fmt.Errorf("unix socket mode %v must only have permission bits", mode)
+		}
+		o.unixSocketPath = path
+		o.unixSocketMode = mode
+		return nil
+	}
+}
+
 // WithReadTimeout set s the HTTP read timeout.
 // If unset, the default of 30 seconds is used.
 func WithReadTimeout(t time.Duration) Option {
@@ -54,3 +99,73 @@ func WithWriteTimeout(t time.Duration) Option {
 		return nil
 	}
 }
+
+// WithRetrievalProtocols sets retrieval protocols that are added to the
+// metadata of every imported CAR, in addition to those in the import request.
+// This is used to advertise the retrieval servers run alongside the admin
+// server.
+func WithRetrievalProtocols(protocols ...metadata.Protocol) Option {
+	return func(o *options) error {
+		o.retrievalProtocols = protocols
+		return nil
+	}
+}
+
+// WithRetrievalPolicy sets the retrieval policy that can be viewed and
+// changed through the admin server. If unset, the retrieval policy endpoints
+// are not served.
+func WithRetrievalPolicy(policy *cardatatransfer.RetrievalPolicy) Option {
+	return func(o *options) error {
+		o.retrievalPolicy = policy
+		return nil
+	}
+}
+
+// WithBearerToken adds a bearer token that clients send in the Authorization
+// header of their requests. Once any token is added, requests without a valid
+// token are rejected. A read-only token only grants access to the endpoints
+// that do not change the state of the provider, such as listing CARs and
+// finding content; the remaining endpoints, such as importing CARs and
+// announcing, require a token that is not read-only.
+//
+// This option may be given multiple times to add multiple tokens.
+func WithBearerToken(token string, readOnly bool) Option {
+	return func(o *options) error {
+		if token == "" {
+			return errors.New("bearer token must not be empty")
+		}
+		o.tokens = append(o.tokens, bearerToken{value: []byte(token), readOnly: readOnly})
+		return nil
+	}
+}
+
+// WithTLSConfig serves the admin API over HTTPS with the given TLS config,
+// which must have at least one certificate. To authenticate clients with
+// mutual TLS, set its ClientAuth to tls.RequireAndVerifyClientCert and its
+// ClientCAs to the certificate authorities of the clients.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(o *options) error {
+		if tlsConfig != nil && len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil {
+			return errors.New("tls config has no certificate")
+		}
+		o.tlsConfig = tlsConfig
+		return nil
+	}
+}
+
+// WithExtendedProviderKeysDir allows requests to add extended providers to name
+// the file holding the private key of an extended provider, which is read with
+// load. Only files under dir are accepted, so that admin clients cannot make
+// the daemon read other files on its host.
+//
+// If unset, requests that name a key file are rejected.
+func WithExtendedProviderKeysDir(dir string, load func(path string) (crypto.PrivKey, error)) Option {
+	return func(o *options) error {
+		if dir != "" && load == nil {
+			return errors.New("extended provider key loader must be set")
+		}
+		o.xpKeysDir = dir
+		o.xpKeyLoader = load
+		return nil
+	}
+}
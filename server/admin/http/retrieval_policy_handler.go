@@ -0,0 +1,66 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/ipni/index-provider/engine/peerutil"
+)
+
+type retrievalPolicyHandler struct {
+	policy *cardatatransfer.RetrievalPolicy
+}
+
+func (h *retrievalPolicyHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+	respond(w, http.StatusOK, toRetrievalPolicy(h.policy.Limits()))
+}
+
+func (h *retrievalPolicyHandler) handleSet(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	if !matchContentTypeJson(w, r) {
+		return
+	}
+	log.Info("Received set retrieval policy request")
+
+	var req RetrievalPolicy
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.MaxTransfersPerPeer < 0 || req.MaxBytesPerSecondPerPeer < 0 {
+		http.Error(w, "limits must not be negative", http.StatusBadRequest)
+		return
+	}
+	peers, err := peerutil.NewPolicyStrings(req.Allow, req.Except)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limits := cardatatransfer.RetrievalLimits{
+		Peers:                    peers,
+		MaxTransfersPerPeer:      req.MaxTransfersPerPeer,
+		MaxBytesPerSecondPerPeer: req.MaxBytesPerSecondPerPeer,
+	}
+	h.policy.SetLimits(limits)
+	log.Infow("Set retrieval policy", "allow", req.Allow, "except", len(req.Except),
+		"maxTransfersPerPeer", req.MaxTransfersPerPeer, "maxBytesPerSecondPerPeer", req.MaxBytesPerSecondPerPeer)
+	respond(w, http.StatusOK, toRetrievalPolicy(limits))
+}
+
+func toRetrievalPolicy(limits cardatatransfer.RetrievalLimits) *RetrievalPolicy {
+	return &RetrievalPolicy{
+		Allow:                    limits.Peers.Default(),
+		Except:                   limits.Peers.ExceptStrings(),
+		MaxTransfersPerPeer:      limits.MaxTransfersPerPeer,
+		MaxBytesPerSecondPerPeer: limits.MaxBytesPerSecondPerPeer,
+	}
+}
@@ -0,0 +1,51 @@
+package adminserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/ipni/index-provider/engine/peerutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_retrievalPolicyHandler(t *testing.T) {
+	policy := cardatatransfer.NewRetrievalPolicy(cardatatransfer.RetrievalLimits{
+		Peers: peerutil.NewPolicy(true),
+	})
+	subject := &retrievalPolicyHandler{policy}
+
+	get := func() RetrievalPolicy {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/admin/retrievalpolicy", nil)
+		require.NoError(t, err)
+		http.HandlerFunc(subject.handleGet).ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var res RetrievalPolicy
+		_, err = res.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		return res
+	}
+	require.Equal(t, RetrievalPolicy{Allow: true}, get())
+
+	denied := random.Peers(1)[0]
+	want := RetrievalPolicy{
+		Allow:                    true,
+		Except:                   []string{denied.String()},
+		MaxTransfersPerPeer:      2,
+		MaxBytesPerSecondPerPeer: 1 << 20,
+	}
+	rr := serveJson(t, subject.handleSet, &want)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, want, get())
+	require.False(t, policy.Allowed(denied))
+	require.Equal(t, 2, policy.Limits().MaxTransfersPerPeer)
+
+	rr = serveJson(t, subject.handleSet, &RetrievalPolicy{Allow: true, Except: []string{"fish"}})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = serveJson(t, subject.handleSet, &RetrievalPolicy{Allow: true, MaxTransfersPerPeer: -1})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, want, get())
+}
@@ -2,9 +2,13 @@ package adminserver
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"io/fs"
 	"mime"
 	"net"
 	"net/http"
+	"os"
 
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipni/index-provider/engine"
@@ -27,10 +31,18 @@ func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (
 		return nil, err
 	}
 
-	l, err := net.Listen("tcp", opts.listenAddr)
+	var l net.Listener
+	if opts.unixSocketPath != "" {
+		l, err = listenUnix(opts.unixSocketPath, opts.unixSocketMode)
+	} else {
+		l, err = net.Listen("tcp", opts.listenAddr)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if opts.tlsConfig != nil {
+		l = tls.NewListener(l, opts.tlsConfig)
+	}
 
 	mux := http.NewServeMux()
 	server := &http.Server{
@@ -41,15 +53,30 @@ func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (
 	s := &Server{server, l, h, e}
 
 	// Set protocol handlers
-	mux.HandleFunc("/admin/announce", s.announceHandler)
-	mux.HandleFunc("/admin/announcehttp", s.announceHttpHandler)
+	auth := &authorizer{opts.tokens}
+	mux.HandleFunc("/admin/announce", auth.mutating(s.announceHandler))
+	mux.HandleFunc("/admin/announcehttp", auth.mutating(s.announceHttpHandler))
+
+	mux.HandleFunc("/admin/connect", auth.mutating(s.connectHandler))
+
+	cHandler := &carHandler{cs, opts.retrievalProtocols}
+	mux.HandleFunc("/admin/import/car", auth.mutating(cHandler.handleImport))
+	mux.HandleFunc("/admin/remove/car", auth.mutating(cHandler.handleRemove))
+	mux.HandleFunc("/admin/list/car", auth.readOnly(cHandler.handleList))
 
-	mux.HandleFunc("/admin/connect", s.connectHandler)
+	fHandler := &findHandler{e, cs}
+	mux.HandleFunc("/admin/find", auth.readOnly(fHandler.handleFind))
 
-	cHandler := &carHandler{cs}
-	mux.HandleFunc("/admin/import/car", cHandler.handleImport)
-	mux.HandleFunc("/admin/remove/car", cHandler.handleRemove)
-	mux.HandleFunc("/admin/list/car", cHandler.handleList)
+	xpHandler := &xprovidersHandler{e, opts.xpKeysDir, opts.xpKeyLoader}
+	mux.HandleFunc("/admin/xproviders", auth.readOnly(xpHandler.handleList))
+	mux.HandleFunc("/admin/xproviders/add", auth.mutating(xpHandler.handleAdd))
+	mux.HandleFunc("/admin/xproviders/remove", auth.mutating(xpHandler.handleRemove))
+
+	if opts.retrievalPolicy != nil {
+		rpHandler := &retrievalPolicyHandler{opts.retrievalPolicy}
+		mux.HandleFunc("/admin/retrievalpolicy", auth.readOnly(rpHandler.handleGet))
+		mux.HandleFunc("/admin/retrievalpolicy/set", auth.mutating(rpHandler.handleSet))
+	}
 
 	return s, nil
 }
@@ -64,6 +91,30 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
 
+// listenUnix listens on a Unix domain socket with the given file mode. The
+// socket file is removed when the listener is closed.
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	// Remove a stale socket left by a server that did not shut down cleanly,
+	// but never a file that is not a socket.
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("cannot listen on unix socket %s: file exists and is not a socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("cannot set unix socket mode: %w", err)
+	}
+	return l, nil
+}
+
 func methodOK(w http.ResponseWriter, r *http.Request, method string) bool {
 	if r.Method != method {
 		w.Header().Set("Allow", method)
@@ -0,0 +1,59 @@
+package adminserver
+
+import (
+	"context"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_UnixSocket(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "admin.sock")
+	// A stale socket is replaced.
+	stale, err := net.Listen("unix", sockPath)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	subject, err := New(nil, nil, nil,
+		WithUnixSocket(sockPath, 0600),
+		WithBearerToken("fish", false))
+	require.NoError(t, err)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- subject.Start()
+	}()
+
+	fi, err := os.Stat(sockPath)
+	require.NoError(t, err)
+	require.NotZero(t, fi.Mode()&fs.ModeSocket)
+	require.Equal(t, fs.FileMode(0600), fi.Mode().Perm())
+
+	cl := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sockPath)
+			},
+		},
+	}
+	resp, err := cl.Get("http://localhost/admin/list/car")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	require.NoError(t, subject.Shutdown(context.Background()))
+	require.ErrorIs(t, <-errChan, http.ErrServerClosed)
+	_, err = os.Stat(sockPath)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// A file that is not a socket is never replaced.
+	require.NoError(t, os.WriteFile(sockPath, []byte("fish"), 0600))
+	_, err = New(nil, nil, nil, WithUnixSocket(sockPath, 0600))
+	require.ErrorContains(t, err, "not a socket")
+}
@@ -0,0 +1,220 @@
+package adminserver
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/engine/xproviders"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type xprovidersHandler struct {
+	e *engine.Engine
+	// keysDir is the only directory from which key files are loaded, with
+	// loadKey.
+	keysDir string
+	loadKey func(path string) (crypto.PrivKey, error)
+}
+
+func (h *xprovidersHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	var contextID []byte
+	if b64 := r.URL.Query().Get("contextID"); b64 != "" {
+		var err error
+		contextID, err = base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			http.Error(w, "contextID is not a valid base64 encoded string", http.StatusBadRequest)
+			return
+		}
+	}
+
+	xps, err := h.e.GetExtendedProviders(r.Context(), contextID)
+	if err != nil {
+		err = fmt.Errorf("failed to get extended providers: %w", err)
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := &ListXProvidersRes{
+		Providers: []ExtendedProvider{},
+	}
+	if xps != nil {
+		resp.Override = xps.Override
+		resp.Metadata = xps.Metadata
+		for _, xp := range xps.Providers {
+			resp.Providers = append(resp.Providers, ExtendedProvider{
+				PeerID:   xp.ID,
+				Addrs:    xp.Addrs,
+				Metadata: xp.Metadata,
+			})
+		}
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+func (h *xprovidersHandler) handleAdd(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	if !matchContentTypeJson(w, r) {
+		return
+	}
+	log.Info("Received add extended providers request")
+
+	var req AddXProvidersReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if len(req.Providers) == 0 {
+		http.Error(w, "at least one extended provider must be specified", http.StatusBadRequest)
+		return
+	}
+
+	infos := make([]xproviders.Info, 0, len(req.Providers))
+	for _, xp := range req.Providers {
+		if _, err := peer.Decode(xp.PeerID); err != nil {
+			http.Error(w, fmt.Sprintf("bad peer id %q: %v", xp.PeerID, err), http.StatusBadRequest)
+			return
+		}
+		if len(xp.Addrs) == 0 {
+			http.Error(w, fmt.Sprintf("no addresses for extended provider %s", xp.PeerID), http.StatusBadRequest)
+			return
+		}
+		info := xproviders.Info{
+			ID:       xp.PeerID,
+			Addrs:    xp.Addrs,
+			Metadata: xp.Metadata,
+		}
+		if xp.PrivKeyPath != "" {
+			priv, err := h.loadPrivKey(xp.PrivKeyPath)
+			if err != nil {
+				msg := fmt.Sprintf("failed to load private key of extended provider %s: %v", xp.PeerID, err)
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			info.Priv = priv
+		}
+		infos = append(infos, info)
+	}
+
+	adCid, err := h.e.AddExtendedProviders(r.Context(), req.ContextID, infos...)
+	if err != nil {
+		h.respondErr(w, "add", err)
+		return
+	}
+	log.Infow("Added extended providers successfully", "count", len(infos), "advertisement", adCid)
+	respond(w, http.StatusOK, &XProvidersRes{AdvId: adCid})
+}
+
+func (h *xprovidersHandler) handleRemove(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	if !matchContentTypeJson(w, r) {
+		return
+	}
+	log.Info("Received remove extended providers request")
+
+	var req RemoveXProvidersReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if len(req.PeerIDs) == 0 {
+		http.Error(w, "at least one peer id must be specified", http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]peer.ID, 0, len(req.PeerIDs))
+	for _, s := range req.PeerIDs {
+		id, err := peer.Decode(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad peer id %q: %v", s, err), http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	adCid, err := h.e.RemoveExtendedProviders(r.Context(), req.ContextID, ids...)
+	if err != nil {
+		h.respondErr(w, "remove", err)
+		return
+	}
+	log.Infow("Removed extended providers successfully", "count", len(ids), "advertisement", adCid)
+	respond(w, http.StatusOK, &XProvidersRes{AdvId: adCid})
+}
+
+func (h *xprovidersHandler) respondErr(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, provider.ErrAlreadyAdvertised):
+		http.Error(w, "extended providers already advertised", http.StatusConflict)
+	case errors.Is(err, provider.ErrContextIDNotFound):
+		http.Error(w, "no extended providers advertised for context id", http.StatusNotFound)
+	case errors.Is(err, engine.ErrNoExtendedProviderKey):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		msg := fmt.Sprintf("failed to %s extended providers: %v", op, err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// errKeyPathNotAllowed is returned for key paths outside the keys directory,
+// without revealing anything about the files at those paths.
+var errKeyPathNotAllowed = errors.New("key path is not in the extended provider keys directory")
+
+// loadPrivKey loads the private key in the file at the given path on the
+// daemon host. The path must be absolute, since it is resolved by the daemon,
+// not by the client, and must resolve to a file under the keys directory.
+func (h *xprovidersHandler) loadPrivKey(path string) (crypto.PrivKey, error) {
+	if h.keysDir == "" {
+		return nil, errors.New("no extended provider keys directory is configured")
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("key path %q is not absolute", path)
+	}
+	// Check the path before touching the file system, then again once
+	// symbolic links are resolved, so that no link leads out of the
+	// directory.
+	if !isUnder(filepath.Clean(h.keysDir), filepath.Clean(path)) {
+		return nil, errKeyPathNotAllowed
+	}
+	keysDir, err := filepath.EvalSymlinks(h.keysDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve extended provider keys directory: %w", err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isUnder(keysDir, resolved) {
+		return nil, errKeyPathNotAllowed
+	}
+	return h.loadKey(resolved)
+}
+
+// isUnder returns whether the clean path is in the clean directory dir or any
+// of its subdirectories.
+func isUnder(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,131 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_xprovidersHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eng, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+
+	keysDir := t.TempDir()
+	subject := &xprovidersHandler{eng, keysDir, loadPrivKey}
+
+	xpID, xpPriv, _ := random.Identity()
+	privBytes, err := crypto.MarshalPrivateKey(xpPriv)
+	require.NoError(t, err)
+	keyPath := filepath.Join(keysDir, "xp.key")
+	require.NoError(t, os.WriteFile(keyPath, privBytes, 0600))
+	xp := ExtendedProvider{
+		PeerID:      xpID.String(),
+		Addrs:       []string{random.Multiaddrs(1)[0].String()},
+		PrivKeyPath: keyPath,
+	}
+
+	// Adding without a key fails, since the engine does not know it yet.
+	noKey := xp
+	noKey.PrivKeyPath = ""
+	rr := serveJson(t, subject.handleAdd, &AddXProvidersReq{Providers: []ExtendedProvider{noKey}})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Key paths are resolved by the daemon, so must be absolute.
+	relKey := xp
+	relKey.PrivKeyPath = "xp.key"
+	rr = serveJson(t, subject.handleAdd, &AddXProvidersReq{Providers: []ExtendedProvider{relKey}})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Key paths outside the keys directory are rejected, even through a
+	// symbolic link, without reading the files.
+	outsideDir := t.TempDir()
+	outsideKey := filepath.Join(outsideDir, "xp.key")
+	require.NoError(t, os.WriteFile(outsideKey, privBytes, 0600))
+	require.NoError(t, os.Symlink(outsideKey, filepath.Join(keysDir, "link.key")))
+	for _, path := range []string{
+		outsideKey,
+		filepath.Join(outsideDir, "missing.key"),
+		filepath.Join(keysDir, "..", filepath.Base(outsideDir), "xp.key"),
+		filepath.Join(keysDir, "link.key"),
+		keysDir,
+	} {
+		outside := xp
+		outside.PrivKeyPath = path
+		rr = serveJson(t, subject.handleAdd, &AddXProvidersReq{Providers: []ExtendedProvider{outside}})
+		require.Equal(t, http.StatusBadRequest, rr.Code, path)
+		require.Contains(t, rr.Body.String(), errKeyPathNotAllowed.Error(), path)
+	}
+
+	// Without a keys directory, no key path is accepted.
+	noDir := &xprovidersHandler{e: eng}
+	rr = serveJson(t, noDir.handleAdd, &AddXProvidersReq{Providers: []ExtendedProvider{xp}})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = serveJson(t, subject.handleAdd, &AddXProvidersReq{Providers: []ExtendedProvider{xp}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var addRes XProvidersRes
+	_, err = addRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	latest, _, err := eng.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, latest, addRes.AdvId)
+
+	// Adding the same provider again does not change anything.
+	rr = serveJson(t, subject.handleAdd, &AddXProvidersReq{Providers: []ExtendedProvider{xp}})
+	require.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/admin/xproviders", nil)
+	require.NoError(t, err)
+	http.HandlerFunc(subject.handleList).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var listRes ListXProvidersRes
+	_, err = listRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, listRes.Providers, 1)
+	require.Equal(t, xp.PeerID, listRes.Providers[0].PeerID)
+	require.Equal(t, xp.Addrs, listRes.Providers[0].Addrs)
+	require.Empty(t, listRes.Providers[0].PrivKeyPath)
+
+	rr = serveJson(t, subject.handleRemove, &RemoveXProvidersReq{PeerIDs: []string{xp.PeerID}})
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = serveJson(t, subject.handleRemove, &RemoveXProvidersReq{ContextID: []byte("fish"), PeerIDs: []string{xp.PeerID}})
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = serveJson(t, subject.handleRemove, &RemoveXProvidersReq{PeerIDs: []string{"fish"}})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func loadPrivKey(path string) (crypto.PrivKey, error) {
+	pkb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.UnmarshalPrivateKey(pkb)
+}
+
+func serveJson(t *testing.T, handler http.HandlerFunc, body any) *httptest.ResponseRecorder {
+	jsonReq, err := json.Marshal(body)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(jsonReq))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
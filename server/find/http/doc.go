@@ -0,0 +1,5 @@
+// Package findserver provides a HTTP server that exposes the IPNI find API for
+// the content advertised by the provider. It answers from the engine reverse
+// index with the same records, in the same JSON shape, as an indexer would,
+// which allows testing retrieval clients without running an indexer.
+package findserver
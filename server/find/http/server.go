@@ -0,0 +1,115 @@
+package findserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/ipni/index-provider/engine"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("findserver")
+
+type Server struct {
+	server *http.Server
+	l      net.Listener
+}
+
+// New creates a find server that answers from the reverse index of the
+// engine, which must be enabled. See: engine.WithReverseIndex.
+func New(e *engine.Engine, o ...Option) (*Server, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", opts.listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Handler:      newHandler(e),
+		ReadTimeout:  opts.readTimeout,
+		WriteTimeout: opts.writeTimeout,
+	}
+	return &Server{server, l}, nil
+}
+
+func (s *Server) Start() error {
+	log.Infow("find http server listening", "addr", s.l.Addr())
+	return s.server.Serve(s.l)
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Info("find http server shutdown")
+	return s.server.Shutdown(ctx)
+}
+
+type findHandler struct {
+	e *engine.Engine
+}
+
+func newHandler(e *engine.Engine) http.Handler {
+	h := &findHandler{e}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /multihash/{multihash}", h.handleFindMultihash)
+	mux.HandleFunc("GET /cid/{cid}", h.handleFindCid)
+	return mux
+}
+
+func (h *findHandler) handleFindMultihash(w http.ResponseWriter, r *http.Request) {
+	mh, err := multihash.FromB58String(r.PathValue("multihash"))
+	if err != nil {
+		http.Error(w, "invalid multihash: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.find(w, r, mh)
+}
+
+func (h *findHandler) handleFindCid(w http.ResponseWriter, r *http.Request) {
+	c, err := cid.Decode(r.PathValue("cid"))
+	if err != nil {
+		http.Error(w, "invalid cid: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.find(w, r, c.Hash())
+}
+
+func (h *findHandler) find(w http.ResponseWriter, r *http.Request, mh multihash.Multihash) {
+	results, err := h.e.FindProviderResults(r.Context(), mh)
+	if err != nil {
+		if errors.Is(err, engine.ErrNoReverseIndex) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		log.Errorw("Failed to find multihash", "multihash", mh, "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	if len(results) == 0 {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	body, err := model.MarshalFindResponse(&model.FindResponse{
+		MultihashResults: []model.MultihashResult{{
+			Multihash:       mh,
+			ProviderResults: results,
+		}},
+	})
+	if err != nil {
+		log.Errorw("Failed to encode find response", "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(body); err != nil {
+		log.Errorw("Failed to write find response", "err", err)
+	}
+}
@@ -0,0 +1,94 @@
+package findserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	providerID, priv, _ := random.Identity()
+	addr := multiaddr.StringCast("/ip4/127.0.0.1/tcp/3103")
+	eng, err := engine.New(
+		engine.WithPrivateKey(priv),
+		engine.WithProvider(peer.AddrInfo{ID: providerID, Addrs: []multiaddr.Multiaddr{addr}}),
+		engine.WithReverseIndex(true),
+	)
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+
+	mhs := random.Multihashes(3)
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+	md := metadata.Default.New(metadata.Bitswap{})
+	_, err = eng.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	wantMetadata, err := md.MarshalBinary()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(newHandler(eng))
+	defer server.Close()
+
+	find := func(path string) (int, *model.FindResponse) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		findResp, err := model.UnmarshalFindResponse(body)
+		require.NoError(t, err)
+		return resp.StatusCode, findResp
+	}
+
+	requireFound := func(resp *model.FindResponse, mh multihash.Multihash) {
+		require.Len(t, resp.MultihashResults, 1)
+		require.Equal(t, mh, resp.MultihashResults[0].Multihash)
+		results := resp.MultihashResults[0].ProviderResults
+		require.Len(t, results, 1)
+		require.Equal(t, []byte("fish"), results[0].ContextID)
+		require.Equal(t, wantMetadata, results[0].Metadata)
+		require.Equal(t, providerID, results[0].Provider.ID)
+		require.Equal(t, []multiaddr.Multiaddr{addr}, results[0].Provider.Addrs)
+	}
+
+	status, resp := find("/multihash/" + mhs[0].B58String())
+	require.Equal(t, http.StatusOK, status)
+	requireFound(resp, mhs[0])
+
+	status, resp = find("/cid/" + cid.NewCidV1(cid.Raw, mhs[1]).String())
+	require.Equal(t, http.StatusOK, status)
+	requireFound(resp, mhs[1])
+
+	status, _ = find("/multihash/" + random.Multihashes(1)[0].B58String())
+	require.Equal(t, http.StatusNotFound, status)
+
+	status, _ = find("/multihash/not-a-multihash")
+	require.Equal(t, http.StatusBadRequest, status)
+
+	_, err = eng.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	status, _ = find("/multihash/" + mhs[0].B58String())
+	require.Equal(t, http.StatusNotFound, status)
+}
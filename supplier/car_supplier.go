@@ -2,9 +2,12 @@ package supplier
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"io"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	bstore "github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/go-cid"
@@ -23,6 +26,10 @@ import (
 const (
 	carSupplierDatastorePrefix = "car_supplier://"
 	carIdDatastoreKeyPrefix    = carSupplierDatastorePrefix + "car_id/"
+	// contextIDDatastoreKeyPrefix maps the base64 encoded context ID of each
+	// CAR to the raw context ID, since the keys of the CAR paths do not
+	// preserve context IDs containing '/'.
+	contextIDDatastoreKeyPrefix = carSupplierDatastorePrefix + "context_id/"
 )
 
 // ErrNotFound signals that CidIteratorSupplier has no iterator corresponding to the given key.
@@ -41,6 +48,15 @@ type CarSupplier struct {
 	eng  provider.Interface
 	ds   datastore.Datastore
 	opts []car.ReadOption
+
+	invalidatorsLock sync.RWMutex
+	invalidators     []BlockstoreInvalidator
+}
+
+// BlockstoreInvalidator is notified when the CAR of a context ID is removed or
+// replaced, so that it stops using the blockstores already opened over it.
+type BlockstoreInvalidator interface {
+	Invalidate(contextID []byte)
 }
 
 // NewCarSupplier instantiates a new CarSupplier and registers it as the provider.MultihashLister of the
@@ -70,14 +86,38 @@ func (cs *CarSupplier) Put(ctx context.Context, contextID []byte, path string, m
 	if err != nil {
 		return cid.Undef, err
 	}
+	if err = cs.ds.Put(ctx, toContextIDKey(contextID), contextID); err != nil {
+		return cid.Undef, err
+	}
+	cs.invalidate(contextID)
 
 	return cs.eng.NotifyPut(ctx, nil, contextID, metadata)
 }
 
+// RegisterInvalidator registers inv to be notified whenever the CAR of a
+// context ID is removed or replaced by this supplier.
+func (cs *CarSupplier) RegisterInvalidator(inv BlockstoreInvalidator) {
+	cs.invalidatorsLock.Lock()
+	defer cs.invalidatorsLock.Unlock()
+	cs.invalidators = append(cs.invalidators, inv)
+}
+
+func (cs *CarSupplier) invalidate(contextID []byte) {
+	cs.invalidatorsLock.RLock()
+	defer cs.invalidatorsLock.RUnlock()
+	for _, inv := range cs.invalidators {
+		inv.Invalidate(contextID)
+	}
+}
+
 func toCarIdKey(contextID []byte) datastore.Key {
 	return datastore.NewKey(carIdDatastoreKeyPrefix + string(contextID))
 }
 
+func toContextIDKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(contextIDDatastoreKeyPrefix + base64.RawURLEncoding.EncodeToString(contextID))
+}
+
 // Remove removes the CAR at the given path from the list of suppliable CID
 // iterators. If the CAR at given path is not known, this function will return
 // an error.  This function accepts both CARv1 and CARv2 formats.
@@ -98,6 +138,10 @@ func (cs *CarSupplier) Remove(ctx context.Context, contextID []byte) (cid.Cid, e
 		// See what we can do to opportunistically heal the datastore.
 		return cid.Undef, err
 	}
+	if err := cs.ds.Delete(ctx, toContextIDKey(contextID)); err != nil {
+		return cid.Undef, err
+	}
+	cs.invalidate(contextID)
 
 	return cs.eng.NotifyRemove(ctx, "", contextID)
 }
@@ -125,6 +169,71 @@ func (cs *CarSupplier) List(ctx context.Context) ([]string, error) {
 	return paths, nil
 }
 
+// IndexContexts adds the multihashes of every CAR supplied to the index of
+// indexer, so that CARs imported before the index was enabled can be located
+// by FindBlockstore. CARs already indexed are skipped by the indexer. A CAR
+// that cannot be indexed is logged and skipped. Returns the number of CARs
+// for which indexing was attempted.
+func (cs *CarSupplier) IndexContexts(ctx context.Context, indexer ContextIndexer) (int, error) {
+	contextIDs, err := cs.contextIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, contextID := range contextIDs {
+		if err = indexer.IndexContext(ctx, "", contextID); err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			log.Errorw("Failed to index CAR", "contextID", contextID, "err", err)
+		}
+	}
+	return len(contextIDs), nil
+}
+
+// contextIDs returns the context IDs of the CARs supplied. The context IDs of
+// CARs put before they were stored in full are recovered from the keys of
+// their paths, which may have changed any '/' in them; a changed context ID
+// was never advertised, so indexing it does nothing.
+func (cs *CarSupplier) contextIDs(ctx context.Context) ([][]byte, error) {
+	results, err := cs.ds.Query(ctx, query.Query{
+		Prefix: contextIDDatastoreKeyPrefix,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var contextIDs [][]byte
+	stored := make(map[string]struct{})
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		contextIDs = append(contextIDs, r.Value)
+		stored[toCarIdKey(r.Value).String()] = struct{}{}
+	}
+
+	results, err = cs.ds.Query(ctx, query.Query{
+		Prefix:   carIdDatastoreKeyPrefix,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	prefix := datastore.NewKey(carIdDatastoreKeyPrefix).String() + "/"
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if _, ok := stored[r.Key]; !ok {
+			contextIDs = append(contextIDs, []byte(strings.TrimPrefix(r.Key, prefix)))
+		}
+	}
+	return contextIDs, nil
+}
+
 // ListMultihashes supplies an iterator over CIDs of the CAR file that corresponds to
 // the given key.  An error is returned if no CAR file is found for the key.
 func (cs *CarSupplier) ListMultihashes(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
@@ -150,6 +259,12 @@ func (cs *CarSupplier) ReadOnlyBlockstore(contextID []byte) (ClosableBlockstore,
 	return blockstore.OpenReadOnly(path, cs.opts...)
 }
 
+// Path returns the path of the CAR file imported with the given context ID.
+// Returns ErrNotFound if there is no such CAR.
+func (cs *CarSupplier) Path(ctx context.Context, contextID []byte) (string, error) {
+	return cs.getPath(ctx, contextID)
+}
+
 func (cs *CarSupplier) getPath(ctx context.Context, contextID []byte) (path string, err error) {
 	b, err := cs.ds.Get(ctx, toCarIdKey(contextID))
 	if err != nil {
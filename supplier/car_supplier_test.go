@@ -151,6 +151,8 @@ func TestRemovedPathIsNoLongerSupplied(t *testing.T) {
 	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
 	subject := NewCarSupplier(mockEng, ds)
 	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+	invalidated := &recordingInvalidator{}
+	subject.RegisterInvalidator(invalidated)
 
 	md := metadata.Default.New(metadata.Bitswap{})
 
@@ -167,6 +169,7 @@ func TestRemovedPathIsNoLongerSupplied(t *testing.T) {
 	id, err := subject.Put(ctx, gotContextID, path, md)
 	require.NoError(t, err)
 	require.Equal(t, wantCid, id)
+	require.Equal(t, [][]byte{gotContextID}, invalidated.contextIDs)
 
 	paths, err := subject.List(ctx)
 	require.NoError(t, err)
@@ -182,6 +185,7 @@ func TestRemovedPathIsNoLongerSupplied(t *testing.T) {
 	removedId, err := subject.Remove(ctx, gotContextID)
 	require.NoError(t, err)
 	require.Equal(t, wantCid, removedId)
+	require.Equal(t, [][]byte{gotContextID, gotContextID}, invalidated.contextIDs)
 
 	_, err = subject.Remove(ctx, gotContextID)
 	require.EqualError(t, err, "no CID iterator found for given key")
@@ -191,6 +195,68 @@ func TestRemovedPathIsNoLongerSupplied(t *testing.T) {
 	require.Len(t, pathsAfterRm, 0)
 }
 
+func TestCarSupplier_IndexContextsKeepsContextIDs(t *testing.T) {
+	path := "../testdata/sample-wrapped-v2.car"
+	rng := rand.New(rand.NewSource(1413))
+
+	ctx := context.Background()
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	ds := datastore.NewMapDatastore()
+
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	subject := NewCarSupplier(mockEng, ds)
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	mockEng.
+		EXPECT().
+		NotifyPut(ctx, gomock.Any(), gomock.Any(), md).
+		Return(generateCidV1(t, rng), nil).
+		Times(3)
+	mockEng.
+		EXPECT().
+		NotifyRemove(ctx, peer.ID(""), gomock.Any()).
+		Return(generateCidV1(t, rng), nil)
+
+	// Context IDs containing '/' are changed by the keys of the CAR paths.
+	contextIDs := [][]byte{
+		[]byte("fish//lobster/"),
+		{'/', 0x00, '.', '/', 0xff},
+		[]byte("removed"),
+	}
+	for _, contextID := range contextIDs {
+		_, err := subject.Put(ctx, contextID, path, md)
+		require.NoError(t, err)
+	}
+	_, err := subject.Remove(ctx, []byte("removed"))
+	require.NoError(t, err)
+
+	indexer := &recordingIndexer{}
+	n, err := subject.IndexContexts(ctx, indexer)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.ElementsMatch(t, contextIDs[:2], indexer.contextIDs)
+}
+
+type recordingIndexer struct {
+	contextIDs [][]byte
+}
+
+func (r *recordingIndexer) IndexContext(_ context.Context, _ peer.ID, contextID []byte) error {
+	r.contextIDs = append(r.contextIDs, contextID)
+	return nil
+}
+
+type recordingInvalidator struct {
+	contextIDs [][]byte
+}
+
+func (r *recordingInvalidator) Invalidate(contextID []byte) {
+	r.contextIDs = append(r.contextIDs, contextID)
+}
+
 func generateCidV1(t *testing.T, rng *rand.Rand) cid.Cid {
 	data := []byte(fmt.Sprintf("🌊d-%d", rng.Uint64()))
 	mh, err := multihash.Sum(data, multihash.SHA3_256, -1)
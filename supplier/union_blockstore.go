@@ -0,0 +1,151 @@
+package supplier
+
+import (
+	"context"
+	"errors"
+
+	bstore "github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+var errReadOnly = errors.New("read-only blockstore")
+
+// ContextFinder finds the context IDs that advertise a multihash. It is
+// implemented by engine.Engine when the reverse index is enabled.
+//
+// See: engine.WithReverseIndex.
+type ContextFinder interface {
+	FindContexts(ctx context.Context, mh multihash.Multihash) ([]engine.ContextRef, error)
+}
+
+var _ ContextFinder = (*engine.Engine)(nil)
+
+// ContextIndexer adds the multihashes of a context ID to the index used by a
+// ContextFinder. It is implemented by engine.Engine when the reverse index is
+// enabled.
+//
+// See: engine.Engine.IndexContext.
+type ContextIndexer interface {
+	IndexContext(ctx context.Context, p peer.ID, contextID []byte) error
+}
+
+var _ ContextIndexer = (*engine.Engine)(nil)
+
+// BlockstoreOpener opens the read-only blockstore of the CAR supplied for a
+// context ID. It is implemented by CarSupplier, and by pools that share the
+// blockstores it opens, which should be preferred for repeated lookups since
+// opening a CARv1 indexes it anew.
+type BlockstoreOpener interface {
+	ReadOnlyBlockstore(contextID []byte) (ClosableBlockstore, error)
+}
+
+var _ BlockstoreOpener = (*CarSupplier)(nil)
+
+// FindBlockstore opens, with opener, the blockstore of a supplied CAR that
+// holds the block c, using finder to locate the CARs that advertise it.
+// Returns ErrNotFound if no supplied CAR holds the block.
+func FindBlockstore(ctx context.Context, opener BlockstoreOpener, finder ContextFinder, c cid.Cid) (ClosableBlockstore, error) {
+	refs, err := finder.FindContexts(ctx, c.Hash())
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		bs, err := opener.ReadOnlyBlockstore(ref.ContextID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				// Context ID advertised by other means than this supplier.
+				continue
+			}
+			return nil, err
+		}
+		has, err := bs.Has(ctx, c)
+		if err == nil && has {
+			return bs, nil
+		}
+		bs.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// UnionBlockstore is a read-only blockstore over the blocks of all the CARs
+// supplied by a CarSupplier. The CAR that holds a block is located using a
+// ContextFinder, and its blockstore is acquired from a BlockstoreOpener for
+// the duration of each operation.
+type UnionBlockstore struct {
+	opener BlockstoreOpener
+	finder ContextFinder
+}
+
+var _ bstore.Blockstore = (*UnionBlockstore)(nil)
+
+// NewUnionBlockstore instantiates a new UnionBlockstore over the CARs opened
+// by opener, located with finder.
+func NewUnionBlockstore(opener BlockstoreOpener, finder ContextFinder) *UnionBlockstore {
+	return &UnionBlockstore{
+		opener: opener,
+		finder: finder,
+	}
+}
+
+func (u *UnionBlockstore) Has(ctx context.Context, c cid.Cid) (bool, error) {
+	bs, err := FindBlockstore(ctx, u.opener, u.finder, c)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, bs.Close()
+}
+
+func (u *UnionBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	bs, err := FindBlockstore(ctx, u.opener, u.finder, c)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ipld.ErrNotFound{Cid: c}
+		}
+		return nil, err
+	}
+	defer bs.Close()
+	return bs.Get(ctx, c)
+}
+
+func (u *UnionBlockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	bs, err := FindBlockstore(ctx, u.opener, u.finder, c)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return -1, ipld.ErrNotFound{Cid: c}
+		}
+		return -1, err
+	}
+	defer bs.Close()
+	return bs.GetSize(ctx, c)
+}
+
+// AllKeysChan is not supported, since the CARs supplied may be large and
+// overlap.
+func (u *UnionBlockstore) AllKeysChan(context.Context) (<-chan cid.Cid, error) {
+	return nil, errors.New("listing all keys is not supported")
+}
+
+func (u *UnionBlockstore) HashOnRead(bool) {}
+
+func (u *UnionBlockstore) DeleteBlock(context.Context, cid.Cid) error {
+	return errReadOnly
+}
+
+func (u *UnionBlockstore) Put(context.Context, blocks.Block) error {
+	return errReadOnly
+}
+
+func (u *UnionBlockstore) PutMany(context.Context, []blocks.Block) error {
+	return errReadOnly
+}
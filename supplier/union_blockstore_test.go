@@ -0,0 +1,104 @@
+package supplier
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-test/random"
+	"github.com/ipld/go-car/v2/blockstore"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnionBlockstore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eng, err := engine.New(engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+
+	cs := NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	subject := NewUnionBlockstore(cs, eng)
+
+	bs, err := blockstore.OpenReadOnly("../testdata/sample-v1-2.car")
+	require.NoError(t, err)
+	roots, err := bs.Roots()
+	require.NoError(t, err)
+	want, err := bs.Get(ctx, roots[0])
+	require.NoError(t, err)
+	require.NoError(t, bs.Close())
+
+	has, err := subject.Has(ctx, roots[0])
+	require.NoError(t, err)
+	require.False(t, has)
+	_, err = subject.Get(ctx, roots[0])
+	require.True(t, ipld.IsNotFound(err))
+
+	for _, name := range []string{"sample-v1.car", "sample-v1-2.car"} {
+		carPath, err := filepath.Abs(filepath.Join("../testdata", name))
+		require.NoError(t, err)
+		_, err = cs.Put(ctx, []byte(name), carPath, metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+
+	has, err = subject.Has(ctx, roots[0])
+	require.NoError(t, err)
+	require.True(t, has)
+	got, err := subject.Get(ctx, roots[0])
+	require.NoError(t, err)
+	require.Equal(t, want.RawData(), got.RawData())
+	size, err := subject.GetSize(ctx, roots[0])
+	require.NoError(t, err)
+	require.Equal(t, len(want.RawData()), size)
+
+	require.Error(t, subject.Put(ctx, got))
+}
+
+func TestCarSupplier_IndexContexts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	_, priv, _ := random.Identity()
+	carPath, err := filepath.Abs("../testdata/sample-v1.car")
+	require.NoError(t, err)
+
+	// CAR imported before the reverse index is enabled.
+	before, err := engine.New(engine.WithDatastore(ds), engine.WithPrivateKey(priv))
+	require.NoError(t, err)
+	require.NoError(t, before.Start(ctx))
+	_, err = NewCarSupplier(before, ds).Put(ctx, []byte("fish"), carPath, metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.NoError(t, before.Shutdown())
+
+	eng, err := engine.New(engine.WithDatastore(ds), engine.WithPrivateKey(priv), engine.WithReverseIndex(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+	cs := NewCarSupplier(eng, ds)
+	subject := NewUnionBlockstore(cs, eng)
+
+	bs, err := blockstore.OpenReadOnly(carPath)
+	require.NoError(t, err)
+	roots, err := bs.Roots()
+	require.NoError(t, err)
+	require.NoError(t, bs.Close())
+
+	has, err := subject.Has(ctx, roots[0])
+	require.NoError(t, err)
+	require.False(t, has)
+
+	n, err := cs.IndexContexts(ctx, eng)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	has, err = subject.Has(ctx, roots[0])
+	require.NoError(t, err)
+	require.True(t, has)
+}